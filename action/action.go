@@ -0,0 +1,62 @@
+package action
+
+import (
+	"context"
+	"gotinder/geo"
+	"gotinder/pagination"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+const (
+	Like Type = "likes"
+	Pass Type = "passes"
+)
+
+var (
+	ErrQuotaExceeded = errors.New("exceed max action allowed")
+	ErrLikeNotFound  = errors.New("like not found")
+)
+
+type (
+	// Type is a type of available actions
+	Type string
+
+	// Action is a type of outgoing action, ID is the target
+	Action struct {
+		ID          uuid.UUID `json:"id"`
+		BirthOfDate int64     `json:"birth_of_date"`
+		CreatedAt   int64     `json:"created_at"`
+	}
+
+	// ReceivedLike is a type of like received from other user, Place is nil when their location is not resolved
+	ReceivedLike struct {
+		ID          uuid.UUID
+		BirthOfDate int64
+		Place       *geo.Place
+		LikedAt     int64
+	}
+
+	// Repository is an interface of action storage
+	Repository interface {
+		// Create record the action, acting twice on the same target is ignored
+		Create(ctx context.Context, t Type, selfID, targetID string) error
+		// Delete remove the action, false means there was none
+		Delete(ctx context.Context, t Type, selfID, targetID string) (bool, error)
+		// Find give actions of the user after cursor, newest first
+		Find(ctx context.Context, t Type, selfID string, after *pagination.Cursor, limit int) ([]Action, error)
+		// CountReceivedLikes count likes of other users the user has not acted on yet
+		CountReceivedLikes(ctx context.Context, selfID string) (int64, error)
+		// FindReceivedLikes give likes of other users the user has not acted on yet after cursor, newest first
+		FindReceivedLikes(ctx context.Context, selfID string, after *pagination.Cursor, limit int) ([]ReceivedLike, error)
+	}
+
+	// QuotaStore is an interface of counting targets the user acted on within a day
+	QuotaStore interface {
+		Count(ctx context.Context, selfID string) (int, error)
+		Add(ctx context.Context, selfID, targetID string) error
+		// Remove take the target off acted targets while the action keeps counting toward the quota
+		Remove(ctx context.Context, selfID, targetID string) error
+	}
+)
@@ -0,0 +1,74 @@
+package action
+
+import (
+	"context"
+	"fmt"
+	"gotinder/infra"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/google/uuid"
+)
+
+// aDayInSecond is how long acted targets are counted toward the quota
+const aDayInSecond = 60 * 60 * 24
+
+// RedisQuotaStore count acted targets on redis set which expires a day after first action
+type RedisQuotaStore struct {
+	pool *redis.Pool
+}
+
+var _ QuotaStore = &RedisQuotaStore{}
+
+func NewRedisQuotaStore(pool *redis.Pool) *RedisQuotaStore {
+	return &RedisQuotaStore{pool: pool}
+}
+
+func (q *RedisQuotaStore) Count(ctx context.Context, selfID string) (int, error) {
+	cacheConn, err := infra.RedisConn(ctx, q.pool)
+	if err != nil {
+		return 0, err
+	}
+	defer cacheConn.Close()
+
+	return redis.Int(cacheConn.Do("SCARD", quotaKey(selfID)))
+}
+
+func (q *RedisQuotaStore) Add(ctx context.Context, selfID, targetID string) error {
+	cacheConn, err := infra.RedisConn(ctx, q.pool)
+	if err != nil {
+		return err
+	}
+	defer cacheConn.Close()
+
+	if _, err := cacheConn.Do("SADD", quotaKey(selfID), targetID); err != nil {
+		return err
+	}
+	_, err = cacheConn.Do("EXPIRE", quotaKey(selfID), aDayInSecond, "NX")
+	return err
+}
+
+// Remove replace the target by unique withdrawn member, so the set no longer has the target
+// but its size, the consumed quota, stays the same
+func (q *RedisQuotaStore) Remove(ctx context.Context, selfID, targetID string) error {
+	cacheConn, err := infra.RedisConn(ctx, q.pool)
+	if err != nil {
+		return err
+	}
+	defer cacheConn.Close()
+
+	removed, err := redis.Int(cacheConn.Do("SREM", quotaKey(selfID), targetID))
+	if err != nil || removed == 0 {
+		return err
+	}
+	_, err = cacheConn.Do("SADD", quotaKey(selfID), withdrawnMember())
+	return err
+}
+
+// withdrawnMember give member of acted targets standing for a withdrawn action
+func withdrawnMember() string {
+	return "withdrawn-" + uuid.NewString()
+}
+
+func quotaKey(selfID string) string {
+	return fmt.Sprintf("action-%s", selfID)
+}
@@ -0,0 +1,140 @@
+package action
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"gotinder/geo"
+	"gotinder/infra"
+	"gotinder/pagination"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/pkg/errors"
+)
+
+// PostgresRepository store actions on postgresql, each action type on its own table
+type PostgresRepository struct {
+	db *sql.DB
+}
+
+var _ Repository = &PostgresRepository{}
+
+func NewPostgresRepository(db *sql.DB) *PostgresRepository {
+	return &PostgresRepository{db: db}
+}
+
+var psql = sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+func (r *PostgresRepository) Create(ctx context.Context, t Type, selfID, targetID string) error {
+	if _, err := psql.
+		Insert(string(t)).
+		Columns("self_id", "target_id").
+		Values(selfID, targetID).
+		Suffix("ON CONFLICT (self_id,target_id) DO NOTHING").
+		RunWith(infra.PgRunner(ctx, r.db)).
+		ExecContext(ctx); err != nil {
+		return errors.Wrap(err, "failed to record request")
+	}
+	return nil
+}
+
+func (r *PostgresRepository) Delete(ctx context.Context, t Type, selfID, targetID string) (bool, error) {
+	result, err := psql.
+		Delete(string(t)).
+		Where("self_id = ?", selfID).
+		Where("target_id = ?", targetID).
+		RunWith(infra.PgRunner(ctx, r.db)).
+		ExecContext(ctx)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to withdraw %s", t)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func (r *PostgresRepository) Find(ctx context.Context, t Type, selfID string, after *pagination.Cursor, limit int) ([]Action, error) {
+	table := string(t)
+	rows, err := pagination.Apply(
+		psql.
+			Select("users.id", "users.birth_of_date", fmt.Sprintf("%s.created_at", table)).
+			From(table).
+			InnerJoin(fmt.Sprintf("users ON users.id = %s.target_id", table)).
+			Where(fmt.Sprintf("%s.self_id = ?", table), selfID),
+		after,
+		fmt.Sprintf("%s.created_at", table),
+		fmt.Sprintf("%s.target_id", table),
+		limit,
+	).
+		RunWith(infra.PgRunner(ctx, r.db)).
+		QueryContext(ctx)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to find %s", table)
+	}
+	defer rows.Close()
+
+	actions := make([]Action, 0)
+	for rows.Next() {
+		var act Action
+		if err := rows.Scan(&act.ID, &act.BirthOfDate, &act.CreatedAt); err != nil {
+			return nil, err
+		}
+		actions = append(actions, act)
+	}
+	return actions, rows.Err()
+}
+
+func (r *PostgresRepository) CountReceivedLikes(ctx context.Context, selfID string) (int64, error) {
+	var count int64
+	if err := pendingLikes(psql.Select("COUNT(*)"), selfID).
+		RunWith(infra.PgRunner(ctx, r.db)).
+		QueryRowContext(ctx).
+		Scan(&count); err != nil {
+		return 0, errors.Wrap(err, "failed to count received likes")
+	}
+	return count, nil
+}
+
+func (r *PostgresRepository) FindReceivedLikes(ctx context.Context, selfID string, after *pagination.Cursor, limit int) ([]ReceivedLike, error) {
+	rows, err := pagination.Apply(
+		pendingLikes(psql.Select("users.id", "users.birth_of_date", "latest_locations.city", "latest_locations.country_code", "likes.created_at"), selfID),
+		after,
+		"likes.created_at",
+		"likes.self_id",
+		limit,
+	).
+		RunWith(infra.PgRunner(ctx, r.db)).
+		QueryContext(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to find received likes")
+	}
+	defer rows.Close()
+
+	likes := make([]ReceivedLike, 0)
+	for rows.Next() {
+		var like ReceivedLike
+		var city, countryCode sql.NullString
+		if err := rows.Scan(&like.ID, &like.BirthOfDate, &city, &countryCode, &like.LikedAt); err != nil {
+			return nil, err
+		}
+		if city.Valid && countryCode.Valid {
+			like.Place = &geo.Place{City: city.String, CountryCode: countryCode.String}
+		}
+		likes = append(likes, like)
+	}
+	return likes, rows.Err()
+}
+
+// pendingLikes narrow down query to likes received by the user who has not liked nor passed the liker
+func pendingLikes(query sq.SelectBuilder, selfID string) sq.SelectBuilder {
+	return query.
+		From("likes").
+		InnerJoin("users ON users.id = likes.self_id").
+		LeftJoin("latest_locations ON latest_locations.user_id = likes.self_id").
+		Where("likes.target_id = ?", selfID).
+		Where("NOT EXISTS (SELECT 1 FROM likes AS acted WHERE acted.self_id = ? AND acted.target_id = likes.self_id)", selfID).
+		Where("NOT EXISTS (SELECT 1 FROM passes AS acted WHERE acted.self_id = ? AND acted.target_id = likes.self_id)", selfID)
+}
@@ -0,0 +1,77 @@
+package action
+
+import (
+	"context"
+	"gotinder/pagination"
+)
+
+// Service is a type of action business logic
+type Service struct {
+	repo  Repository
+	quota QuotaStore
+}
+
+func NewService(repo Repository, quota QuotaStore) *Service {
+	return &Service{repo: repo, quota: quota}
+}
+
+// Act record that the user is acting on the target, maxDaily of zero means unlimited
+func (s *Service) Act(ctx context.Context, t Type, selfID, targetID string, maxDaily int) error {
+	if maxDaily > 0 {
+		count, err := s.quota.Count(ctx, selfID)
+		if err != nil {
+			return err
+		}
+		if count >= maxDaily {
+			return ErrQuotaExceeded
+		}
+	}
+
+	if err := s.repo.Create(ctx, t, selfID, targetID); err != nil {
+		return err
+	}
+	return s.quota.Add(ctx, selfID, targetID)
+}
+
+// Withdraw remove the user's like on the target along with the target in their acted targets.
+// quota the like consumed is kept, otherwise liking and withdrawing repeatedly would bypass the daily limit.
+// no match state is kept apart from likes, so there is nothing else to clean up
+func (s *Service) Withdraw(ctx context.Context, selfID, targetID string) error {
+	deleted, err := s.repo.Delete(ctx, Like, selfID, targetID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrLikeNotFound
+	}
+	return s.quota.Remove(ctx, selfID, targetID)
+}
+
+// Find give page of actions of the user along with cursor of next page
+func (s *Service) Find(ctx context.Context, t Type, selfID string, after *pagination.Cursor, limit int) ([]Action, string, error) {
+	actions, err := s.repo.Find(ctx, t, selfID, after, limit)
+	if err != nil {
+		return nil, "", err
+	}
+	actions, next := pagination.Next(actions, limit, func(act Action) pagination.Cursor {
+		return pagination.Cursor{CreatedAt: act.CreatedAt, ID: act.ID.String()}
+	})
+	return actions, next, nil
+}
+
+// ReceivedLikes give page of likes the user has not acted on yet along with their total and cursor of next page
+func (s *Service) ReceivedLikes(ctx context.Context, selfID string, after *pagination.Cursor, limit int) ([]ReceivedLike, int64, string, error) {
+	count, err := s.repo.CountReceivedLikes(ctx, selfID)
+	if err != nil {
+		return nil, 0, "", err
+	}
+
+	likes, err := s.repo.FindReceivedLikes(ctx, selfID, after, limit)
+	if err != nil {
+		return nil, 0, "", err
+	}
+	likes, next := pagination.Next(likes, limit, func(like ReceivedLike) pagination.Cursor {
+		return pagination.Cursor{CreatedAt: like.LikedAt, ID: like.ID.String()}
+	})
+	return likes, count, next, nil
+}
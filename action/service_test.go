@@ -0,0 +1,81 @@
+package action_test
+
+import (
+	"context"
+	"gotinder/action"
+	"gotinder/pagination"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type (
+	stubRepository struct {
+		created map[string]bool
+	}
+
+	stubQuotaStore struct {
+		targets map[string]bool
+	}
+)
+
+func (r *stubRepository) Create(ctx context.Context, t action.Type, selfID, targetID string) error {
+	r.created[string(t)+targetID] = true
+	return nil
+}
+
+func (r *stubRepository) Delete(ctx context.Context, t action.Type, selfID, targetID string) (bool, error) {
+	deleted := r.created[string(t)+targetID]
+	delete(r.created, string(t)+targetID)
+	return deleted, nil
+}
+
+func (r *stubRepository) Find(ctx context.Context, t action.Type, selfID string, after *pagination.Cursor, limit int) ([]action.Action, error) {
+	return nil, nil
+}
+
+func (r *stubRepository) CountReceivedLikes(ctx context.Context, selfID string) (int64, error) {
+	return 0, nil
+}
+
+func (r *stubRepository) FindReceivedLikes(ctx context.Context, selfID string, after *pagination.Cursor, limit int) ([]action.ReceivedLike, error) {
+	return nil, nil
+}
+
+func (q *stubQuotaStore) Count(ctx context.Context, selfID string) (int, error) {
+	return len(q.targets), nil
+}
+
+func (q *stubQuotaStore) Add(ctx context.Context, selfID, targetID string) error {
+	q.targets[targetID] = true
+	return nil
+}
+
+func (q *stubQuotaStore) Remove(ctx context.Context, selfID, targetID string) error {
+	if q.targets[targetID] {
+		delete(q.targets, targetID)
+		q.targets["withdrawn-"+targetID] = true
+	}
+	return nil
+}
+
+func TestService_Act(t *testing.T) {
+	ctx := context.Background()
+	quota := &stubQuotaStore{targets: map[string]bool{}}
+	service := action.NewService(&stubRepository{created: map[string]bool{}}, quota)
+
+	assert.Nil(t, service.Act(ctx, action.Like, "self", "target-1", 2))
+	assert.Nil(t, service.Act(ctx, action.Pass, "self", "target-2", 2))
+	assert.ErrorIs(t, service.Act(ctx, action.Like, "self", "target-3", 2), action.ErrQuotaExceeded)
+
+	// unlimited actions are still counted, so limit applies right away when plan changes
+	assert.Nil(t, service.Act(ctx, action.Like, "self", "target-3", 0))
+	assert.Len(t, quota.targets, 3)
+
+	// withdrawn like is taken off acted targets but keeps consuming the quota, so liking again is still limited
+	assert.Nil(t, service.Withdraw(ctx, "self", "target-1"))
+	assert.Len(t, quota.targets, 3)
+	assert.False(t, quota.targets["target-1"])
+	assert.ErrorIs(t, service.Withdraw(ctx, "self", "target-1"), action.ErrLikeNotFound)
+	assert.ErrorIs(t, service.Act(ctx, action.Like, "self", "target-4", 2), action.ErrQuotaExceeded)
+}
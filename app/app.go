@@ -0,0 +1,177 @@
+package app
+
+import (
+	"database/sql"
+	"gotinder/action"
+	"gotinder/config"
+	"gotinder/coupon"
+	"gotinder/geo"
+	"gotinder/infra"
+	"gotinder/location"
+	"gotinder/memory"
+	"gotinder/metrics"
+	"gotinder/notification"
+	"gotinder/payment"
+	"gotinder/recommendation"
+	"gotinder/subscription"
+	"gotinder/user"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// offlineGeocoder is shared by apps, so bundled dataset is decoded once per process
+var offlineGeocoder = geo.NewOfflineGeocoder()
+
+type (
+	// App is a type owning configuration, connections and services of one running instance,
+	// apps share no state so several of them can run isolated in one process
+	App struct {
+		Config   *config.Configuration
+		DB       *sql.DB
+		Cache    *redis.Pool
+		Payment  infra.PaymentProvider
+		Nearby   infra.NearbyIndex
+		Geocoder geo.Geocoder
+		Metrics  *metrics.Metrics
+		Services Services
+	}
+
+	// Services is a type to group business logic used by handlers, jobs and commands
+	Services struct {
+		Users           *user.Service
+		Subscriptions   *subscription.Service
+		Actions         *action.Service
+		Coupons         *coupon.Service
+		Locations       *location.Service
+		Recommendations *recommendation.Service
+		Payments        *payment.Service
+	}
+
+	// storage is a type to group persistence and cache implementations services are wired on
+	storage struct {
+		tx              infra.Transactor
+		users           user.Repository
+		subscriptions   subscription.Repository
+		notifications   notification.Repository
+		publisher       notification.Publisher
+		actions         action.Repository
+		quota           action.QuotaStore
+		coupons         coupon.Repository
+		locations       location.Repository
+		throttle        location.ThrottleStore
+		nearby          infra.NearbyIndex
+		discovery       infra.NearbyIndex
+		recommendations recommendation.Repository
+		payments        payment.Repository
+	}
+)
+
+// New give app on postgresql and redis, wiring services by the configuration.
+// connections are owned by the app from now on and closed by Close
+func New(cfg *config.Configuration, db *sql.DB, cache *redis.Pool) *App {
+	a := newApp(cfg, db, cache, postgresStorage(db, cache, infra.NewNearbyIndex(cfg.Discovery.NearbyIndex, db, cache)))
+	a.Metrics.RegisterDB(db)
+	a.Metrics.RegisterRedisPool(cache)
+	return a
+}
+
+// NewMemory give app kept on the in-memory store, so it can be served without postgresql and redis
+func NewMemory(cfg *config.Configuration, store *memory.Store) *App {
+	return newApp(cfg, nil, nil, memoryStorage(store))
+}
+
+// Close close connections owned by the app
+func (a *App) Close() {
+	infra.TerminalRedisPool(a.Cache)
+	infra.TerminatePgConnection(a.DB)
+}
+
+// newApp give app with services wired on the storage along with configured infra and policies
+func newApp(cfg *config.Configuration, db *sql.DB, cache *redis.Pool, s storage) *App {
+	a := &App{
+		Config:   cfg,
+		DB:       db,
+		Cache:    cache,
+		Payment:  infra.NewPaymentProvider(cfg.Payment.Provider, cfg.Payment.WebhookSecret),
+		Nearby:   s.nearby,
+		Geocoder: offlineGeocoder,
+		Metrics:  metrics.New(),
+	}
+
+	distancePolicy := geo.NewFuzzPolicy(
+		cfg.Discovery.Fuzzing.JitterInMeter,
+		cfg.Discovery.Fuzzing.MinDistanceInMeter,
+		cfg.Discovery.Fuzzing.BucketInMeter,
+		cfg.Discovery.Fuzzing.Secret,
+	)
+	locationThrottle := location.NewThrottle(
+		cfg.Discovery.Throttling.Interval,
+		cfg.Discovery.Throttling.MinDistanceInMeter,
+		cfg.Discovery.Throttling.MaxUpdates,
+	)
+	subscriptions := subscription.NewService(s.subscriptions, s.tx, s.notifications, s.publisher)
+
+	a.Services = Services{
+		Users:         user.NewService(s.users),
+		Subscriptions: subscriptions,
+		Actions:       action.NewService(s.actions, s.quota),
+		Coupons:       coupon.NewService(s.coupons, s.tx, subscriptions),
+		Locations: location.NewService(
+			s.locations,
+			s.tx,
+			s.nearby,
+			a.Geocoder,
+			distancePolicy,
+			locationThrottle,
+			s.throttle,
+		),
+		Recommendations: recommendation.NewService(s.recommendations, s.discovery, distancePolicy),
+		Payments:        payment.NewService(s.payments, s.tx, a.Payment, subscriptions),
+	}
+	return a
+}
+
+// postgresStorage give storage on postgresql and redis along with the nearby index,
+// recommendations search on postgresql directly when the index is PostGIS so acted on users are filtered there
+func postgresStorage(db *sql.DB, pool *redis.Pool, nearby infra.NearbyIndex) storage {
+	discovery := nearby
+	if _, ok := nearby.(*infra.PostgisNearbyIndex); ok {
+		discovery = nil
+	}
+	return storage{
+		tx:              infra.NewPgTransactor(db),
+		users:           user.NewPostgresRepository(db),
+		subscriptions:   subscription.NewPostgresRepository(db),
+		notifications:   notification.NewPostgresRepository(db),
+		publisher:       notification.NewRedisPublisher(pool),
+		actions:         action.NewPostgresRepository(db),
+		quota:           action.NewRedisQuotaStore(pool),
+		coupons:         coupon.NewPostgresRepository(db),
+		locations:       location.NewPostgresRepository(db),
+		throttle:        location.NewRedisThrottleStore(pool),
+		nearby:          nearby,
+		discovery:       discovery,
+		recommendations: recommendation.NewPostgresRepository(db),
+		payments:        payment.NewPostgresRepository(db),
+	}
+}
+
+// memoryStorage give storage kept on the in-memory store
+func memoryStorage(store *memory.Store) storage {
+	return storage{
+		tx:              store,
+		users:           memory.NewUserRepository(store),
+		subscriptions:   memory.NewSubscriptionRepository(store),
+		notifications:   memory.NewNotificationRepository(store),
+		publisher:       memory.NewPublisher(store),
+		actions:         memory.NewActionRepository(store),
+		quota:           memory.NewQuotaStore(store),
+		coupons:         memory.NewCouponRepository(store),
+		locations:       memory.NewLocationRepository(store),
+		throttle:        memory.NewThrottleStore(store),
+		nearby:          memory.NewNearbyIndex(store),
+		discovery:       memory.NewNearbyIndex(store),
+		recommendations: memory.NewRecommendationRepository(store),
+		payments:        memory.NewPaymentRepository(store),
+	}
+}
@@ -0,0 +1,104 @@
+package apperr
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	CodeInvalidRequest Code = "invalid_request"
+	CodeUnauthorized   Code = "unauthorized"
+	CodeForbidden      Code = "forbidden"
+	CodeNotFound       Code = "not_found"
+	CodeConflict       Code = "conflict"
+	CodeInternal       Code = "internal"
+	CodeUnavailable    Code = "unavailable"
+	CodeTimeout        Code = "timeout"
+)
+
+var (
+	ErrInvalidRequest = New(CodeInvalidRequest, http.StatusBadRequest, "invalid request")
+	ErrUnauthorized   = New(CodeUnauthorized, http.StatusUnauthorized, "unauthorized")
+	ErrForbidden      = New(CodeForbidden, http.StatusForbidden, "access denied")
+	ErrNotFound       = New(CodeNotFound, http.StatusNotFound, "resource not found")
+	ErrConflict       = New(CodeConflict, http.StatusConflict, "resource already exists")
+	ErrInternal       = New(CodeInternal, http.StatusInternalServerError, "internal server error")
+	ErrUnavailable    = New(CodeUnavailable, http.StatusServiceUnavailable, "service temporarily unavailable")
+	ErrTimeout        = New(CodeTimeout, http.StatusGatewayTimeout, "request timed out")
+
+	// ErrReferenceNotFound is not found error of resource referenced by the request, rather than the requested one
+	ErrReferenceNotFound = ErrNotFound.WithMessage("referenced resource not found")
+)
+
+type (
+	// Code is a type of stable machine-readable error identifier exposed to clients
+	Code string
+
+	// Error is a type of error exposed to clients. Message is safe to show to the user,
+	// while the cause is kept for logging only
+	Error struct {
+		Code    Code
+		Status  int
+		Message string
+		cause   error
+	}
+)
+
+func New(code Code, status int, message string) *Error {
+	return &Error{Code: code, Status: status, Message: message}
+}
+
+// InvalidRequest give invalid request error showing message of err, used for errors describing the request itself
+func InvalidRequest(err error) *Error {
+	return ErrInvalidRequest.WithMessage(err.Error()).Wrap(err)
+}
+
+func (e *Error) Error() string {
+	if e.cause == nil {
+		return e.Message
+	}
+	return fmt.Sprintf("%s: %s", e.Message, e.cause)
+}
+
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+// Is match errors of the same code, so wrapped copies still match their template
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// Wrap give copy of the error caused by cause
+func (e *Error) Wrap(cause error) *Error {
+	wrapped := *e
+	wrapped.cause = cause
+	return &wrapped
+}
+
+// WithMessage give copy of the error showing message to the user
+func (e *Error) WithMessage(message string) *Error {
+	wrapped := *e
+	wrapped.Message = message
+	return &wrapped
+}
+
+// From give app error of err: the one within its chain, the one mapped from postgres error,
+// timeout or unavailable error of exceeded deadline or unreachable store, otherwise internal error
+// which hides err from the user
+func From(err error) *Error {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+	if mapped, ok := fromPostgres(err); ok {
+		return mapped
+	}
+	if mapped, ok := fromTransient(err); ok {
+		return mapped
+	}
+	return ErrInternal.Wrap(err)
+}
@@ -0,0 +1,116 @@
+package apperr_test
+
+import (
+	"context"
+	"gotinder/apperr"
+	"net"
+	"net/http"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFrom(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		code    apperr.Code
+		status  int
+		message string
+	}{
+		{
+			name:    "app error within chain",
+			err:     errors.Wrap(apperr.ErrForbidden.WithMessage("not yours"), "failed to find"),
+			code:    apperr.CodeForbidden,
+			status:  http.StatusForbidden,
+			message: "not yours",
+		},
+		{
+			name:    "unique violation",
+			err:     errors.Wrap(&pq.Error{Code: "23505", Message: "duplicate key value violates unique constraint"}, "failed to record"),
+			code:    apperr.CodeConflict,
+			status:  http.StatusConflict,
+			message: "resource already exists",
+		},
+		{
+			name:    "foreign key violation",
+			err:     &pq.Error{Code: "23503", Message: "violates foreign key constraint"},
+			code:    apperr.CodeNotFound,
+			status:  http.StatusNotFound,
+			message: "referenced resource not found",
+		},
+		{
+			name:    "statement timeout",
+			err:     errors.Wrap(&pq.Error{Code: "57014", Message: "canceling statement due to statement timeout"}, "failed to find"),
+			code:    apperr.CodeTimeout,
+			status:  http.StatusGatewayTimeout,
+			message: "request timed out",
+		},
+		{
+			name:    "postgres shutting down",
+			err:     &pq.Error{Code: "57P01", Message: "terminating connection due to administrator command"},
+			code:    apperr.CodeUnavailable,
+			status:  http.StatusServiceUnavailable,
+			message: "service temporarily unavailable",
+		},
+		{
+			name:    "deadline exceeded",
+			err:     errors.Wrap(context.DeadlineExceeded, "failed to count quota"),
+			code:    apperr.CodeTimeout,
+			status:  http.StatusGatewayTimeout,
+			message: "request timed out",
+		},
+		{
+			name:    "network timeout",
+			err:     &net.OpError{Op: "read", Net: "tcp", Err: os.ErrDeadlineExceeded},
+			code:    apperr.CodeTimeout,
+			status:  http.StatusGatewayTimeout,
+			message: "request timed out",
+		},
+		{
+			name:    "connection refused",
+			err:     errors.Wrap(&net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}, "failed to publish"),
+			code:    apperr.CodeUnavailable,
+			status:  http.StatusServiceUnavailable,
+			message: "service temporarily unavailable",
+		},
+		{
+			name:    "redis pool exhausted",
+			err:     redis.ErrPoolExhausted,
+			code:    apperr.CodeUnavailable,
+			status:  http.StatusServiceUnavailable,
+			message: "service temporarily unavailable",
+		},
+		{
+			name:    "unmapped error is hidden",
+			err:     errors.New("dial tcp: connection refused"),
+			code:    apperr.CodeInternal,
+			status:  http.StatusInternalServerError,
+			message: "internal server error",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			appErr := apperr.From(tt.err)
+
+			assert.Equal(t, tt.code, appErr.Code)
+			assert.Equal(t, tt.status, appErr.Status)
+			assert.Equal(t, tt.message, appErr.Message)
+		})
+	}
+}
+
+func TestError_Wrap(t *testing.T) {
+	cause := errors.New("email already registered")
+	err := apperr.ErrConflict.Wrap(cause)
+
+	assert.ErrorIs(t, err, apperr.ErrConflict)
+	assert.ErrorIs(t, err, cause)
+	assert.NotErrorIs(t, err, apperr.ErrNotFound)
+	assert.Equal(t, "resource already exists: email already registered", err.Error())
+}
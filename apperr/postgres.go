@@ -0,0 +1,42 @@
+package apperr
+
+import (
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+// postgresErrs map postgresql error codes to errors exposed to clients,
+// see https://www.postgresql.org/docs/current/errcodes-appendix.html
+var postgresErrs = map[pq.ErrorCode]*Error{
+	"23505": ErrConflict,
+	"23503": ErrReferenceNotFound,
+	"23514": ErrInvalidRequest,
+	"22P02": ErrInvalidRequest,
+	// query_canceled, raised when statement_timeout is reached
+	"57014": ErrTimeout,
+}
+
+// postgresClassErrs map classes of postgresql error codes not mapped by their code to errors exposed to clients
+var postgresClassErrs = map[pq.ErrorClass]*Error{
+	// connection exception
+	"08": ErrUnavailable,
+	// insufficient resources, e.g. too many connections
+	"53": ErrUnavailable,
+	// operator intervention, e.g. server shutting down
+	"57": ErrUnavailable,
+}
+
+// fromPostgres give error exposed to clients of postgresql error within err chain
+func fromPostgres(err error) (*Error, bool) {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return nil, false
+	}
+	if mapped, ok := postgresErrs[pqErr.Code]; ok {
+		return mapped.Wrap(err), true
+	}
+	if mapped, ok := postgresClassErrs[pqErr.Code.Class()]; ok {
+		return mapped.Wrap(err), true
+	}
+	return nil, false
+}
@@ -0,0 +1,41 @@
+package apperr
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"net"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/pkg/errors"
+)
+
+// unavailableErrs are errors of stores which can't be reached or have no connection to spare
+var unavailableErrs = []error{
+	context.Canceled,
+	driver.ErrBadConn,
+	sql.ErrConnDone,
+	redis.ErrPoolExhausted,
+}
+
+// fromTransient give timeout error of exceeded deadline within err chain, or unavailable error of store
+// which can't be reached, so clients know the request may succeed when retried
+func fromTransient(err error) (*Error, bool) {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrTimeout.Wrap(err), true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return ErrTimeout.Wrap(err), true
+	}
+	for _, target := range unavailableErrs {
+		if errors.Is(err, target) {
+			return ErrUnavailable.Wrap(err), true
+		}
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return ErrUnavailable.Wrap(err), true
+	}
+	return nil, false
+}
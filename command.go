@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"gotinder/app"
+	"gotinder/coupon"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// runCommand run CLI subcommand instead of the server until ctx is done,
+// e.g. "gotinder coupons generate -campaign=promo -count=100"
+func runCommand(ctx context.Context, a *app.App, args []string) error {
+	if len(args) >= 2 && args[0] == "coupons" && args[1] == "generate" {
+		return generateCoupons(ctx, a, args[2:])
+	}
+	if len(args) >= 2 && args[0] == "nearby" && args[1] == "reindex" {
+		return reindexNearby(ctx, a)
+	}
+	return errors.Errorf("unknown command %v", args)
+}
+
+// reindexNearby put latest location of every user on configured nearby index
+func reindexNearby(ctx context.Context, a *app.App) error {
+	indexed, err := a.Services.Locations.RebuildNearbyIndex(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to rebuild nearby index")
+	}
+	slog.Info("nearby index rebuilt", "index", a.Nearby.Name(), "users", indexed)
+	return nil
+}
+
+// generateCoupons generate coupons of a campaign and export the generated ones as CSV
+func generateCoupons(ctx context.Context, a *app.App, args []string) error {
+	var batch coupon.Batch
+	var validFor time.Duration
+	var output string
+	fs := flag.NewFlagSet("coupons generate", flag.ContinueOnError)
+	fs.StringVar(&batch.Campaign, "campaign", "", "campaign shared by generated coupons")
+	fs.IntVar(&batch.Count, "count", 0, "number of coupons to generate")
+	fs.Int64Var(&batch.DurationInSecond, "duration", 30*24*60*60, "subscription duration granted by each coupon, in second")
+	fs.DurationVar(&validFor, "valid-for", 30*24*time.Hour, "how long coupons can be applied from now")
+	fs.Int64Var(&batch.MaxRedemptionsPerUser, "max-redemptions-per-user", 1, "redemption limit per user of each coupon")
+	fs.StringVar(&batch.PlanTier, "plan", "", "tier of plan granted by coupons, premium when empty")
+	fs.StringVar(&output, "out", "", "CSV output file of the generated coupons only, stdout when empty")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	batch.ValidUntil = time.Now().Add(validFor).Unix()
+
+	codes, err := a.Services.Coupons.Generate(ctx, batch)
+	if err != nil {
+		return errors.Wrap(err, "failed to generate coupons")
+	}
+	slog.Info("coupons generated", "campaign", batch.Campaign, "count", len(codes))
+
+	var w io.Writer = os.Stdout
+	if output != "" {
+		f, err := os.Create(output)
+		if err != nil {
+			return errors.Wrap(err, "failed to create output file")
+		}
+		defer f.Close()
+		w = f
+	}
+
+	// only the codes just generated, earlier codes of the campaign are exported by "/coupons/campaigns/:campaign/export"
+	if err := a.Services.Coupons.ExportCampaign(ctx, w, batch.Campaign, codes...); err != nil {
+		return errors.Wrap(err, "failed to export coupons")
+	}
+	if output != "" {
+		slog.Info("coupons exported", "file", output)
+	}
+	return nil
+}
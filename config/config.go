@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/spf13/viper"
@@ -27,18 +28,33 @@ type (
 	Configuration struct {
 		App struct {
 			Rest AppConfiguration
+			Job  JobConfiguration
 		}
 		Store struct {
 			Postgresql PGConfiguration
 			Migration  MigrationConfiguration
 			Redis      RedisConfiguration
 		}
+		Payment   PaymentConfiguration
+		Discovery DiscoveryConfiguration
+		Tracing   TracingConfiguration
 	}
 
 	AppConfiguration struct {
-		Enabled bool
-		Name    string
-		Port    int
+		Enabled        bool
+		Name           string
+		Port           int
+		ShutdownDelay  time.Duration
+		RequestTimeout time.Duration
+	}
+
+	JobConfiguration struct {
+		Enabled                    bool
+		SubscriptionExpiryInterval time.Duration
+		SubscriptionGracePeriod    time.Duration
+		SubscriptionNoticePeriod   time.Duration
+		LocationHistoryInterval    time.Duration
+		LocationHistoryRetention   time.Duration
 	}
 
 	StoreConfiguration struct {
@@ -52,16 +68,49 @@ type (
 		Name               string
 		User               string
 		SSLMode            string
+		StatementTimeout   time.Duration
 	}
 
 	RedisConfiguration struct {
 		StoreConfiguration `mapstructure:",squash"`
 		Database           int
+		Timeout            time.Duration
 	}
 
 	MigrationConfiguration struct {
 		TableName string
 	}
+
+	DiscoveryConfiguration struct {
+		NearbyIndex string
+		Fuzzing     FuzzingConfiguration
+		Throttling  ThrottlingConfiguration
+	}
+
+	FuzzingConfiguration struct {
+		JitterInMeter      float64
+		MinDistanceInMeter float64
+		BucketInMeter      float64
+		Secret             string
+	}
+
+	ThrottlingConfiguration struct {
+		Interval           time.Duration
+		MinDistanceInMeter float64
+		MaxUpdates         int
+	}
+
+	PaymentConfiguration struct {
+		Provider      string
+		WebhookSecret string
+	}
+
+	TracingConfiguration struct {
+		Exporter    string
+		Endpoint    string
+		Insecure    bool
+		SampleRatio float64
+	}
 )
 
 func New() *Configuration {
@@ -109,6 +158,10 @@ func (a AppConfiguration) IsDebug() bool {
 	return appEnv != productionEnv
 }
 
+func (a AppConfiguration) IsLocal() bool {
+	return appEnv == "" || appEnv == localEnv
+}
+
 func (r RedisConfiguration) GetConfigString() string {
 	return fmt.Sprintf("%s:%v", r.Host, r.Port)
 }
@@ -0,0 +1,152 @@
+package coupon
+
+import (
+	"context"
+	"gotinder/pagination"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrRevoked          = errors.New("coupon revoked")
+	ErrExpired          = errors.New("coupon expired")
+	ErrExhausted        = errors.New("coupon redemption limit reached")
+	ErrUserExhausted    = errors.New("coupon redemption limit per user reached")
+	ErrAlreadyUsed      = errors.New("coupon not found or already applied")
+	ErrCouponNotFound   = errors.New("coupon not found")
+	ErrCampaignNotFound = errors.New("campaign not found")
+	ErrInvalidBatch     = errors.New("invalid coupon batch")
+
+	// ruleErrs are errors caused by coupon lifecycle rules
+	ruleErrs = []error{ErrRevoked, ErrExpired, ErrExhausted, ErrUserExhausted}
+)
+
+type (
+	// Coupon is a type of coupon along with number of its redemptions
+	Coupon struct {
+		ID                    string  `json:"id"`
+		CreatedAt             int64   `json:"created_at"`
+		Code                  string  `json:"code"`
+		DurationInSecond      int64   `json:"duration_in_second"`
+		ValidUntil            int64   `json:"valid_until"`
+		MaxRedemptions        *int64  `json:"max_redemptions"`
+		MaxRedemptionsPerUser int64   `json:"max_redemptions_per_user"`
+		RevokedAt             *int64  `json:"revoked_at"`
+		Campaign              *string `json:"campaign"`
+		PlanTier              string  `json:"plan_tier"`
+		IsPublic              bool    `json:"is_public"`
+		Redemptions           int64   `json:"redemptions"`
+	}
+
+	// NewCoupon is a type of coupon to be created, empty PlanTier means premium
+	NewCoupon struct {
+		Code                  string
+		DurationInSecond      int64
+		ValidUntil            int64
+		MaxRedemptions        *int64
+		MaxRedemptionsPerUser int64
+		Campaign              string
+		PlanTier              string
+		IsPublic              bool
+	}
+
+	// Batch is a type of bulk coupon generation spec, empty PlanTier means premium
+	Batch struct {
+		Campaign              string
+		Count                 int
+		DurationInSecond      int64
+		ValidUntil            int64
+		MaxRedemptionsPerUser int64
+		PlanTier              string
+	}
+
+	// UserCoupon is a type of coupon applied to a user and not yet used
+	UserCoupon struct {
+		ID               string
+		CouponID         string
+		DurationInSecond int64
+	}
+
+	// CampaignStats is a type of redemption statistics of a campaign
+	CampaignStats struct {
+		Campaign      string `json:"campaign"`
+		TotalCodes    int64  `json:"total_codes"`
+		RevokedCodes  int64  `json:"revoked_codes"`
+		RedeemedCodes int64  `json:"redeemed_codes"`
+		Redemptions   int64  `json:"redemptions"`
+		Pending       int64  `json:"pending"`
+	}
+
+	// Repository is an interface of coupon storage,
+	// Lock methods hold the row until the transaction ends so concurrent redemptions are counted one after another
+	Repository interface {
+		Create(ctx context.Context, c NewCoupon, planID uuid.NullUUID) error
+		// Find give coupons after cursor, newest first, optionally filtered by campaign
+		Find(ctx context.Context, campaign string, after *pagination.Cursor, limit int) ([]Coupon, error)
+		FindByID(ctx context.Context, id string) (Coupon, error)
+		// EachByCampaign call fn on every coupon of the campaign, oldest first
+		EachByCampaign(ctx context.Context, campaign string, fn func(Coupon) error) error
+		// Revoke stop coupon from being applied or redeemed, false means there is no such coupon
+		Revoke(ctx context.Context, id string) (bool, error)
+		LockByCode(ctx context.Context, code string, publicOnly bool) (Coupon, error)
+		LockByID(ctx context.Context, id string) (Coupon, error)
+		// CountRedemptions count redemptions of the coupon in total and by the user,
+		// includePending count applied but not yet used coupons too
+		CountRedemptions(ctx context.Context, couponID, userID string, includePending bool) (total int64, byUser int64, err error)
+		// CreateUserCoupon apply coupon to the user, non nil usedAt means it is redeemed right away
+		CreateUserCoupon(ctx context.Context, userID, couponID string, usedAt *int64) error
+		// LockUserCoupon find and lock unused coupon of the code applied to the user along with the coupon itself
+		LockUserCoupon(ctx context.Context, code, userID string) (UserCoupon, error)
+		// MarkUsed mark coupon applied to the user as used, false means it was already used
+		MarkUsed(ctx context.Context, userCouponID string) (bool, error)
+		// InsertCodes insert coupons of the codes, giving codes which are actually inserted.
+		// colliding codes are skipped
+		InsertCodes(ctx context.Context, batch Batch, planID uuid.NullUUID, codes []string) ([]string, error)
+		// CampaignStats give redemption statistics of the campaign
+		CampaignStats(ctx context.Context, campaign string) (CampaignStats, error)
+	}
+)
+
+// IsRuleErr check if error is caused by coupon lifecycle rules
+func IsRuleErr(err error) bool {
+	for _, ruleErr := range ruleErrs {
+		if errors.Is(err, ruleErr) {
+			return true
+		}
+	}
+	return false
+}
+
+// validate check the batch can be generated
+func (b Batch) validate() error {
+	if b.Campaign == "" {
+		return errors.Wrap(ErrInvalidBatch, "campaign is required")
+	}
+	if b.Count < 1 || b.Count > maxBatchCount {
+		return errors.Wrapf(ErrInvalidBatch, "count must be between 1 and %d", maxBatchCount)
+	}
+	if b.DurationInSecond < 0 || b.ValidUntil < 0 || b.MaxRedemptionsPerUser < 0 {
+		return errors.Wrap(ErrInvalidBatch, "duration, validity and redemption limit can't be negative")
+	}
+	return nil
+}
+
+// redeemable check lifecycle rules of the coupon given its redemptions,
+// includePending means counts include applied but not yet used coupons, which is when expiry applies
+func (c Coupon) redeemable(total, byUser int64, includePending bool, now time.Time) error {
+	if c.RevokedAt != nil {
+		return ErrRevoked
+	}
+	if includePending && now.After(time.Unix(c.ValidUntil, 0)) {
+		return ErrExpired
+	}
+	if c.MaxRedemptions != nil && total >= *c.MaxRedemptions {
+		return ErrExhausted
+	}
+	if byUser >= c.MaxRedemptionsPerUser {
+		return ErrUserExhausted
+	}
+	return nil
+}
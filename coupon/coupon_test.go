@@ -0,0 +1,58 @@
+package coupon
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCoupon_redeemable(t *testing.T) {
+	now := time.Now()
+	revokedAt := now.Unix()
+	maxRedemptions := int64(10)
+	coupon := Coupon{
+		ValidUntil:            now.Add(time.Hour).Unix(),
+		MaxRedemptions:        &maxRedemptions,
+		MaxRedemptionsPerUser: 1,
+	}
+
+	assert.Nil(t, coupon.redeemable(0, 0, true, now))
+	assert.ErrorIs(t, coupon.redeemable(10, 0, true, now), ErrExhausted)
+	assert.ErrorIs(t, coupon.redeemable(1, 1, true, now), ErrUserExhausted)
+
+	// expiry only stops coupons from being applied, applied coupons can still be used
+	assert.ErrorIs(t, coupon.redeemable(0, 0, true, now.Add(2*time.Hour)), ErrExpired)
+	assert.Nil(t, coupon.redeemable(0, 0, false, now.Add(2*time.Hour)))
+
+	unlimited := coupon
+	unlimited.MaxRedemptions = nil
+	assert.Nil(t, unlimited.redeemable(1000, 0, true, now))
+
+	revoked := coupon
+	revoked.RevokedAt = &revokedAt
+	assert.ErrorIs(t, revoked.redeemable(0, 0, false, now), ErrRevoked)
+}
+
+func TestIsRuleErr(t *testing.T) {
+	assert.True(t, IsRuleErr(ErrRevoked))
+	assert.True(t, IsRuleErr(errors.Wrap(ErrUserExhausted, "failed to redeem")))
+	assert.False(t, IsRuleErr(ErrCouponNotFound))
+	assert.False(t, IsRuleErr(ErrAlreadyUsed))
+}
+
+func TestBatch_validate(t *testing.T) {
+	batch := Batch{Campaign: "promo", Count: 10, DurationInSecond: 60, ValidUntil: 60}
+	assert.Nil(t, batch.validate())
+
+	noCampaign := batch
+	noCampaign.Campaign = ""
+	assert.ErrorIs(t, noCampaign.validate(), ErrInvalidBatch)
+
+	for _, count := range []int{0, maxBatchCount + 1} {
+		invalid := batch
+		invalid.Count = count
+		assert.ErrorIs(t, invalid.validate(), ErrInvalidBatch)
+	}
+}
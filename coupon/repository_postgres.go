@@ -0,0 +1,310 @@
+package coupon
+
+import (
+	"context"
+	"database/sql"
+	"gotinder/infra"
+	"gotinder/pagination"
+	"gotinder/subscription"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// PostgresRepository store coupons on postgresql
+type PostgresRepository struct {
+	db *sql.DB
+}
+
+var _ Repository = &PostgresRepository{}
+
+func NewPostgresRepository(db *sql.DB) *PostgresRepository {
+	return &PostgresRepository{db: db}
+}
+
+var psql = sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+func (r *PostgresRepository) Create(ctx context.Context, c NewCoupon, planID uuid.NullUUID) error {
+	_, err := psql.
+		Insert("coupons").
+		Columns("code", "duration_in_second", "valid_until", "max_redemptions", "max_redemptions_per_user", "campaign", "plan_id", "is_public").
+		Values(
+			c.Code,
+			c.DurationInSecond,
+			c.ValidUntil,
+			c.MaxRedemptions,
+			c.MaxRedemptionsPerUser,
+			sql.NullString{String: c.Campaign, Valid: c.Campaign != ""},
+			planID,
+			c.IsPublic,
+		).
+		RunWith(infra.PgRunner(ctx, r.db)).
+		ExecContext(ctx)
+	return err
+}
+
+func (r *PostgresRepository) Find(ctx context.Context, campaign string, after *pagination.Cursor, limit int) ([]Coupon, error) {
+	query := selectCoupons()
+	if campaign != "" {
+		query = query.Where("coupons.campaign = ?", campaign)
+	}
+	rows, err := pagination.Apply(query, after, "coupons.created_at", "coupons.id", limit).
+		RunWith(infra.PgRunner(ctx, r.db)).
+		QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	coupons := make([]Coupon, 0)
+	for rows.Next() {
+		coupon, err := scanCoupon(rows)
+		if err != nil {
+			return nil, err
+		}
+		coupons = append(coupons, coupon)
+	}
+	return coupons, rows.Err()
+}
+
+func (r *PostgresRepository) FindByID(ctx context.Context, id string) (Coupon, error) {
+	return r.findOne(ctx, selectCoupons().Where("coupons.id = ?", id))
+}
+
+func (r *PostgresRepository) EachByCampaign(ctx context.Context, campaign string, fn func(Coupon) error) error {
+	rows, err := selectCoupons().
+		Where("coupons.campaign = ?", campaign).
+		OrderBy("coupons.created_at ASC", "coupons.code ASC").
+		RunWith(infra.PgRunner(ctx, r.db)).
+		QueryContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		coupon, err := scanCoupon(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(coupon); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (r *PostgresRepository) Revoke(ctx context.Context, id string) (bool, error) {
+	result, err := psql.
+		Update("coupons").
+		Set("revoked_at", sq.Expr("COALESCE(revoked_at, ?)", time.Now().Unix())).
+		Where("id = ?", id).
+		RunWith(infra.PgRunner(ctx, r.db)).
+		ExecContext(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func (r *PostgresRepository) LockByCode(ctx context.Context, code string, publicOnly bool) (Coupon, error) {
+	where := sq.Eq{"coupons.code": code}
+	if publicOnly {
+		where["coupons.is_public"] = true
+	}
+	return r.findOne(ctx, selectCoupons().Where(where).Suffix("FOR UPDATE OF coupons"))
+}
+
+func (r *PostgresRepository) LockByID(ctx context.Context, id string) (Coupon, error) {
+	return r.findOne(ctx, selectCoupons().Where("coupons.id = ?", id).Suffix("FOR UPDATE OF coupons"))
+}
+
+func (r *PostgresRepository) CountRedemptions(ctx context.Context, couponID, userID string, includePending bool) (int64, int64, error) {
+	countRedemptions := psql.
+		Select("COUNT(*)").
+		Column(sq.Expr("COUNT(*) FILTER (WHERE user_id = ?)", userID)).
+		From("user_coupons").
+		Where("coupon_id = ?", couponID)
+	if !includePending {
+		countRedemptions = countRedemptions.Where("used_at IS NOT NULL")
+	}
+
+	var total, byUser int64
+	err := countRedemptions.
+		RunWith(infra.PgRunner(ctx, r.db)).
+		QueryRowContext(ctx).
+		Scan(&total, &byUser)
+	return total, byUser, err
+}
+
+func (r *PostgresRepository) CreateUserCoupon(ctx context.Context, userID, couponID string, usedAt *int64) error {
+	_, err := psql.
+		Insert("user_coupons").
+		Columns("user_id", "coupon_id", "used_at").
+		Values(userID, couponID, usedAt).
+		RunWith(infra.PgRunner(ctx, r.db)).
+		ExecContext(ctx)
+	return err
+}
+
+func (r *PostgresRepository) LockUserCoupon(ctx context.Context, code, userID string) (UserCoupon, error) {
+	var userCoupon UserCoupon
+	err := psql.
+		Select("user_coupons.id", "coupons.id", "coupons.duration_in_second").
+		From("user_coupons").
+		InnerJoin("coupons ON coupons.id = user_coupons.coupon_id").
+		Where("user_coupons.user_id = ?", userID).
+		Where("coupons.code = ?", code).
+		Where("user_coupons.used_at IS NULL").
+		Suffix("FOR UPDATE OF user_coupons, coupons").
+		RunWith(infra.PgRunner(ctx, r.db)).
+		QueryRowContext(ctx).
+		Scan(&userCoupon.ID, &userCoupon.CouponID, &userCoupon.DurationInSecond)
+	if errors.Is(err, sql.ErrNoRows) {
+		return userCoupon, ErrAlreadyUsed
+	}
+	return userCoupon, err
+}
+
+func (r *PostgresRepository) MarkUsed(ctx context.Context, userCouponID string) (bool, error) {
+	result, err := psql.
+		Update("user_coupons").
+		Set("used_at", time.Now().Unix()).
+		Where("id = ?", userCouponID).
+		Where("used_at IS NULL").
+		RunWith(infra.PgRunner(ctx, r.db)).
+		ExecContext(ctx)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func (r *PostgresRepository) InsertCodes(ctx context.Context, batch Batch, planID uuid.NullUUID, codes []string) ([]string, error) {
+	insert := psql.
+		Insert("coupons").
+		Columns("code", "duration_in_second", "valid_until", "max_redemptions_per_user", "campaign", "plan_id").
+		Suffix("ON CONFLICT DO NOTHING RETURNING code")
+	for _, code := range codes {
+		insert = insert.Values(code, batch.DurationInSecond, batch.ValidUntil, batch.MaxRedemptionsPerUser, batch.Campaign, planID)
+	}
+
+	rows, err := insert.RunWith(infra.PgRunner(ctx, r.db)).QueryContext(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to insert coupons")
+	}
+	defer rows.Close()
+
+	inserted := make([]string, 0, len(codes))
+	for rows.Next() {
+		var code string
+		if err := rows.Scan(&code); err != nil {
+			return nil, err
+		}
+		inserted = append(inserted, code)
+	}
+	return inserted, rows.Err()
+}
+
+func (r *PostgresRepository) CampaignStats(ctx context.Context, campaign string) (CampaignStats, error) {
+	stats := CampaignStats{Campaign: campaign}
+	if err := psql.
+		Select("COUNT(*)", "COUNT(*) FILTER (WHERE revoked_at IS NOT NULL)").
+		From("coupons").
+		Where("campaign = ?", campaign).
+		RunWith(infra.PgRunner(ctx, r.db)).
+		QueryRowContext(ctx).
+		Scan(&stats.TotalCodes, &stats.RevokedCodes); err != nil {
+		return stats, err
+	}
+
+	if err := psql.
+		Select(
+			"COUNT(DISTINCT user_coupons.coupon_id) FILTER (WHERE user_coupons.used_at IS NOT NULL)",
+			"COUNT(*) FILTER (WHERE user_coupons.used_at IS NOT NULL)",
+			"COUNT(*) FILTER (WHERE user_coupons.used_at IS NULL)",
+		).
+		From("user_coupons").
+		InnerJoin("coupons ON coupons.id = user_coupons.coupon_id").
+		Where("coupons.campaign = ?", campaign).
+		RunWith(infra.PgRunner(ctx, r.db)).
+		QueryRowContext(ctx).
+		Scan(&stats.RedeemedCodes, &stats.Redemptions, &stats.Pending); err != nil {
+		return stats, err
+	}
+	return stats, nil
+}
+
+// findOne run query selecting one coupon, missing coupon is reported as ErrCouponNotFound
+func (r *PostgresRepository) findOne(ctx context.Context, query sq.SelectBuilder) (Coupon, error) {
+	coupon, err := scanCoupon(query.RunWith(infra.PgRunner(ctx, r.db)).QueryRowContext(ctx))
+	if errors.Is(err, sql.ErrNoRows) {
+		return coupon, ErrCouponNotFound
+	}
+	return coupon, err
+}
+
+// selectCoupons build query to select coupon columns in the order expected by scanCoupon
+func selectCoupons() sq.SelectBuilder {
+	return psql.
+		Select(
+			"coupons.id",
+			"coupons.created_at",
+			"coupons.code",
+			"coupons.duration_in_second",
+			"coupons.valid_until",
+			"coupons.max_redemptions",
+			"coupons.max_redemptions_per_user",
+			"coupons.revoked_at",
+			"coupons.campaign",
+			"coupons.is_public",
+		).
+		Column(sq.Expr("COALESCE(plans.tier, ?)", subscription.PlanPremium)).
+		Column("(SELECT COUNT(*) FROM user_coupons WHERE user_coupons.coupon_id = coupons.id AND user_coupons.used_at IS NOT NULL)").
+		From("coupons").
+		LeftJoin("plans ON plans.id = coupons.plan_id")
+}
+
+// scanCoupon read coupon columns selected by selectCoupons
+func scanCoupon(row sq.RowScanner) (Coupon, error) {
+	var coupon Coupon
+	var maxRedemptions, revokedAt sql.NullInt64
+	var campaign sql.NullString
+	if err := row.Scan(
+		&coupon.ID,
+		&coupon.CreatedAt,
+		&coupon.Code,
+		&coupon.DurationInSecond,
+		&coupon.ValidUntil,
+		&maxRedemptions,
+		&coupon.MaxRedemptionsPerUser,
+		&revokedAt,
+		&campaign,
+		&coupon.IsPublic,
+		&coupon.PlanTier,
+		&coupon.Redemptions,
+	); err != nil {
+		return coupon, err
+	}
+	if maxRedemptions.Valid {
+		coupon.MaxRedemptions = &maxRedemptions.Int64
+	}
+	if revokedAt.Valid {
+		coupon.RevokedAt = &revokedAt.Int64
+	}
+	if campaign.Valid {
+		coupon.Campaign = &campaign.String
+	}
+	return coupon, nil
+}
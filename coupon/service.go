@@ -0,0 +1,293 @@
+package coupon
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/csv"
+	"gotinder/infra"
+	"gotinder/pagination"
+	"gotinder/subscription"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+const (
+	// codeAlphabet is alphanumeric without look-alike characters.
+	// its length divides 256, so picking by random byte is not biased
+	codeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	codeLength   = 10
+	batchSize    = 500
+	// maxBatchCount is the most coupons generated at once
+	maxBatchCount = 100000
+)
+
+// Service is a type of coupon business logic
+type Service struct {
+	repo          Repository
+	tx            infra.Transactor
+	subscriptions *subscription.Service
+}
+
+func NewService(repo Repository, tx infra.Transactor, subscriptions *subscription.Service) *Service {
+	return &Service{
+		repo:          repo,
+		tx:            tx,
+		subscriptions: subscriptions,
+	}
+}
+
+// Create create the coupon, redeemable at most once per user unless told otherwise
+func (s *Service) Create(ctx context.Context, c NewCoupon) error {
+	planID, err := s.planID(ctx, c.PlanTier)
+	if err != nil {
+		return err
+	}
+	if c.MaxRedemptionsPerUser == 0 {
+		c.MaxRedemptionsPerUser = 1
+	}
+	return s.repo.Create(ctx, c, planID)
+}
+
+// Apply apply targeted coupon to the user, redeemed later by the user through RedeemApplied
+func (s *Service) Apply(ctx context.Context, code, userID string) error {
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		coupon, err := s.repo.LockByCode(ctx, code, false)
+		if err != nil {
+			return err
+		}
+
+		if err := s.ensureRedeemable(ctx, coupon, userID, true); err != nil {
+			return err
+		}
+
+		return s.repo.CreateUserCoupon(ctx, userID, coupon.ID, nil)
+	})
+}
+
+// Redeem redeem public campaign coupon for the user in one step, giving when their subscription ends.
+// coupon and user rows are locked in this order before any change, like RedeemApplied. targeted coupons are reported as not found, so their codes can't be probed
+func (s *Service) Redeem(ctx context.Context, code, userID string) (time.Time, error) {
+	var subscribeUntil time.Time
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		coupon, err := s.repo.LockByCode(ctx, code, true)
+		if err != nil {
+			return err
+		}
+		if err := s.subscriptions.LockSubscriber(ctx, userID); err != nil {
+			return err
+		}
+
+		if err := s.ensureRedeemable(ctx, coupon, userID, true); err != nil {
+			return err
+		}
+
+		usedAt := time.Now().Unix()
+		if err := s.repo.CreateUserCoupon(ctx, userID, coupon.ID, &usedAt); err != nil {
+			return err
+		}
+
+		subscribeUntil, err = s.subscriptions.Extend(ctx, userID, coupon.PlanTier, time.Duration(coupon.DurationInSecond)*time.Second)
+		return err
+	})
+	return subscribeUntil, err
+}
+
+// RedeemApplied redeem the coupon applied to the user and extend their subscription within one transaction.
+// coupon and user rows are locked in this order before any change, so concurrent redemptions are applied
+// one after another
+func (s *Service) RedeemApplied(ctx context.Context, code, userID string) error {
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		userCoupon, err := s.repo.LockUserCoupon(ctx, code, userID)
+		if err != nil {
+			return err
+		}
+
+		coupon, err := s.repo.LockByID(ctx, userCoupon.CouponID)
+		if err != nil {
+			return err
+		}
+		if err := s.subscriptions.LockSubscriber(ctx, userID); err != nil {
+			return err
+		}
+
+		if err := s.ensureRedeemable(ctx, coupon, userID, false); err != nil {
+			return err
+		}
+
+		used, err := s.repo.MarkUsed(ctx, userCoupon.ID)
+		if err != nil {
+			return err
+		}
+		if !used {
+			return ErrAlreadyUsed
+		}
+
+		_, err = s.subscriptions.Extend(ctx, userID, coupon.PlanTier, time.Duration(userCoupon.DurationInSecond)*time.Second)
+		return err
+	})
+}
+
+// Find give page of coupons, optionally filtered by campaign, along with cursor of next page
+func (s *Service) Find(ctx context.Context, campaign string, after *pagination.Cursor, limit int) ([]Coupon, string, error) {
+	coupons, err := s.repo.Find(ctx, campaign, after, limit)
+	if err != nil {
+		return nil, "", err
+	}
+	coupons, next := pagination.Next(coupons, limit, func(coupon Coupon) pagination.Cursor {
+		return pagination.Cursor{CreatedAt: coupon.CreatedAt, ID: coupon.ID}
+	})
+	return coupons, next, nil
+}
+
+// FindByID give detail of a coupon
+func (s *Service) FindByID(ctx context.Context, id string) (Coupon, error) {
+	return s.repo.FindByID(ctx, id)
+}
+
+// Revoke stop coupon from being applied or redeemed, already granted subscriptions are kept
+func (s *Service) Revoke(ctx context.Context, id string) error {
+	revoked, err := s.repo.Revoke(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !revoked {
+		return ErrCouponNotFound
+	}
+	return nil
+}
+
+// Generate insert random unique coupons of the campaign in batches within one transaction,
+// giving the generated codes
+func (s *Service) Generate(ctx context.Context, batch Batch) ([]string, error) {
+	if err := batch.validate(); err != nil {
+		return nil, err
+	}
+	if batch.MaxRedemptionsPerUser == 0 {
+		batch.MaxRedemptionsPerUser = 1
+	}
+
+	planID, err := s.planID(ctx, batch.PlanTier)
+	if err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, batch.Count)
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		for len(codes) < batch.Count {
+			candidates := make([]string, min(batchSize, batch.Count-len(codes)))
+			for i := range candidates {
+				code, err := randomCode()
+				if err != nil {
+					return err
+				}
+				candidates[i] = code
+			}
+
+			// colliding codes are skipped by the repository and regenerated on next round
+			inserted, err := s.repo.InsertCodes(ctx, batch, planID, candidates)
+			if err != nil {
+				return err
+			}
+			codes = append(codes, inserted...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// ExportCampaign write coupons of the campaign as CSV with header, oldest first.
+// only coupons of the codes are written when any is given, e.g. the ones just generated.
+// campaign without coupons is reported as ErrCampaignNotFound before anything is written
+func (s *Service) ExportCampaign(ctx context.Context, w io.Writer, campaign string, codes ...string) error {
+	only := make(map[string]bool, len(codes))
+	for _, code := range codes {
+		only[code] = true
+	}
+
+	csvWriter := csv.NewWriter(w)
+	found := false
+	if err := s.repo.EachByCampaign(ctx, campaign, func(c Coupon) error {
+		if !found {
+			found = true
+			if err := csvWriter.Write([]string{"code", "duration_in_second", "valid_until", "plan_tier", "redemptions", "revoked_at"}); err != nil {
+				return err
+			}
+		}
+		if len(only) > 0 && !only[c.Code] {
+			return nil
+		}
+		var revokedAt string
+		if c.RevokedAt != nil {
+			revokedAt = strconv.FormatInt(*c.RevokedAt, 10)
+		}
+		return csvWriter.Write([]string{
+			c.Code,
+			strconv.FormatInt(c.DurationInSecond, 10),
+			strconv.FormatInt(c.ValidUntil, 10),
+			c.PlanTier,
+			strconv.FormatInt(c.Redemptions, 10),
+			revokedAt,
+		})
+	}); err != nil {
+		return err
+	}
+	if !found {
+		return ErrCampaignNotFound
+	}
+
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
+
+// CampaignStats give redemption statistics of a campaign
+func (s *Service) CampaignStats(ctx context.Context, campaign string) (CampaignStats, error) {
+	stats, err := s.repo.CampaignStats(ctx, campaign)
+	if err != nil {
+		return stats, err
+	}
+	if stats.TotalCodes == 0 {
+		return stats, ErrCampaignNotFound
+	}
+	return stats, nil
+}
+
+// ensureRedeemable check lifecycle rules of locked coupon for the user,
+// includePending count applied but not yet used coupons toward the limits
+func (s *Service) ensureRedeemable(ctx context.Context, coupon Coupon, userID string, includePending bool) error {
+	total, byUser, err := s.repo.CountRedemptions(ctx, coupon.ID, userID, includePending)
+	if err != nil {
+		return err
+	}
+	return coupon.redeemable(total, byUser, includePending, time.Now())
+}
+
+// planID give id of plan of the tier, empty tier means premium which is kept as null
+func (s *Service) planID(ctx context.Context, tier string) (uuid.NullUUID, error) {
+	if tier == "" {
+		return uuid.NullUUID{}, nil
+	}
+	plan, err := s.subscriptions.PlanByTier(ctx, tier)
+	if err != nil {
+		return uuid.NullUUID{}, err
+	}
+	return uuid.NullUUID{UUID: plan.ID, Valid: true}, nil
+}
+
+// randomCode give random code from codeAlphabet
+func randomCode() (string, error) {
+	b := make([]byte, codeLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrap(err, "failed to generate coupon code")
+	}
+	for i := range b {
+		b[i] = codeAlphabet[int(b[i])%len(codeAlphabet)]
+	}
+	return string(b), nil
+}
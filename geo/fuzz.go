@@ -0,0 +1,115 @@
+package geo
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"github.com/pkg/errors"
+)
+
+const metersPerDegree = 111320
+
+// DefaultFuzzPolicy is fuzzing policy used when none is configured
+var DefaultFuzzPolicy = FuzzPolicy{
+	JitterInMeter:      300,
+	MinDistanceInMeter: 2000,
+	BucketInMeter:      1000,
+}
+
+type (
+	// FuzzPolicy is a type of policy hiding exact location of users from each other
+	FuzzPolicy struct {
+		// JitterInMeter is max offset applied to stored location, zero disables jitter
+		JitterInMeter float64
+		// MinDistanceInMeter is distance below which only "less than" is shown
+		MinDistanceInMeter float64
+		// BucketInMeter is step distance is rounded to
+		BucketInMeter float64
+		// Secret key the jitter, so offset of a user can't be derived from their id
+		Secret string
+	}
+
+	// Distance is a type of displayed distance
+	Distance struct {
+		Value    float64 `json:"value"`
+		Unit     string  `json:"unit"`
+		LessThan bool    `json:"less_than"`
+		Label    string  `json:"label"`
+	}
+)
+
+// NewFuzzPolicy give policy where zero value falls back to DefaultFuzzPolicy, negative jitter disables it.
+// panic when jitter is enabled without secret, as user ids are public and anyone could undo the jitter
+func NewFuzzPolicy(jitterInMeter, minDistanceInMeter, bucketInMeter float64, secret string) FuzzPolicy {
+	policy := DefaultFuzzPolicy
+	if jitterInMeter != 0 {
+		policy.JitterInMeter = jitterInMeter
+	}
+	if minDistanceInMeter != 0 {
+		policy.MinDistanceInMeter = minDistanceInMeter
+	}
+	if bucketInMeter != 0 {
+		policy.BucketInMeter = bucketInMeter
+	}
+	if policy.JitterInMeter > 0 && secret == "" {
+		panic(errors.New("fuzzing secret is required when jitter is enabled"))
+	}
+	policy.Secret = secret
+	return policy
+}
+
+// Jitter give the point moved by offset which is random but stable for the user,
+// so averaging repeated updates doesn't reveal the real point
+func (f FuzzPolicy) Jitter(userID string, p Point) Point {
+	if f.JitterInMeter <= 0 {
+		return p
+	}
+
+	mac := hmac.New(sha256.New, []byte(f.Secret))
+	mac.Write([]byte(userID))
+	sum := mac.Sum(nil)
+	angle := 2 * math.Pi * unitFloat(sum[:8])
+	// square root keeps offsets uniformly spread over the disc instead of clustering at its center
+	offset := f.JitterInMeter * math.Sqrt(unitFloat(sum[8:16]))
+
+	lat := p.Lat + offset*math.Cos(angle)/metersPerDegree
+	lat = math.Max(-90, math.Min(90, lat))
+	lng := p.Lng
+	if cos := math.Cos(p.Lat * math.Pi / 180); cos > 1e-9 {
+		lng += offset * math.Sin(angle) / (metersPerDegree * cos)
+	}
+	lng = math.Mod(lng+540, 360) - 180
+	return Point{Lat: lat, Lng: lng}
+}
+
+// Bucket give displayed distance of distanceInMeter, rounded to the bucket
+func (f FuzzPolicy) Bucket(distanceInMeter float64) Distance {
+	if f.MinDistanceInMeter > 0 && distanceInMeter < f.MinDistanceInMeter {
+		value := f.MinDistanceInMeter / 1000
+		return Distance{
+			Value:    value,
+			Unit:     "km",
+			LessThan: true,
+			Label:    fmt.Sprintf("less than %v km", value),
+		}
+	}
+
+	bucket := f.BucketInMeter
+	if bucket <= 0 {
+		bucket = 1000
+	}
+	value := math.Round(distanceInMeter/bucket) * bucket / 1000
+	return Distance{
+		Value: value,
+		Unit:  "km",
+		Label: fmt.Sprintf("%v km", value),
+	}
+}
+
+// unitFloat give float in [0, 1) from 8 bytes
+func unitFloat(b []byte) float64 {
+	return float64(binary.BigEndian.Uint64(b)>>11) / (1 << 53)
+}
@@ -0,0 +1,62 @@
+package geo_test
+
+import (
+	"gotinder/geo"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFuzzPolicy_Jitter(t *testing.T) {
+	policy := geo.FuzzPolicy{JitterInMeter: 300, Secret: "secret"}
+	malang := geo.Point{Lat: -7.9666, Lng: 112.6326}
+
+	jittered := policy.Jitter("user-1", malang)
+	assert.NotEqual(t, malang, jittered)
+	assert.LessOrEqual(t, malang.DistanceTo(jittered), float64(301))
+	assert.Nil(t, jittered.Validate())
+
+	// stable for the same user, so repeated updates can't be averaged out
+	assert.Equal(t, jittered, policy.Jitter("user-1", malang))
+	assert.NotEqual(t, jittered, policy.Jitter("user-2", malang))
+	assert.NotEqual(t, jittered, geo.FuzzPolicy{JitterInMeter: 300, Secret: "other"}.Jitter("user-1", malang))
+
+	assert.Equal(t, malang, geo.FuzzPolicy{}.Jitter("user-1", malang))
+	assert.Nil(t, policy.Jitter("user-1", geo.Point{Lat: 90, Lng: 180}).Validate())
+}
+
+func TestFuzzPolicy_Bucket(t *testing.T) {
+	policy := geo.FuzzPolicy{MinDistanceInMeter: 2000, BucketInMeter: 1000}
+
+	cases := []struct {
+		distanceInMeter float64
+		expected        geo.Distance
+	}{
+		{0, geo.Distance{Value: 2, Unit: "km", LessThan: true, Label: "less than 2 km"}},
+		{1999, geo.Distance{Value: 2, Unit: "km", LessThan: true, Label: "less than 2 km"}},
+		{2000, geo.Distance{Value: 2, Unit: "km", Label: "2 km"}},
+		{2499, geo.Distance{Value: 2, Unit: "km", Label: "2 km"}},
+		{2500, geo.Distance{Value: 3, Unit: "km", Label: "3 km"}},
+		{79831.27, geo.Distance{Value: 80, Unit: "km", Label: "80 km"}},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.expected, policy.Bucket(c.distanceInMeter), c.distanceInMeter)
+	}
+
+	assert.Equal(t, geo.Distance{Value: 7.5, Unit: "km", Label: "7.5 km"}, geo.FuzzPolicy{BucketInMeter: 500}.Bucket(7400))
+}
+
+func TestNewFuzzPolicy(t *testing.T) {
+	assert.Equal(t, geo.FuzzPolicy{
+		JitterInMeter:      geo.DefaultFuzzPolicy.JitterInMeter,
+		MinDistanceInMeter: 5000,
+		BucketInMeter:      geo.DefaultFuzzPolicy.BucketInMeter,
+		Secret:             "secret",
+	}, geo.NewFuzzPolicy(0, 5000, 0, "secret"))
+
+	assert.Panics(t, func() { geo.NewFuzzPolicy(0, 0, 0, "") })
+
+	disabled := geo.NewFuzzPolicy(-1, 0, 0, "")
+	p := geo.Point{Lat: -7.9666, Lng: 112.6326}
+	assert.Equal(t, p, disabled.Jitter("user-1", p))
+}
@@ -0,0 +1,108 @@
+package geo
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// maxPlaceDistanceInMeter is how far the nearest populated place may be for a point to belong to it
+const maxPlaceDistanceInMeter = 200000
+
+var (
+	ErrPlaceNotFound = errors.New("place not found")
+
+	//go:embed cities.csv
+	citiesCSV []byte
+
+	// bundledCities parse bundled city dataset once, it is part of the binary so failing is a programming error
+	bundledCities = sync.OnceValue(func() []City {
+		records, err := csv.NewReader(bytes.NewReader(citiesCSV)).ReadAll()
+		if err != nil {
+			panic(errors.Wrap(err, "failed to read city dataset"))
+		}
+		cities := make([]City, 0, len(records)-1)
+		for _, record := range records[1:] {
+			lat, latErr := strconv.ParseFloat(record[3], 64)
+			lng, lngErr := strconv.ParseFloat(record[4], 64)
+			var population int64
+			var populationErr error
+			if record[5] != "" {
+				population, populationErr = strconv.ParseInt(record[5], 10, 64)
+			}
+			if latErr != nil || lngErr != nil || populationErr != nil {
+				panic(errors.Errorf("invalid city record %v", record))
+			}
+			cities = append(cities, City{
+				Place:      Place{City: record[0], Region: record[1], CountryCode: record[2]},
+				Point:      Point{Lat: lat, Lng: lng},
+				Population: population,
+			})
+		}
+		return cities
+	})
+)
+
+type (
+	// Geocoder is an interface of reverse geocoding coordinate into place
+	Geocoder interface {
+		// ReverseGeocode give place the point belongs to
+		ReverseGeocode(ctx context.Context, p Point) (Place, error)
+	}
+
+	// Place is a type of populated place
+	Place struct {
+		City        string `json:"city"`
+		Region      string `json:"region"`
+		CountryCode string `json:"country_code"`
+	}
+
+	// OfflineGeocoder resolve point to the nearest populated place of bundled city dataset
+	OfflineGeocoder struct{}
+
+	// City is a type of populated place of bundled city dataset, Population is zero when unknown
+	City struct {
+		Place
+		Point
+		Population int64
+	}
+)
+
+var _ Geocoder = &OfflineGeocoder{}
+
+func NewOfflineGeocoder() *OfflineGeocoder {
+	return new(OfflineGeocoder)
+}
+
+// Cities give bundled city dataset, the only source of cities. it resolves places offline and is seeded on the
+// cities table passports are put on
+func Cities() []City {
+	return bundledCities()
+}
+
+// String give the place as "City, CC"
+func (p Place) String() string {
+	return fmt.Sprintf("%s, %s", p.City, p.CountryCode)
+}
+
+func (g *OfflineGeocoder) ReverseGeocode(ctx context.Context, p Point) (Place, error) {
+	cities := bundledCities()
+
+	// dataset is small, scanning it is cheaper than maintaining a spatial index
+	nearest, nearestDistance := -1, float64(maxPlaceDistanceInMeter)
+	for i, c := range cities {
+		if distance := p.DistanceTo(c.Point); distance <= nearestDistance {
+			nearest, nearestDistance = i, distance
+		}
+	}
+	if nearest < 0 {
+		return Place{}, errors.Wrapf(ErrPlaceNotFound, "no populated place near %s", p)
+	}
+	return cities[nearest].Place, nil
+}
@@ -0,0 +1,55 @@
+package geo_test
+
+import (
+	"context"
+	"gotinder/geo"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOfflineGeocoder_ReverseGeocode(t *testing.T) {
+	geocoder := geo.NewOfflineGeocoder()
+
+	cases := []struct {
+		point    geo.Point
+		expected string
+	}{
+		{geo.Point{Lat: -6.1754, Lng: 106.8272}, "Jakarta, ID"},
+		{geo.Point{Lat: -7.97727, Lng: 112.6341}, "Malang, ID"},
+		{geo.Point{Lat: -8.65, Lng: 115.2167}, "Denpasar, ID"},
+		{geo.Point{Lat: 51.5007, Lng: -0.1246}, "London, GB"},
+	}
+	for _, c := range cases {
+		place, err := geocoder.ReverseGeocode(context.Background(), c.point)
+		assert.Nil(t, err, c.expected)
+		assert.Equal(t, c.expected, place.String())
+	}
+
+	place, err := geocoder.ReverseGeocode(context.Background(), geo.Point{Lat: -6.2088, Lng: 106.8456})
+	assert.Nil(t, err)
+	assert.Equal(t, geo.Place{City: "Jakarta", Region: "Jakarta", CountryCode: "ID"}, place)
+
+	// middle of the pacific
+	_, err = geocoder.ReverseGeocode(context.Background(), geo.Point{Lat: 0, Lng: -160})
+	assert.ErrorIs(t, err, geo.ErrPlaceNotFound)
+}
+
+func TestCities(t *testing.T) {
+	cities := geo.Cities()
+	assert.NotEmpty(t, cities)
+
+	// cities table is unique by name within country, seeding it must not hit the same row twice
+	seen := make(map[string]bool, len(cities))
+	for _, c := range cities {
+		key := strings.ToLower(c.City) + "," + c.CountryCode
+		assert.False(t, seen[key], key)
+		seen[key] = true
+	}
+	assert.Contains(t, cities, geo.City{
+		Place:      geo.Place{City: "Jakarta", Region: "Jakarta", CountryCode: "ID"},
+		Point:      geo.Point{Lat: -6.2088, Lng: 106.8456},
+		Population: 10562088,
+	})
+}
@@ -0,0 +1,70 @@
+package geo
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// SRID is spatial reference of stored points (WGS 84)
+const SRID = 4326
+
+// earthRadiusInMeter is mean earth radius, the one used by PostGIS ST_DistanceSphere
+const earthRadiusInMeter = 6370986
+
+var ErrInvalidPoint = errors.New("invalid point")
+
+type (
+	// Point is a type of coordinate on earth in degree
+	Point struct {
+		Lat float64
+		Lng float64
+	}
+)
+
+// NewPoint give validated point
+func NewPoint(lat, lng float64) (Point, error) {
+	p := Point{Lat: lat, Lng: lng}
+	return p, p.Validate()
+}
+
+// ParsePoint give validated point from decimal degree strings
+func ParsePoint(lat, lng string) (Point, error) {
+	parsedLat, err := strconv.ParseFloat(lat, 64)
+	if err != nil {
+		return Point{}, errors.Wrapf(ErrInvalidPoint, "latitude %q is not a number", lat)
+	}
+	parsedLng, err := strconv.ParseFloat(lng, 64)
+	if err != nil {
+		return Point{}, errors.Wrapf(ErrInvalidPoint, "longitude %q is not a number", lng)
+	}
+	return NewPoint(parsedLat, parsedLng)
+}
+
+// Validate check the point is within latitude and longitude range
+func (p Point) Validate() error {
+	if math.IsNaN(p.Lat) || p.Lat < -90 || p.Lat > 90 {
+		return errors.Wrapf(ErrInvalidPoint, "latitude %v is out of range", p.Lat)
+	}
+	if math.IsNaN(p.Lng) || p.Lng < -180 || p.Lng > 180 {
+		return errors.Wrapf(ErrInvalidPoint, "longitude %v is out of range", p.Lng)
+	}
+	return nil
+}
+
+// DistanceTo give great circle distance to q in meter, matching PostGIS ST_DistanceSphere
+func (p Point) DistanceTo(q Point) float64 {
+	lat1, lat2 := p.Lat*math.Pi/180, q.Lat*math.Pi/180
+	dLat := lat2 - lat1
+	dLng := (q.Lng - p.Lng) * math.Pi / 180
+
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadiusInMeter * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// String give the point as "lat,lng"
+func (p Point) String() string {
+	return fmt.Sprintf("%v,%v", p.Lat, p.Lng)
+}
@@ -0,0 +1,45 @@
+package geo_test
+
+import (
+	"gotinder/geo"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePoint(t *testing.T) {
+	p, err := geo.ParsePoint("-7.97727", "112.6341")
+	assert.Nil(t, err)
+	assert.Equal(t, geo.Point{Lat: -7.97727, Lng: 112.6341}, p)
+
+	for _, c := range [][2]string{
+		{"91", "0"},
+		{"0", "-181"},
+		{"NaN", "0"},
+		{"lat", "0"},
+		{"0", "1); DROP TABLE users; --"},
+	} {
+		_, err := geo.ParsePoint(c[0], c[1])
+		assert.ErrorIs(t, err, geo.ErrInvalidPoint, c)
+	}
+}
+
+func TestPoint_DistanceTo(t *testing.T) {
+	cases := []struct {
+		name          string
+		from, to      geo.Point
+		distanceInKm  float64
+		toleranceInKm float64
+	}{
+		{"jakarta-surabaya", geo.Point{Lat: -6.2088, Lng: 106.8456}, geo.Point{Lat: -7.2575, Lng: 112.7521}, 663, 5},
+		{"malang-surabaya", geo.Point{Lat: -7.9666, Lng: 112.6326}, geo.Point{Lat: -7.2575, Lng: 112.7521}, 80, 2},
+		{"london-paris", geo.Point{Lat: 51.5074, Lng: -0.1278}, geo.Point{Lat: 48.8566, Lng: 2.3522}, 344, 3},
+		{"new york-los angeles", geo.Point{Lat: 40.7128, Lng: -74.0060}, geo.Point{Lat: 34.0522, Lng: -118.2437}, 3936, 10},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			assert.InDelta(t, c.distanceInKm, c.from.DistanceTo(c.to)/1000, c.toleranceInKm)
+			assert.InDelta(t, c.from.DistanceTo(c.to), c.to.DistanceTo(c.from), 0.001)
+		})
+	}
+}
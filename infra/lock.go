@@ -0,0 +1,29 @@
+package infra
+
+import (
+	"context"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// TryLock acquire distributed lock on redis which is held until ttl passes,
+// false means the lock is currently held by someone else
+func TryLock(ctx context.Context, pool *redis.Pool, key string, ttl time.Duration) (bool, error) {
+	conn, err := RedisConn(ctx, pool)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to acquire lock")
+	}
+	defer conn.Close()
+
+	_, err = redis.String(conn.Do("SET", key, uuid.NewString(), "NX", "PX", ttl.Milliseconds()))
+	if errors.Is(err, redis.ErrNil) {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.Wrap(err, "failed to acquire lock")
+	}
+	return true, nil
+}
@@ -0,0 +1,60 @@
+package infra
+
+import (
+	"context"
+	"database/sql"
+	"gotinder/geo"
+	"log/slog"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/pkg/errors"
+)
+
+const (
+	postgisNearbyIndexName = "postgis"
+	redisNearbyIndexName   = "redis"
+
+	redisNearbyIndexKey = "nearby-users"
+)
+
+var nearbyRegistry = map[string]func(db *sql.DB, pool *redis.Pool) NearbyIndex{
+	postgisNearbyIndexName: func(db *sql.DB, pool *redis.Pool) NearbyIndex {
+		return NewPostgisNearbyIndex(db)
+	},
+	redisNearbyIndexName: func(db *sql.DB, pool *redis.Pool) NearbyIndex {
+		return NewRedisNearbyIndex(pool, redisNearbyIndexKey)
+	},
+}
+
+type (
+	// NearbyIndex is an interface of spatial index finding users around a point
+	NearbyIndex interface {
+		// Name give identifier of the index
+		Name() string
+		// Put record latest location of the user
+		Put(ctx context.Context, userID string, p geo.Point) error
+		// Remove take the user off the index, so they are no longer found nearby
+		Remove(ctx context.Context, userID string) error
+		// Search give users within radiusInMeter of origin, nearest first
+		Search(ctx context.Context, origin geo.Point, radiusInMeter float64, limit int) ([]NearbyUser, error)
+	}
+
+	// NearbyUser is a type of user found by NearbyIndex
+	NearbyUser struct {
+		UserID          string
+		DistanceInMeter float64
+	}
+)
+
+// NewNearbyIndex give nearby index by its name, latest_locations on PostGIS stays source of truth
+func NewNearbyIndex(name string, db *sql.DB, pool *redis.Pool) NearbyIndex {
+	if name == "" {
+		name = postgisNearbyIndexName
+	}
+	newIndex, ok := nearbyRegistry[name]
+	if !ok {
+		panic(errors.Errorf("unknown nearby index %s", name))
+	}
+	slog.Info("nearby index ready", "index", name)
+	return newIndex(db, pool)
+}
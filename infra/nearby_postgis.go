@@ -0,0 +1,64 @@
+package infra
+
+import (
+	"context"
+	"database/sql"
+	"gotinder/geo"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/pkg/errors"
+)
+
+// PostgisNearbyIndex search latest_locations directly, which is already written by location update
+type PostgisNearbyIndex struct {
+	db *sql.DB
+}
+
+var _ NearbyIndex = &PostgisNearbyIndex{}
+
+func NewPostgisNearbyIndex(db *sql.DB) *PostgisNearbyIndex {
+	return &PostgisNearbyIndex{db: db}
+}
+
+func (i *PostgisNearbyIndex) Name() string {
+	return postgisNearbyIndexName
+}
+
+// Put do nothing, latest_locations is the index
+func (i *PostgisNearbyIndex) Put(ctx context.Context, userID string, p geo.Point) error {
+	return nil
+}
+
+// Remove do nothing, user is removed from latest_locations
+func (i *PostgisNearbyIndex) Remove(ctx context.Context, userID string) error {
+	return nil
+}
+
+func (i *PostgisNearbyIndex) Search(ctx context.Context, origin geo.Point, radiusInMeter float64, limit int) ([]NearbyUser, error) {
+	point := sq.Expr("ST_SetSRID(ST_MakePoint(?::float8, ?::float8), ?::int)", origin.Lng, origin.Lat, geo.SRID)
+	rows, err := sq.
+		StatementBuilder.
+		PlaceholderFormat(sq.Dollar).
+		Select("user_id").
+		Column(sq.Expr("ST_DistanceSphere(location::geometry, ?)", point)).
+		From("latest_locations").
+		Where(sq.Expr("ST_DWithin(location, (?)::geography, ?::float8)", point, radiusInMeter)).
+		OrderBy("2 ASC").
+		Limit(uint64(limit)).
+		RunWith(PgRunner(ctx, i.db)).
+		QueryContext(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to search nearby users")
+	}
+	defer rows.Close()
+
+	users := make([]NearbyUser, 0)
+	for rows.Next() {
+		var u NearbyUser
+		if err := rows.Scan(&u.UserID, &u.DistanceInMeter); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
@@ -0,0 +1,85 @@
+package infra
+
+import (
+	"context"
+	"gotinder/geo"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/pkg/errors"
+)
+
+// RedisNearbyIndex keep latest location of users in redis GEO set, taking discovery off Postgres
+type RedisNearbyIndex struct {
+	pool *redis.Pool
+	key  string
+}
+
+var _ NearbyIndex = &RedisNearbyIndex{}
+
+func NewRedisNearbyIndex(pool *redis.Pool, key string) *RedisNearbyIndex {
+	return &RedisNearbyIndex{pool: pool, key: key}
+}
+
+func (i *RedisNearbyIndex) Name() string {
+	return redisNearbyIndexName
+}
+
+func (i *RedisNearbyIndex) Put(ctx context.Context, userID string, p geo.Point) error {
+	conn, err := RedisConn(ctx, i.pool)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.Do("GEOADD", i.key, p.Lng, p.Lat, userID); err != nil {
+		return errors.Wrap(err, "failed to index user location")
+	}
+	return nil
+}
+
+func (i *RedisNearbyIndex) Remove(ctx context.Context, userID string) error {
+	conn, err := RedisConn(ctx, i.pool)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.Do("ZREM", i.key, userID); err != nil {
+		return errors.Wrap(err, "failed to remove user location from index")
+	}
+	return nil
+}
+
+func (i *RedisNearbyIndex) Search(ctx context.Context, origin geo.Point, radiusInMeter float64, limit int) ([]NearbyUser, error) {
+	conn, err := RedisConn(ctx, i.pool)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	values, err := redis.Values(conn.Do(
+		"GEOSEARCH", i.key,
+		"FROMLONLAT", origin.Lng, origin.Lat,
+		"BYRADIUS", radiusInMeter, "m",
+		"ASC",
+		"COUNT", limit,
+		"WITHDIST",
+	))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to search nearby users")
+	}
+
+	users := make([]NearbyUser, 0, len(values))
+	for _, value := range values {
+		member, err := redis.Values(value, nil)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to decode nearby user")
+		}
+		var u NearbyUser
+		if _, err := redis.Scan(member, &u.UserID, &u.DistanceInMeter); err != nil {
+			return nil, errors.Wrap(err, "failed to decode nearby user")
+		}
+		users = append(users, u)
+	}
+	return users, nil
+}
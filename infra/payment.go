@@ -0,0 +1,80 @@
+package infra
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	PaymentEventActivated PaymentEventType = "subscription.activated"
+	PaymentEventRenewed   PaymentEventType = "subscription.renewed"
+	PaymentEventCancelled PaymentEventType = "subscription.cancelled"
+
+	fakePaymentProviderName = "fake"
+)
+
+var (
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+
+	paymentRegistry = map[string]func(webhookSecret string) PaymentProvider{
+		fakePaymentProviderName: func(webhookSecret string) PaymentProvider {
+			return NewFakePaymentProvider(webhookSecret)
+		},
+	}
+)
+
+type (
+	// PaymentProvider is an interface of payment gateway used to sell subscription plans
+	PaymentProvider interface {
+		// Name give identifier of the provider, used to scope recorded sessions and events
+		Name() string
+		// CreateCheckoutSession start payment of the plan, returning url the user has to visit
+		CreateCheckoutSession(req CheckoutRequest) (CheckoutSession, error)
+		// ParseWebhook verify signature of webhook request and decode its event
+		ParseWebhook(payload []byte, header http.Header) (PaymentEvent, error)
+	}
+
+	// PaymentEventType is a type of subscription event sent by payment provider
+	PaymentEventType string
+
+	// CheckoutRequest is a type of checkout session creation request
+	CheckoutRequest struct {
+		UserID   string
+		PlanTier string
+		Amount   int64
+		Currency string
+	}
+
+	// CheckoutSession is a type of created checkout session
+	CheckoutSession struct {
+		ID  string
+		URL string
+	}
+
+	// PaymentEvent is a type of webhook event, PeriodEnd is unix time when paid period ends
+	PaymentEvent struct {
+		ID        string           `json:"id"`
+		Type      PaymentEventType `json:"type"`
+		SessionID string           `json:"session_id"`
+		PeriodEnd int64            `json:"period_end"`
+	}
+)
+
+// NewPaymentProvider give payment provider by its name, there is no default as fake one grants subscriptions
+// without any payment. panic when it is empty or unknown
+func NewPaymentProvider(name, webhookSecret string) PaymentProvider {
+	if name == "" {
+		panic(errors.New("payment provider is required"))
+	}
+	newProvider, ok := paymentRegistry[name]
+	if !ok {
+		panic(errors.Errorf("unknown payment provider %s", name))
+	}
+	if webhookSecret == "" {
+		panic(errors.New("payment webhook secret is required"))
+	}
+	slog.Info("payment provider ready", "provider", name)
+	return newProvider(webhookSecret)
+}
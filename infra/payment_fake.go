@@ -0,0 +1,105 @@
+package infra
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+const fakeSignatureHeader = "X-Fake-Signature"
+
+// FakePaymentProvider is a payment provider for tests and local development,
+// it never charges anyone and sign its webhook events with HMAC-SHA256
+type FakePaymentProvider struct {
+	webhookSecret string
+}
+
+var _ PaymentProvider = &FakePaymentProvider{}
+
+func NewFakePaymentProvider(webhookSecret string) *FakePaymentProvider {
+	return &FakePaymentProvider{webhookSecret: webhookSecret}
+}
+
+func (p *FakePaymentProvider) Name() string {
+	return fakePaymentProviderName
+}
+
+func (p *FakePaymentProvider) CreateCheckoutSession(req CheckoutRequest) (CheckoutSession, error) {
+	id := uuid.NewString()
+	return CheckoutSession{
+		ID:  id,
+		URL: fmt.Sprintf("https://fake-payment.local/checkout/%s", id),
+	}, nil
+}
+
+func (p *FakePaymentProvider) ParseWebhook(payload []byte, header http.Header) (PaymentEvent, error) {
+	var event PaymentEvent
+	signature, err := hex.DecodeString(header.Get(fakeSignatureHeader))
+	if err != nil || !hmac.Equal(signature, p.sign(payload)) {
+		return event, ErrInvalidSignature
+	}
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return event, errors.Wrap(err, "failed to decode webhook event")
+	}
+	return event, nil
+}
+
+// NewEvent build event of the checkout session with fresh event id
+func (p *FakePaymentProvider) NewEvent(eventType PaymentEventType, sessionID string, periodEnd int64) PaymentEvent {
+	return PaymentEvent{
+		ID:        uuid.NewString(),
+		Type:      eventType,
+		SessionID: sessionID,
+		PeriodEnd: periodEnd,
+	}
+}
+
+// SignEvent give webhook payload and headers as sent by the provider
+func (p *FakePaymentProvider) SignEvent(event PaymentEvent) ([]byte, http.Header, error) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to encode webhook event")
+	}
+	header := make(http.Header)
+	header.Set("Content-Type", "application/json")
+	header.Set(fakeSignatureHeader, hex.EncodeToString(p.sign(payload)))
+	return payload, header, nil
+}
+
+// Emit send signed event to the webhook url, the way real provider would
+func (p *FakePaymentProvider) Emit(ctx context.Context, webhookURL string, event PaymentEvent) error {
+	payload, header, err := p.SignEvent(event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewReader(payload))
+	if err != nil {
+		return errors.Wrap(err, "failed to build webhook request")
+	}
+	req.Header = header
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "failed to send webhook")
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return errors.Errorf("webhook responded with status %d", res.StatusCode)
+	}
+	return nil
+}
+
+func (p *FakePaymentProvider) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(p.webhookSecret))
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
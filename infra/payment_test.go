@@ -0,0 +1,16 @@
+package infra_test
+
+import (
+	"gotinder/infra"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewPaymentProvider(t *testing.T) {
+	// fake provider grants subscriptions without payment, so it is never picked when none is configured
+	assert.Panics(t, func() { infra.NewPaymentProvider("", "secret") })
+	assert.Panics(t, func() { infra.NewPaymentProvider("unknown", "secret") })
+	assert.Panics(t, func() { infra.NewPaymentProvider("fake", "") })
+	assert.NotNil(t, infra.NewPaymentProvider("fake", "secret"))
+}
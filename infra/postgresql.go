@@ -1,56 +1,77 @@
 package infra
 
 import (
+	"context"
 	"database/sql"
-	"log"
+	"log/slog"
 	"net/url"
-	"sync"
+	"strconv"
 	"time"
 
+	sq "github.com/Masterminds/squirrel"
 	"github.com/amacneil/dbmate/v2/pkg/dbmate"
+	"github.com/lib/pq"
 	"github.com/pkg/errors"
 )
 
-var (
-	PgConn     *sql.DB
-	pgConnOnce sync.Once
+const (
+	// defaultMigrationTableName is the table dbmate records applied migrations on when none is configured
+	defaultMigrationTableName = "schema_migrations"
+
+	defaultStatementTimeout = 10 * time.Second
+	pingTimeout             = 5 * time.Second
 )
 
-func NewPgConnection(connStr string) {
-	pgConnOnce.Do(func() {
-		conn, err := sql.Open("postgres", connStr)
+// NewPgConnection open connection pool to postgresql where postgresql cancels each statement running longer than
+// statementTimeout, zero falls back to default and negative disables it. each query is traced as span of the
+// context it is run with. panic when it can't be reached
+func NewPgConnection(connStr string, statementTimeout time.Duration) *sql.DB {
+	if statementTimeout == 0 {
+		statementTimeout = defaultStatementTimeout
+	}
+	if statementTimeout > 0 {
+		u, err := url.Parse(connStr)
 		if err != nil {
-			panic(errors.Wrap(err, "fail to open connection"))
+			panic(errors.Wrap(err, "failed to parse connection string"))
 		}
+		// unknown parameters are sent by lib/pq as run-time parameters of the session
+		query := u.Query()
+		query.Set("statement_timeout", strconv.FormatInt(statementTimeout.Milliseconds(), 10))
+		u.RawQuery = query.Encode()
+		connStr = u.String()
+	}
 
-		err = conn.Ping()
-		if err != nil {
-			panic(errors.Wrap(err, "fail to verify connection"))
-		}
+	connector, err := pq.NewConnector(connStr)
+	if err != nil {
+		panic(errors.Wrap(err, "fail to open connection"))
+	}
+	conn := sql.OpenDB(NewTracedConnector(connector))
 
-		conn.SetMaxIdleConns(1)
-		conn.SetMaxOpenConns(4)
-		conn.SetConnMaxLifetime(3600 * time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+	defer cancel()
+	err = conn.PingContext(ctx)
+	if err != nil {
+		panic(errors.Wrap(err, "fail to verify connection"))
+	}
 
-		log.Println("database connection established!")
+	conn.SetMaxIdleConns(1)
+	conn.SetMaxOpenConns(4)
+	conn.SetConnMaxLifetime(3600 * time.Second)
 
-		PgConn = conn
-	})
+	slog.Info("database connection established")
+
+	return conn
 }
 
-func TerminatePgConnection() {
-	defer func() {
-		PgConn = nil
-		pgConnOnce = sync.Once{}
-	}()
-	if PgConn == nil {
+func TerminatePgConnection(conn *sql.DB) {
+	if conn == nil {
 		return
 	}
-	log.Println("clossing db connection")
-	if err := PgConn.Close(); err != nil {
-		log.Println(errors.Wrap(err, "failed to close pg connection"))
+	slog.Info("closing db connection")
+	if err := conn.Close(); err != nil {
+		slog.Error("failed to close pg connection", "error", err)
 	} else {
-		log.Println("db connection closed")
+		slog.Info("db connection closed")
 	}
 }
 
@@ -63,9 +84,35 @@ func Migrate(connStr, migrationDir, migrationTableName string) {
 	db.MigrationsDir = []string{migrationDir}
 	db.MigrationsTableName = migrationTableName
 
-	log.Println("apply pending migration")
+	slog.Info("apply pending migration")
 	err = db.CreateAndMigrate()
 	if err != nil {
 		panic(errors.Wrap(err, "failed to migrate"))
 	}
 }
+
+// MigrationVersion give version of the latest migration applied by dbmate on the table, empty when none is applied
+func MigrationVersion(ctx context.Context, db *sql.DB, migrationTableName string) (string, error) {
+	if migrationTableName == "" {
+		migrationTableName = defaultMigrationTableName
+	}
+
+	var version string
+	err := sq.
+		StatementBuilder.
+		PlaceholderFormat(sq.Dollar).
+		Select("version").
+		From(pq.QuoteIdentifier(migrationTableName)).
+		OrderBy("version DESC").
+		Limit(1).
+		RunWith(PgRunner(ctx, db)).
+		QueryRowContext(ctx).
+		Scan(&version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", errors.Wrap(err, "failed to find migration version")
+	}
+	return version, nil
+}
@@ -1,67 +1,93 @@
 package infra
 
 import (
-	"log"
-	"sync"
+	"context"
+	"log/slog"
 	"time"
 
 	"github.com/gomodule/redigo/redis"
 )
 
-var (
-	RedisPool     *redis.Pool
-	redisPoolOnce sync.Once
-)
+// defaultRedisTimeout bound connecting, sending and reading reply of each command
+const defaultRedisTimeout = 3 * time.Second
 
-func NewRedisPool(server, password string, db int) {
-	redisPoolOnce.Do(func() {
-		RedisPool = &redis.Pool{
-			MaxIdle:     3,
-			Wait:        true,
-			IdleTimeout: 240 * time.Second,
-			Dial: func() (redis.Conn, error) {
-				c, err := redis.Dial("tcp", server)
-				if err != nil {
-					return nil, err
-				}
-				if password != "" {
-					if _, err := c.Do("AUTH", password); err != nil {
-						c.Close()
-						return nil, err
-					}
-				}
+// NewRedisPool give connection pool to redis where connecting, sending and reading reply of each command fails after
+// timeout, zero falls back to default and negative disables it. panic when it can't be reached
+func NewRedisPool(server, password string, db int, timeout time.Duration) *redis.Pool {
+	if timeout == 0 {
+		timeout = defaultRedisTimeout
+	}
+	var dialOpts []redis.DialOption
+	if timeout > 0 {
+		dialOpts = append(dialOpts,
+			redis.DialConnectTimeout(timeout),
+			redis.DialReadTimeout(timeout),
+			redis.DialWriteTimeout(timeout),
+		)
+	}
 
-				if _, err := c.Do("SELECT", db); err != nil {
+	pool := &redis.Pool{
+		MaxIdle:     3,
+		Wait:        true,
+		IdleTimeout: 240 * time.Second,
+		Dial: func() (redis.Conn, error) {
+			c, err := redis.Dial("tcp", server, dialOpts...)
+			if err != nil {
+				return nil, err
+			}
+			if password != "" {
+				if _, err := c.Do("AUTH", password); err != nil {
 					c.Close()
 					return nil, err
 				}
-				return c, err
-			},
-			TestOnBorrow: func(c redis.Conn, t time.Time) error {
-				_, err := c.Do("PING")
-				return err
-			},
-		}
+			}
+
+			if _, err := c.Do("SELECT", db); err != nil {
+				c.Close()
+				return nil, err
+			}
+			return c, err
+		},
+		TestOnBorrow: func(c redis.Conn, t time.Time) error {
+			_, err := c.Do("PING")
+			return err
+		},
+	}
 
-		c, err := RedisPool.Dial()
-		if err != nil {
-			panic(err)
-		}
-		defer c.Close()
+	c, err := pool.Dial()
+	if err != nil {
+		panic(err)
+	}
+	defer c.Close()
 
-		if pong, err := redis.String(c.Do("PING")); err != nil {
-			panic("Cannot ping Redis")
-		} else {
-			log.Printf("Redis Ping: %s\n", pong)
-		}
-	})
+	if pong, err := redis.String(c.Do("PING")); err != nil {
+		panic("Cannot ping Redis")
+	} else {
+		slog.Info("redis ping", "reply", pong)
+	}
+	return pool
 }
 
-func TerminalRedisPool() {
-	log.Println("closing cache connection")
-	if err := RedisPool.Close(); err != nil {
-		log.Printf("fail closing cache connection: %s\n", err)
+func TerminalRedisPool(pool *redis.Pool) {
+	if pool == nil {
+		return
+	}
+	slog.Info("closing cache connection")
+	if err := pool.Close(); err != nil {
+		slog.Error("fail closing cache connection", "error", err)
 	} else {
-		log.Println("cache connection closed")
+		slog.Info("cache connection closed")
 	}
 }
+
+// PingRedis check redis can be reached through the pool before ctx is done
+func PingRedis(ctx context.Context, pool *redis.Pool) error {
+	conn, err := pool.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = redis.DoContext(conn, ctx, "PING")
+	return err
+}
@@ -0,0 +1,144 @@
+package infra
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	tracerName = "gotinder"
+
+	stdoutTraceExporterName = "stdout"
+	otlpTraceExporterName   = "otlp"
+
+	// tracerShutdownTimeout bound flushing spans on shutdown, so unreachable collector can't hang it
+	tracerShutdownTimeout = 5 * time.Second
+)
+
+var traceExporterRegistry = map[string]func(endpoint string, insecure bool) (sdktrace.SpanExporter, error){
+	stdoutTraceExporterName: func(string, bool) (sdktrace.SpanExporter, error) {
+		return stdouttrace.New(stdouttrace.WithPrettyPrint())
+	},
+	otlpTraceExporterName: func(endpoint string, insecure bool) (sdktrace.SpanExporter, error) {
+		opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(endpoint)}
+		if insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		return otlptracehttp.New(context.Background(), opts...)
+	},
+}
+
+type (
+	// tracedRedisConn is a type of redis connection starting span for each command
+	tracedRedisConn struct {
+		redis.Conn
+		ctx context.Context
+	}
+)
+
+// NewTracerProvider give tracer provider exporting spans of the service by registered exporter of the name,
+// sampling ratio of root spans where zero samples all of them. it is registered globally along with W3C trace
+// context propagation, as instrumentation of gin, sql and redis use the global one. empty name give nil and
+// keeps the global no-op provider, panic when the exporter is unknown or can't be created
+func NewTracerProvider(name, endpoint string, insecure bool, serviceName string, sampleRatio float64) *sdktrace.TracerProvider {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	if name == "" {
+		return nil
+	}
+
+	newExporter, ok := traceExporterRegistry[name]
+	if !ok {
+		panic(errors.Errorf("unknown trace exporter %s", name))
+	}
+	exporter, err := newExporter(endpoint, insecure)
+	if err != nil {
+		panic(errors.Wrap(err, "failed to create trace exporter"))
+	}
+	if sampleRatio == 0 {
+		sampleRatio = 1
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRatio))),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(serviceName))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider
+}
+
+// TerminateTracerProvider flush spans not exported yet within tracerShutdownTimeout and stop the provider
+func TerminateTracerProvider(provider *sdktrace.TracerProvider) {
+	if provider == nil {
+		return
+	}
+	slog.Info("stopping tracer provider")
+	ctx, cancel := context.WithTimeout(context.Background(), tracerShutdownTimeout)
+	defer cancel()
+	if err := provider.Shutdown(ctx); err != nil {
+		slog.Error("fail stopping tracer provider", "error", err)
+	} else {
+		slog.Info("tracer provider stopped")
+	}
+}
+
+// RedisConn give connection of the pool once available before ctx is done, each command of it is bound to ctx
+// and traced as its span
+func RedisConn(ctx context.Context, pool *redis.Pool) (redis.Conn, error) {
+	conn, err := pool.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &tracedRedisConn{Conn: conn, ctx: ctx}, nil
+}
+
+func (c *tracedRedisConn) Do(commandName string, args ...interface{}) (interface{}, error) {
+	_, span := otel.Tracer(tracerName).Start(c.ctx, commandName,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemRedis, semconv.DBOperation(commandName)),
+	)
+	defer span.End()
+
+	reply, err := redis.DoContext(c.Conn, c.ctx, commandName, args...)
+	recordErr(span, err)
+	return reply, err
+}
+
+// startQuerySpan start span of the query named by its operation, e.g. SELECT
+func startQuerySpan(ctx context.Context, query string) (context.Context, trace.Span) {
+	operation := "QUERY"
+	if fields := strings.Fields(query); len(fields) > 0 {
+		operation = strings.ToUpper(fields[0])
+	}
+	return otel.Tracer(tracerName).Start(ctx, operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemPostgreSQL,
+			semconv.DBOperation(operation),
+			semconv.DBStatement(query),
+		),
+	)
+}
+
+// recordErr mark the span as failed by err, if any
+func recordErr(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
@@ -0,0 +1,143 @@
+package infra
+
+import (
+	"context"
+	"database/sql/driver"
+	"io"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type (
+	// tracedConnector is a type of connector whose connections trace each query as span,
+	// span of a query giving rows lasts until the rows are closed so reading them is part of it
+	tracedConnector struct {
+		connector driver.Connector
+	}
+
+	// tracedConn is a type of driver connection starting span for each query,
+	// other optional interfaces of the connection are passed through
+	tracedConn struct {
+		driver.Conn
+	}
+
+	// tracedRows is a type of driver rows ending span of its query once closed
+	tracedRows struct {
+		driver.Rows
+		span trace.Span
+	}
+)
+
+var (
+	_ driver.Connector          = tracedConnector{}
+	_ driver.QueryerContext     = &tracedConn{}
+	_ driver.ExecerContext      = &tracedConn{}
+	_ driver.ConnPrepareContext = &tracedConn{}
+	_ driver.ConnBeginTx        = &tracedConn{}
+	_ driver.Pinger             = &tracedConn{}
+	_ driver.SessionResetter    = &tracedConn{}
+	_ driver.Validator          = &tracedConn{}
+)
+
+// NewTracedConnector give connector tracing each query run on its connections as span of the query context
+func NewTracedConnector(connector driver.Connector) driver.Connector {
+	return tracedConnector{connector: connector}
+}
+
+func (c tracedConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &tracedConn{Conn: conn}, nil
+}
+
+func (c tracedConnector) Driver() driver.Driver {
+	return c.connector.Driver()
+}
+
+func (c *tracedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	ctx, span := startQuerySpan(ctx, query)
+	rows, err := queryer.QueryContext(ctx, query, args)
+	if err != nil {
+		recordErr(span, err)
+		span.End()
+		return nil, err
+	}
+	return &tracedRows{Rows: rows, span: span}, nil
+}
+
+func (c *tracedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	ctx, span := startQuerySpan(ctx, query)
+	defer span.End()
+
+	result, err := execer.ExecContext(ctx, query, args)
+	recordErr(span, err)
+	return result, err
+}
+
+func (c *tracedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		return preparer.PrepareContext(ctx, query)
+	}
+	return c.Conn.Prepare(query)
+}
+
+func (c *tracedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, opts)
+	}
+	// like database/sql does for connection without BeginTx, only default options can be honored
+	if opts.Isolation != 0 || opts.ReadOnly {
+		return nil, errors.New("transaction options are not supported by the driver")
+	}
+	return c.Conn.Begin()
+}
+
+func (c *tracedConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *tracedConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.Conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *tracedConn) IsValid() bool {
+	if validator, ok := c.Conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
+}
+
+func (r *tracedRows) Next(dest []driver.Value) error {
+	err := r.Rows.Next(dest)
+	if err != io.EOF {
+		recordErr(r.span, err)
+	}
+	return err
+}
+
+func (r *tracedRows) Close() error {
+	defer r.span.End()
+
+	err := r.Rows.Close()
+	recordErr(r.span, err)
+	return err
+}
@@ -0,0 +1,82 @@
+package infra_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"gotinder/infra"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+type (
+	// fakeConnector is a type of connector giving fakeConn
+	fakeConnector struct{}
+
+	// fakeConn is a type of driver connection answering every query with a single row
+	fakeConn struct{}
+
+	// fakeRows is a type of driver rows of a single value
+	fakeRows struct {
+		read bool
+	}
+)
+
+func (fakeConnector) Connect(context.Context) (driver.Conn, error) { return fakeConn{}, nil }
+func (fakeConnector) Driver() driver.Driver                        { return nil }
+
+func (fakeConn) Prepare(string) (driver.Stmt, error) { return nil, driver.ErrSkip }
+func (fakeConn) Close() error                        { return nil }
+func (fakeConn) Begin() (driver.Tx, error)           { return nil, driver.ErrSkip }
+
+func (fakeConn) QueryContext(context.Context, string, []driver.NamedValue) (driver.Rows, error) {
+	return &fakeRows{}, nil
+}
+
+func (r *fakeRows) Columns() []string { return []string{"id"} }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.read {
+		return io.EOF
+	}
+	r.read = true
+	dest[0] = int64(1)
+	return nil
+}
+
+func TestTracedConnector_QuerySpanEndsOnRowsClose(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	defer otel.SetTracerProvider(previous)
+
+	db := sql.OpenDB(infra.NewTracedConnector(fakeConnector{}))
+	defer db.Close()
+
+	rows, err := db.QueryContext(context.Background(), "SELECT id FROM users")
+	require.NoError(t, err)
+	assert.Len(t, recorder.Started(), 1)
+	assert.Len(t, recorder.Ended(), 0)
+
+	var id int64
+	require.True(t, rows.Next())
+	require.NoError(t, rows.Scan(&id))
+	assert.Len(t, recorder.Ended(), 0)
+
+	require.NoError(t, rows.Close())
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	assert.Equal(t, "SELECT", spans[0].Name())
+
+	// row is read on scan, so its span ends there too
+	require.NoError(t, db.QueryRowContext(context.Background(), "SELECT id FROM users").Scan(&id))
+	assert.Len(t, recorder.Ended(), 2)
+}
@@ -0,0 +1,68 @@
+package infra
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+type (
+	// Transactor is an interface of running function within one transaction,
+	// repositories called with the given context join the transaction
+	Transactor interface {
+		WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+	}
+
+	// PgTransactor run function within postgresql transaction
+	PgTransactor struct {
+		db *sql.DB
+	}
+
+	pgTxKey struct{}
+)
+
+var _ Transactor = &PgTransactor{}
+
+func NewPgTransactor(db *sql.DB) *PgTransactor {
+	return &PgTransactor{db: db}
+}
+
+// WithinTx commit the transaction when fn succeeds, nested call joins the outer transaction
+func (t *PgTransactor) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(pgTxKey{}).(*sql.Tx); ok {
+		return fn(ctx)
+	}
+
+	tx, err := t.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	var isCommitted bool
+	defer func() {
+		if !isCommitted {
+			if err := tx.Rollback(); err != nil {
+				slog.ErrorContext(ctx, "failed to rollback transaction", "error", err)
+			}
+		}
+	}()
+
+	if err := fn(context.WithValue(ctx, pgTxKey{}, tx)); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	isCommitted = true
+	return nil
+}
+
+// PgRunner give transaction the context is within, or db outside of any transaction
+func PgRunner(ctx context.Context, db *sql.DB) sq.StdSqlCtx {
+	if tx, ok := ctx.Value(pgTxKey{}).(*sql.Tx); ok {
+		return tx
+	}
+	return db
+}
@@ -0,0 +1,103 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	"gotinder/infra"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+const defaultInterval = time.Minute
+
+type (
+	// Fn is a type of function run by job runner
+	Fn func(ctx context.Context) error
+
+	// Runner run registered jobs periodically. each run is guarded by distributed lock held for
+	// the whole interval, so a job runs at most once per interval across all instances of the app
+	Runner struct {
+		pool   *redis.Pool
+		jobs   []job
+		cancel context.CancelFunc
+		wg     sync.WaitGroup
+	}
+
+	job struct {
+		name     string
+		interval time.Duration
+		fn       Fn
+	}
+)
+
+// NewRunner give runner taking locks of jobs on the redis pool
+func NewRunner(pool *redis.Pool) *Runner {
+	return &Runner{pool: pool}
+}
+
+// Register add job to be run every interval, must be called before Start
+func (r *Runner) Register(name string, interval time.Duration, fn Fn) {
+	if interval <= 0 {
+		interval = defaultInterval
+	}
+	r.jobs = append(r.jobs, job{name: name, interval: interval, fn: fn})
+}
+
+// Start run registered jobs in background
+func (r *Runner) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+	for _, j := range r.jobs {
+		r.wg.Add(1)
+		go func(j job) {
+			defer r.wg.Done()
+			j.loop(ctx, r.pool)
+		}(j)
+	}
+	slog.Info("jobs started", "count", len(r.jobs))
+}
+
+// Stop cancel running jobs and wait until they return
+func (r *Runner) Stop() {
+	if r.cancel == nil {
+		return
+	}
+	r.cancel()
+	r.wg.Wait()
+	slog.Info("jobs stopped")
+}
+
+// loop run the job on every tick until ctx is done
+func (j job) loop(ctx context.Context, pool *redis.Pool) {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+	for {
+		j.run(ctx, pool)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// run the job once when its lock can be acquired
+func (j job) run(ctx context.Context, pool *redis.Pool) {
+	locked, err := infra.TryLock(ctx, pool, fmt.Sprintf("job-lock-%s", j.name), j.interval)
+	if err != nil {
+		slog.ErrorContext(ctx, "job failed", "job", j.name, "error", err)
+		return
+	}
+	if !locked {
+		return
+	}
+
+	runCtx, cancel := context.WithTimeout(ctx, j.interval)
+	defer cancel()
+	if err := j.fn(runCtx); err != nil {
+		slog.ErrorContext(ctx, "job failed", "job", j.name, "error", err)
+	}
+}
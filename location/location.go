@@ -0,0 +1,60 @@
+package location
+
+import (
+	"context"
+	"gotinder/geo"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrPassportNotFound = errors.New("passport not set")
+	ErrCityNotFound     = errors.New("city not found")
+)
+
+type (
+	// City is a type of city a passport can be put on
+	City struct {
+		ID          uuid.UUID `json:"id"`
+		Name        string    `json:"name"`
+		CountryCode string    `json:"country_code"`
+		Lat         float64   `json:"lat"`
+		Lng         float64   `json:"lng"`
+	}
+
+	// Passport is a type of virtual location of a user
+	Passport struct {
+		Lat       float64 `json:"lat"`
+		Lng       float64 `json:"lng"`
+		City      *City   `json:"city"`
+		UpdatedAt int64   `json:"updated_at"`
+	}
+
+	// Repository is an interface of location storage
+	Repository interface {
+		// UpsertLatest record latest location of the user shown to others, nil place means it is not resolved to any place
+		UpsertLatest(ctx context.Context, userID string, p geo.Point, place *geo.Place, at time.Time) error
+		// DeleteLatest remove latest location of the user, so they are no longer shown to others
+		DeleteLatest(ctx context.Context, userID string) error
+		// RecordHistory record real location of the user
+		RecordHistory(ctx context.Context, userID string, p geo.Point, at time.Time) error
+		// DeleteHistoriesBefore delete at most limit location histories older than cutoff, giving number of deleted ones
+		DeleteHistoriesBefore(ctx context.Context, cutoff time.Time, limit int) (int64, error)
+		// JitterLatest replace latest locations recorded before jittering by jitter of them, giving number of them
+		JitterLatest(ctx context.Context, jitter func(userID string, p geo.Point) geo.Point) (int, error)
+		// EachLatest call fn on latest location of every user
+		EachLatest(ctx context.Context, fn func(userID string, p geo.Point) error) error
+		// UpsertCities put cities a passport can be put on, updating existing ones of the same name and country
+		UpsertCities(ctx context.Context, cities []geo.City) error
+		// FindCities give cities of the name prefix, most populated first
+		FindCities(ctx context.Context, prefix string, limit int) ([]City, error)
+		// FindCityByName give most populated city of the name, optionally within the country
+		FindCityByName(ctx context.Context, name, countryCode string) (City, error)
+		FindPassport(ctx context.Context, userID string) (Passport, error)
+		// UpsertPassport put the user on virtual location, optionally on a city
+		UpsertPassport(ctx context.Context, userID string, p geo.Point, cityID uuid.NullUUID, at time.Time) error
+		DeletePassport(ctx context.Context, userID string) error
+	}
+)
@@ -0,0 +1,318 @@
+package location
+
+import (
+	"context"
+	"database/sql"
+	"gotinder/geo"
+	"gotinder/infra"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// PostgresRepository store locations on postgresql
+type PostgresRepository struct {
+	db *sql.DB
+}
+
+var _ Repository = &PostgresRepository{}
+
+func NewPostgresRepository(db *sql.DB) *PostgresRepository {
+	return &PostgresRepository{db: db}
+}
+
+var psql = sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+func (r *PostgresRepository) UpsertLatest(ctx context.Context, userID string, p geo.Point, place *geo.Place, at time.Time) error {
+	var city, countryCode *string
+	if place != nil {
+		city, countryCode = &place.City, &place.CountryCode
+	}
+
+	if _, err := psql.
+		Insert("latest_locations").
+		Columns("updated_at", "lat", "lng", "city", "country_code", "jittered", "user_id").
+		Values(at.Unix(), p.Lat, p.Lng, city, countryCode, true, userID).
+		Suffix(`
+			ON CONFLICT (user_id) DO UPDATE SET
+			updated_at=EXCLUDED.updated_at,
+			lat=EXCLUDED.lat,
+			lng=EXCLUDED.lng,
+			location=EXCLUDED.location,
+			city=EXCLUDED.city,
+			country_code=EXCLUDED.country_code,
+			jittered=EXCLUDED.jittered
+		`).
+		RunWith(infra.PgRunner(ctx, r.db)).
+		ExecContext(ctx); err != nil {
+		return errors.Wrap(err, "failed to record request")
+	}
+	return nil
+}
+
+func (r *PostgresRepository) DeleteLatest(ctx context.Context, userID string) error {
+	if _, err := psql.
+		Delete("latest_locations").
+		Where("user_id = ?", userID).
+		RunWith(infra.PgRunner(ctx, r.db)).
+		ExecContext(ctx); err != nil {
+		return errors.Wrap(err, "failed to remove latest location")
+	}
+	return nil
+}
+
+func (r *PostgresRepository) RecordHistory(ctx context.Context, userID string, p geo.Point, at time.Time) error {
+	if _, err := psql.
+		Insert("location_histories").
+		Columns("created_at", "lat", "lng", "user_id").
+		Values(at.Unix(), p.Lat, p.Lng, userID).
+		RunWith(infra.PgRunner(ctx, r.db)).
+		ExecContext(ctx); err != nil {
+		return errors.Wrap(err, "failed to record location history")
+	}
+	return nil
+}
+
+func (r *PostgresRepository) DeleteHistoriesBefore(ctx context.Context, cutoff time.Time, limit int) (int64, error) {
+	expired := sq.
+		Select("id").
+		From("location_histories").
+		Where("created_at < ?", cutoff.Unix()).
+		Limit(uint64(limit))
+	result, err := psql.
+		Delete("location_histories").
+		Where(sq.Expr("id IN (?)", expired)).
+		RunWith(infra.PgRunner(ctx, r.db)).
+		ExecContext(ctx)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to delete location histories")
+	}
+	return result.RowsAffected()
+}
+
+func (r *PostgresRepository) EachLatest(ctx context.Context, fn func(userID string, p geo.Point) error) error {
+	rows, err := psql.
+		Select("user_id", "lat", "lng").
+		From("latest_locations").
+		RunWith(infra.PgRunner(ctx, r.db)).
+		QueryContext(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to find latest locations")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var userID string
+		var p geo.Point
+		if err := rows.Scan(&userID, &p.Lat, &p.Lng); err != nil {
+			return err
+		}
+		if err := fn(userID, p); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (r *PostgresRepository) JitterLatest(ctx context.Context, jitter func(userID string, p geo.Point) geo.Point) (int, error) {
+	rows, err := psql.
+		Select("user_id", "lat", "lng").
+		From("latest_locations").
+		Where("NOT jittered").
+		RunWith(infra.PgRunner(ctx, r.db)).
+		QueryContext(ctx)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to find latest locations")
+	}
+	defer rows.Close()
+
+	latest := make(map[string]geo.Point)
+	for rows.Next() {
+		var userID string
+		var p geo.Point
+		if err := rows.Scan(&userID, &p.Lat, &p.Lng); err != nil {
+			return 0, err
+		}
+		latest[userID] = p
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	var jittered int
+	for userID, p := range latest {
+		p = jitter(userID, p)
+		// location updated meanwhile is already jittered, so it is left as it is
+		result, err := psql.
+			Update("latest_locations").
+			Set("lat", p.Lat).
+			Set("lng", p.Lng).
+			Set("jittered", true).
+			Where("user_id = ?", userID).
+			Where("NOT jittered").
+			RunWith(infra.PgRunner(ctx, r.db)).
+			ExecContext(ctx)
+		if err != nil {
+			return jittered, errors.Wrap(err, "failed to jitter latest location")
+		}
+		if affected, err := result.RowsAffected(); err == nil {
+			jittered += int(affected)
+		}
+	}
+	return jittered, nil
+}
+
+func (r *PostgresRepository) UpsertCities(ctx context.Context, cities []geo.City) error {
+	if len(cities) == 0 {
+		return nil
+	}
+	query := psql.
+		Insert("cities").
+		Columns("name", "country_code", "population", "lat", "lng")
+	for _, city := range cities {
+		query = query.Values(city.City, city.CountryCode, city.Population, city.Lat, city.Lng)
+	}
+	if _, err := query.
+		Suffix(`
+			ON CONFLICT (LOWER(name), country_code) DO UPDATE SET
+			population=EXCLUDED.population,
+			lat=EXCLUDED.lat,
+			lng=EXCLUDED.lng
+		`).
+		RunWith(infra.PgRunner(ctx, r.db)).
+		ExecContext(ctx); err != nil {
+		return errors.Wrap(err, "failed to upsert cities")
+	}
+	return nil
+}
+
+func (r *PostgresRepository) FindCities(ctx context.Context, prefix string, limit int) ([]City, error) {
+	rows, err := selectCities().
+		Where("cities.name ILIKE ?", escapeLike(prefix)+"%").
+		OrderBy("cities.population DESC", "cities.name ASC").
+		Limit(uint64(limit)).
+		RunWith(infra.PgRunner(ctx, r.db)).
+		QueryContext(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to find cities")
+	}
+	defer rows.Close()
+
+	cities := make([]City, 0)
+	for rows.Next() {
+		city, err := scanCity(rows)
+		if err != nil {
+			return nil, err
+		}
+		cities = append(cities, city)
+	}
+	return cities, rows.Err()
+}
+
+func (r *PostgresRepository) FindCityByName(ctx context.Context, name, countryCode string) (City, error) {
+	query := selectCities().
+		Where("LOWER(cities.name) = LOWER(?)", name).
+		OrderBy("cities.population DESC").
+		Limit(1)
+	if countryCode != "" {
+		query = query.Where("cities.country_code = UPPER(?)", countryCode)
+	}
+	city, err := scanCity(query.RunWith(infra.PgRunner(ctx, r.db)).QueryRowContext(ctx))
+	if errors.Is(err, sql.ErrNoRows) {
+		return city, ErrCityNotFound
+	}
+	return city, err
+}
+
+func (r *PostgresRepository) FindPassport(ctx context.Context, userID string) (Passport, error) {
+	var passport Passport
+	var cityID uuid.NullUUID
+	var cityName, countryCode sql.NullString
+	var cityLat, cityLng sql.NullFloat64
+	err := psql.
+		Select(
+			"passport_locations.lat",
+			"passport_locations.lng",
+			"passport_locations.updated_at",
+			"cities.id",
+			"cities.name",
+			"cities.country_code",
+			"cities.lat",
+			"cities.lng",
+		).
+		From("passport_locations").
+		LeftJoin("cities ON cities.id = passport_locations.city_id").
+		Where("passport_locations.user_id = ?", userID).
+		RunWith(infra.PgRunner(ctx, r.db)).
+		QueryRowContext(ctx).
+		Scan(&passport.Lat, &passport.Lng, &passport.UpdatedAt, &cityID, &cityName, &countryCode, &cityLat, &cityLng)
+	if errors.Is(err, sql.ErrNoRows) {
+		return passport, ErrPassportNotFound
+	}
+	if err != nil {
+		return passport, errors.Wrap(err, "failed to find passport")
+	}
+
+	if cityID.Valid {
+		passport.City = &City{
+			ID:          cityID.UUID,
+			Name:        cityName.String,
+			CountryCode: countryCode.String,
+			Lat:         cityLat.Float64,
+			Lng:         cityLng.Float64,
+		}
+	}
+	return passport, nil
+}
+
+func (r *PostgresRepository) UpsertPassport(ctx context.Context, userID string, p geo.Point, cityID uuid.NullUUID, at time.Time) error {
+	if _, err := psql.
+		Insert("passport_locations").
+		Columns("updated_at", "lat", "lng", "city_id", "user_id").
+		Values(at.Unix(), p.Lat, p.Lng, cityID, userID).
+		Suffix(`
+			ON CONFLICT (user_id) DO UPDATE SET
+			updated_at=EXCLUDED.updated_at,
+			lat=EXCLUDED.lat,
+			lng=EXCLUDED.lng,
+			city_id=EXCLUDED.city_id
+		`).
+		RunWith(infra.PgRunner(ctx, r.db)).
+		ExecContext(ctx); err != nil {
+		return errors.Wrap(err, "failed to record passport")
+	}
+	return nil
+}
+
+func (r *PostgresRepository) DeletePassport(ctx context.Context, userID string) error {
+	if _, err := psql.
+		Delete("passport_locations").
+		Where("user_id = ?", userID).
+		RunWith(infra.PgRunner(ctx, r.db)).
+		ExecContext(ctx); err != nil {
+		return errors.Wrap(err, "failed to remove passport")
+	}
+	return nil
+}
+
+// selectCities build query to select city columns in the order expected by scanCity
+func selectCities() sq.SelectBuilder {
+	return psql.
+		Select("cities.id", "cities.name", "cities.country_code", "cities.lat", "cities.lng").
+		From("cities")
+}
+
+// scanCity read city columns selected by selectCities
+func scanCity(row sq.RowScanner) (City, error) {
+	var city City
+	err := row.Scan(&city.ID, &city.Name, &city.CountryCode, &city.Lat, &city.Lng)
+	return city, err
+}
+
+// escapeLike escape LIKE wildcards of user input
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
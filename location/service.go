@@ -0,0 +1,195 @@
+package location
+
+import (
+	"context"
+	"gotinder/geo"
+	"gotinder/infra"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultHistoryRetention = 30 * 24 * time.Hour
+	historyDeleteBatchSize  = 5000
+)
+
+// Service is a type of location business logic
+type Service struct {
+	repo     Repository
+	tx       infra.Transactor
+	nearby   infra.NearbyIndex
+	geocoder geo.Geocoder
+	policy   geo.FuzzPolicy
+	throttle Throttle
+	store    ThrottleStore
+}
+
+func NewService(
+	repo Repository,
+	tx infra.Transactor,
+	nearby infra.NearbyIndex,
+	geocoder geo.Geocoder,
+	policy geo.FuzzPolicy,
+	throttle Throttle,
+	store ThrottleStore,
+) *Service {
+	return &Service{
+		repo:     repo,
+		tx:       tx,
+		nearby:   nearby,
+		geocoder: geocoder,
+		policy:   policy,
+		throttle: throttle,
+		store:    store,
+	}
+}
+
+// Update record current location of the user, giving whether latest location is written and the place it is
+// resolved to. when throttled, only history is recorded while latest location and index are left as they are.
+// place is nil when location is far from any known place or the update is throttled
+func (s *Service) Update(ctx context.Context, userID string, p geo.Point) (bool, *geo.Place, error) {
+	// throttling is best effort, database stays the source of truth when the store fails
+	allowed, err := s.throttle.allow(ctx, s.store, userID, p)
+	if err != nil {
+		slog.WarnContext(ctx, "failed to check location throttle", "error", err)
+		allowed = true
+	}
+	if !allowed {
+		// real location is always kept in history, throttling only spares latest location and index
+		return false, nil, s.repo.RecordHistory(ctx, userID, p, time.Now())
+	}
+
+	var place *geo.Place
+	resolved, err := s.geocoder.ReverseGeocode(ctx, p)
+	if err != nil && !errors.Is(err, geo.ErrPlaceNotFound) {
+		slog.WarnContext(ctx, "failed to reverse geocode location", "error", err)
+	}
+	if err == nil {
+		place = &resolved
+	}
+
+	// others only ever see jittered location, real one is kept in history
+	jittered := s.policy.Jitter(userID, p)
+	now := time.Now()
+	if err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.repo.UpsertLatest(ctx, userID, jittered, place, now); err != nil {
+			return err
+		}
+		// real location is always kept in history, even when passport is used for recommendations
+		return s.repo.RecordHistory(ctx, userID, p, now)
+	}); err != nil {
+		return false, nil, err
+	}
+
+	// latest_locations is source of truth, index can be rebuilt from it when it misses an update
+	if err := s.nearby.Put(ctx, userID, jittered); err != nil {
+		slog.WarnContext(ctx, "failed to index nearby location", "error", err)
+	}
+	if err := s.throttle.accept(ctx, s.store, userID, p); err != nil {
+		slog.WarnContext(ctx, "failed to record location throttle", "error", err)
+	}
+	return true, place, nil
+}
+
+// SeedCities put bundled city dataset on cities a passport can be put on, it is safe to run on every start
+func (s *Service) SeedCities(ctx context.Context) error {
+	return s.repo.UpsertCities(ctx, geo.Cities())
+}
+
+// Cities give cities of the name prefix, most populated first
+func (s *Service) Cities(ctx context.Context, prefix string, limit int) ([]City, error) {
+	return s.repo.FindCities(ctx, prefix, limit)
+}
+
+// Passport give virtual location of the user
+func (s *Service) Passport(ctx context.Context, userID string) (Passport, error) {
+	return s.repo.FindPassport(ctx, userID)
+}
+
+// SetPassport put the user on virtual location used for their recommendations, either on the point
+// or on the city when point is nil. their real location keeps being recorded
+func (s *Service) SetPassport(ctx context.Context, userID string, p *geo.Point, city, countryCode string) (Passport, error) {
+	var point geo.Point
+	var cityID uuid.NullUUID
+	if p != nil {
+		point = *p
+	} else {
+		c, err := s.repo.FindCityByName(ctx, city, countryCode)
+		if err != nil {
+			return Passport{}, err
+		}
+		point = geo.Point{Lat: c.Lat, Lng: c.Lng}
+		cityID = uuid.NullUUID{UUID: c.ID, Valid: true}
+	}
+
+	if err := s.repo.UpsertPassport(ctx, userID, point, cityID, time.Now()); err != nil {
+		return Passport{}, err
+	}
+	return s.repo.FindPassport(ctx, userID)
+}
+
+// RemovePassport put the user back on their real location
+func (s *Service) RemovePassport(ctx context.Context, userID string) error {
+	return s.repo.DeletePassport(ctx, userID)
+}
+
+// StopSharing hide the user from others nearby until their next accepted location update. unlike Update,
+// failing to remove them from the index is returned since rebuilding it does not remove anyone.
+// their last accepted location is forgotten too, otherwise resuming from the same spot would be throttled
+func (s *Service) StopSharing(ctx context.Context, userID string) error {
+	if err := s.repo.DeleteLatest(ctx, userID); err != nil {
+		return err
+	}
+	if err := s.store.ClearLast(ctx, userID); err != nil {
+		return err
+	}
+	return s.nearby.Remove(ctx, userID)
+}
+
+// NewHistoryRetentionJob give job which delete location histories older than retention,
+// in batches so the table is not locked for long
+func (s *Service) NewHistoryRetentionJob(retention time.Duration) func(ctx context.Context) error {
+	if retention <= 0 {
+		retention = defaultHistoryRetention
+	}
+
+	return func(ctx context.Context) error {
+		cutoff := time.Now().Add(-retention)
+		for {
+			deleted, err := s.repo.DeleteHistoriesBefore(ctx, cutoff, historyDeleteBatchSize)
+			if err != nil {
+				return err
+			}
+			if deleted < historyDeleteBatchSize {
+				return nil
+			}
+		}
+	}
+}
+
+// JitterLegacyLocations jitter latest locations recorded before locations were jittered, so others never see
+// real location of those who have not updated since. it is safe to run on every start
+func (s *Service) JitterLegacyLocations(ctx context.Context) (int, error) {
+	return s.repo.JitterLatest(ctx, s.policy.Jitter)
+}
+
+// RebuildNearbyIndex put latest location of every user on nearby index, giving number of indexed users.
+// legacy locations are jittered first, so real location is never copied to the index
+func (s *Service) RebuildNearbyIndex(ctx context.Context) (int, error) {
+	if _, err := s.JitterLegacyLocations(ctx); err != nil {
+		return 0, err
+	}
+
+	var indexed int
+	err := s.repo.EachLatest(ctx, func(userID string, p geo.Point) error {
+		if err := s.nearby.Put(ctx, userID, p); err != nil {
+			return err
+		}
+		indexed++
+		return nil
+	})
+	return indexed, err
+}
@@ -0,0 +1,91 @@
+package location
+
+import (
+	"context"
+	"gotinder/geo"
+	"time"
+)
+
+// DefaultThrottle is location update throttle used when none is configured
+var DefaultThrottle = Throttle{
+	Interval:           time.Minute,
+	MinDistanceInMeter: 100,
+	MaxUpdates:         5,
+}
+
+type (
+	// Throttle is a type of policy skipping location updates per user
+	Throttle struct {
+		// Interval is window of both movement filter and rate limit, zero disables throttling
+		Interval time.Duration
+		// MinDistanceInMeter is how far user must move from last accepted update within interval
+		MinDistanceInMeter float64
+		// MaxUpdates is max accepted updates within interval, zero means unlimited
+		MaxUpdates int
+	}
+
+	// ThrottleStore is an interface of short lived state of location updates per user
+	ThrottleStore interface {
+		// Last give last accepted location of the user, nil when there is none within the interval
+		Last(ctx context.Context, userID string) (*geo.Point, error)
+		// SetLast record last accepted location of the user for ttl
+		SetLast(ctx context.Context, userID string, p geo.Point, ttl time.Duration) error
+		// ClearLast forget last accepted location of the user, so next update from the same spot is accepted
+		ClearLast(ctx context.Context, userID string) error
+		// Incr count an update of the user, the count is reset ttl after the first one
+		Incr(ctx context.Context, userID string, ttl time.Duration) (int, error)
+	}
+)
+
+// NewThrottle give throttle where zero value falls back to DefaultThrottle, negative value disables it
+func NewThrottle(interval time.Duration, minDistanceInMeter float64, maxUpdates int) Throttle {
+	throttle := DefaultThrottle
+	if interval != 0 {
+		throttle.Interval = max(interval, 0)
+	}
+	if minDistanceInMeter != 0 {
+		throttle.MinDistanceInMeter = max(minDistanceInMeter, 0)
+	}
+	if maxUpdates != 0 {
+		throttle.MaxUpdates = max(maxUpdates, 0)
+	}
+	return throttle
+}
+
+// allow check whether location update of the user should be written,
+// it is skipped when user barely moved or updated too often within the interval
+func (t Throttle) allow(ctx context.Context, store ThrottleStore, userID string, p geo.Point) (bool, error) {
+	if t.Interval <= 0 {
+		return true, nil
+	}
+
+	if t.MinDistanceInMeter > 0 {
+		last, err := store.Last(ctx, userID)
+		if err != nil {
+			return false, err
+		}
+		if last != nil && p.DistanceTo(*last) < t.MinDistanceInMeter {
+			return false, nil
+		}
+	}
+
+	if t.MaxUpdates > 0 {
+		count, err := store.Incr(ctx, userID, t.Interval)
+		if err != nil {
+			return false, err
+		}
+		if count > t.MaxUpdates {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// accept record point as last accepted location update of the user
+func (t Throttle) accept(ctx context.Context, store ThrottleStore, userID string, p geo.Point) error {
+	if t.Interval <= 0 || t.MinDistanceInMeter <= 0 {
+		return nil
+	}
+	return store.SetLast(ctx, userID, p, t.Interval)
+}
@@ -0,0 +1,98 @@
+package location
+
+import (
+	"context"
+	"fmt"
+	"gotinder/geo"
+	"gotinder/infra"
+	"strings"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/pkg/errors"
+)
+
+// RedisThrottleStore keep throttle state on redis keys expiring with the interval
+type RedisThrottleStore struct {
+	pool *redis.Pool
+}
+
+var _ ThrottleStore = &RedisThrottleStore{}
+
+func NewRedisThrottleStore(pool *redis.Pool) *RedisThrottleStore {
+	return &RedisThrottleStore{pool: pool}
+}
+
+func (s *RedisThrottleStore) Last(ctx context.Context, userID string) (*geo.Point, error) {
+	cacheConn, err := infra.RedisConn(ctx, s.pool)
+	if err != nil {
+		return nil, err
+	}
+	defer cacheConn.Close()
+
+	last, err := redis.String(cacheConn.Do("GET", lastLocationKey(userID)))
+	if errors.Is(err, redis.ErrNil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to find last location")
+	}
+
+	lat, lng, _ := strings.Cut(last, ",")
+	p, err := geo.ParsePoint(lat, lng)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse last location")
+	}
+	return &p, nil
+}
+
+func (s *RedisThrottleStore) SetLast(ctx context.Context, userID string, p geo.Point, ttl time.Duration) error {
+	cacheConn, err := infra.RedisConn(ctx, s.pool)
+	if err != nil {
+		return err
+	}
+	defer cacheConn.Close()
+
+	if _, err := cacheConn.Do("SET", lastLocationKey(userID), p.String(), "PX", ttl.Milliseconds()); err != nil {
+		return errors.Wrap(err, "failed to record last location")
+	}
+	return nil
+}
+
+func (s *RedisThrottleStore) ClearLast(ctx context.Context, userID string) error {
+	cacheConn, err := infra.RedisConn(ctx, s.pool)
+	if err != nil {
+		return err
+	}
+	defer cacheConn.Close()
+
+	if _, err := cacheConn.Do("DEL", lastLocationKey(userID)); err != nil {
+		return errors.Wrap(err, "failed to clear last location")
+	}
+	return nil
+}
+
+func (s *RedisThrottleStore) Incr(ctx context.Context, userID string, ttl time.Duration) (int, error) {
+	cacheConn, err := infra.RedisConn(ctx, s.pool)
+	if err != nil {
+		return 0, err
+	}
+	defer cacheConn.Close()
+
+	count, err := redis.Int(cacheConn.Do("INCR", locationRateKey(userID)))
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to count location updates")
+	}
+	if _, err := cacheConn.Do("PEXPIRE", locationRateKey(userID), ttl.Milliseconds(), "NX"); err != nil {
+		return 0, errors.Wrap(err, "failed to count location updates")
+	}
+	return count, nil
+}
+
+func lastLocationKey(userID string) string {
+	return fmt.Sprintf("location-last-%s", userID)
+}
+
+func locationRateKey(userID string) string {
+	return fmt.Sprintf("location-rate-%s", userID)
+}
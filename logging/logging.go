@@ -0,0 +1,99 @@
+package logging
+
+import (
+	"context"
+	"gotinder/config"
+	"io"
+	"log/slog"
+	"net/url"
+	"strings"
+)
+
+// redacted replace value of sensitive attributes and query parameters
+const redacted = "[REDACTED]"
+
+// sensitiveKeys are parts of attribute or query parameter keys whose value must never be logged
+var sensitiveKeys = []string{"password", "passwd", "token", "secret", "jwt", "authorization", "cookie"}
+
+type (
+	// contextHandler is a type of handler adding attributes attached to the context to each record
+	contextHandler struct {
+		slog.Handler
+	}
+
+	attrsKey struct{}
+)
+
+// New give logger of the environment: human-readable text on local, JSON elsewhere, debug level outside production.
+// attributes attached to the context by With are added to each record, sensitive ones are redacted
+func New(w io.Writer, cfg config.AppConfiguration) *slog.Logger {
+	opts := &slog.HandlerOptions{
+		Level:       slog.LevelInfo,
+		ReplaceAttr: redactAttr,
+	}
+	if cfg.IsDebug() {
+		opts.Level = slog.LevelDebug
+	}
+
+	var handler slog.Handler = slog.NewJSONHandler(w, opts)
+	if cfg.IsLocal() {
+		handler = slog.NewTextHandler(w, opts)
+	}
+	return slog.New(contextHandler{Handler: handler})
+}
+
+// With give context carrying attrs along with ones already attached, so every record logged with it has them
+func With(ctx context.Context, attrs ...slog.Attr) context.Context {
+	existing, _ := ctx.Value(attrsKey{}).([]slog.Attr)
+	merged := make([]slog.Attr, 0, len(existing)+len(attrs))
+	merged = append(merged, existing...)
+	merged = append(merged, attrs...)
+	return context.WithValue(ctx, attrsKey{}, merged)
+}
+
+// RedactURL give path and query of u, with value of sensitive query parameters redacted
+func RedactURL(u *url.URL) string {
+	if u.RawQuery == "" {
+		return u.Path
+	}
+	query := u.Query()
+	for key := range query {
+		if isSensitive(key) {
+			query[key] = []string{redacted}
+		}
+	}
+	return u.Path + "?" + query.Encode()
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if attrs, ok := ctx.Value(attrsKey{}).([]slog.Attr); ok {
+		r.AddAttrs(attrs...)
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{Handler: h.Handler.WithGroup(name)}
+}
+
+// redactAttr replace value of sensitive attribute
+func redactAttr(_ []string, a slog.Attr) slog.Attr {
+	if isSensitive(a.Key) {
+		return slog.String(a.Key, redacted)
+	}
+	return a
+}
+
+func isSensitive(key string) bool {
+	key = strings.ToLower(key)
+	for _, sensitive := range sensitiveKeys {
+		if strings.Contains(key, sensitive) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,61 @@
+package logging_test
+
+import (
+	"bytes"
+	"context"
+	"gotinder/config"
+	"gotinder/logging"
+	"log/slog"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	var buf bytes.Buffer
+	logger := logging.New(&buf, config.AppConfiguration{})
+
+	ctx := logging.With(context.Background(), slog.String("request_id", "req-1"))
+	ctx = logging.With(ctx, slog.String("user_id", "user-1"))
+	logger.InfoContext(ctx, "registered", "email", "base@mail.com", "password", "Secret1234!", "access_token", "jwt")
+
+	line := buf.String()
+	assert.Contains(t, line, "request_id=req-1")
+	assert.Contains(t, line, "user_id=user-1")
+	assert.Contains(t, line, "email=base@mail.com")
+	assert.Contains(t, line, "password=[REDACTED]")
+	assert.Contains(t, line, "access_token=[REDACTED]")
+	assert.NotContains(t, line, "Secret1234!")
+}
+
+func TestRedactURL(t *testing.T) {
+	tests := []struct {
+		name string
+		url  string
+		want string
+	}{
+		{
+			name: "no query",
+			url:  "/v1/plans",
+			want: "/v1/plans",
+		},
+		{
+			name: "insensitive query",
+			url:  "/v1/actions/likes?limit=10&cursor=abc",
+			want: "/v1/actions/likes?cursor=abc&limit=10",
+		},
+		{
+			name: "sensitive query",
+			url:  "/v1/auth/direct/login?user=base@mail.com&passwd=x&password=secret&token=jwt",
+			want: "/v1/auth/direct/login?passwd=%5BREDACTED%5D&password=%5BREDACTED%5D&token=%5BREDACTED%5D&user=base%40mail.com",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := url.Parse(tt.url)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, logging.RedactURL(u))
+		})
+	}
+}
@@ -1,9 +1,17 @@
 package main
 
 import (
+	"context"
+	"gotinder/app"
 	"gotinder/config"
 	"gotinder/infra"
+	"gotinder/job"
+	"gotinder/logging"
 	"gotinder/rest"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
 
 	_ "github.com/amacneil/dbmate/v2/pkg/driver/postgres"
 	_ "github.com/lib/pq"
@@ -11,17 +19,65 @@ import (
 
 func main() {
 	cfg := config.New()
-	infra.NewPgConnection(cfg.Store.Postgresql.GetConfigString())
+	slog.SetDefault(logging.New(os.Stderr, cfg.App.Rest))
+	tracerProvider := infra.NewTracerProvider(
+		cfg.Tracing.Exporter,
+		cfg.Tracing.Endpoint,
+		cfg.Tracing.Insecure,
+		cfg.App.Rest.Name,
+		cfg.Tracing.SampleRatio,
+	)
+	db := infra.NewPgConnection(cfg.Store.Postgresql.GetConfigString(), cfg.Store.Postgresql.StatementTimeout)
 	infra.Migrate(cfg.Store.Postgresql.GetConfigString(), "./migrations", cfg.Store.Migration.TableName)
-	infra.NewRedisPool(cfg.Store.Redis.GetConfigString(), cfg.Store.Redis.Password, cfg.Store.Redis.Database)
+	cache := infra.NewRedisPool(cfg.Store.Redis.GetConfigString(), cfg.Store.Redis.Password, cfg.Store.Redis.Database, cfg.Store.Redis.Timeout)
+	a := app.New(cfg, db, cache)
+	if err := a.Services.Locations.SeedCities(context.Background()); err != nil {
+		slog.Error("failed to seed cities", "error", err)
+		os.Exit(1)
+	}
+	if jittered, err := a.Services.Locations.JitterLegacyLocations(context.Background()); err != nil {
+		slog.Error("failed to jitter legacy locations", "error", err)
+		os.Exit(1)
+	} else if jittered > 0 {
+		slog.Info("legacy locations jittered", "users", jittered)
+	}
+	if len(os.Args) > 1 {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		err := runCommand(ctx, a, os.Args[1:])
+		stop()
+		infra.TerminateTracerProvider(tracerProvider)
+		a.Close()
+		if err != nil {
+			slog.Error("command failed", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
 	if cfg.App.Rest.Enabled {
+		runner := job.NewRunner(a.Cache)
+		if cfg.App.Job.Enabled {
+			runner.Register(
+				"subscription-expiry",
+				cfg.App.Job.SubscriptionExpiryInterval,
+				a.Services.Subscriptions.NewExpiryJob(cfg.App.Job.SubscriptionGracePeriod, cfg.App.Job.SubscriptionNoticePeriod),
+			)
+			runner.Register(
+				"location-history-retention",
+				cfg.App.Job.LocationHistoryInterval,
+				a.Services.Locations.NewHistoryRetentionJob(cfg.App.Job.LocationHistoryRetention),
+			)
+		}
+		runner.Start()
 		rest.New(
-			cfg.App.Rest.Port,
+			a,
+			func() (name string, fn func()) {
+				return "stop job runner", runner.Stop
+			},
 			func() (name string, fn func()) {
-				return "close pg connection", infra.TerminatePgConnection
+				return "flush traces", func() { infra.TerminateTracerProvider(tracerProvider) }
 			},
 			func() (name string, fn func()) {
-				return "close redis connection", infra.TerminalRedisPool
+				return "close connections", a.Close
 			},
 		)
 	}
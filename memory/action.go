@@ -0,0 +1,198 @@
+package memory
+
+import (
+	"context"
+	"gotinder/action"
+	"gotinder/pagination"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// aDay is how long acted targets are counted toward the quota
+const aDay = 24 * time.Hour
+
+type (
+	// ActionRepository store actions on the store, each action type on its own table
+	ActionRepository struct {
+		store *Store
+	}
+
+	// QuotaStore count acted targets on a set which expires a day after first action
+	QuotaStore struct {
+		store *Store
+	}
+
+	actionRow struct {
+		SelfID    string
+		TargetID  string
+		CreatedAt int64
+	}
+)
+
+var (
+	_ action.Repository = &ActionRepository{}
+	_ action.QuotaStore = &QuotaStore{}
+)
+
+func NewActionRepository(store *Store) *ActionRepository {
+	return &ActionRepository{store: store}
+}
+
+func NewQuotaStore(store *Store) *QuotaStore {
+	return &QuotaStore{store: store}
+}
+
+func (r *ActionRepository) Create(ctx context.Context, t action.Type, selfID, targetID string) error {
+	return r.store.run(ctx, func(st *state) error {
+		if st.hasActed(t, selfID, targetID) {
+			return nil
+		}
+		st.actions[string(t)] = append(st.actions[string(t)], actionRow{
+			SelfID:    selfID,
+			TargetID:  targetID,
+			CreatedAt: time.Now().Unix(),
+		})
+		return nil
+	})
+}
+
+func (r *ActionRepository) Delete(ctx context.Context, t action.Type, selfID, targetID string) (bool, error) {
+	var deleted bool
+	err := r.store.run(ctx, func(st *state) error {
+		rows := make([]actionRow, 0, len(st.actions[string(t)]))
+		for _, row := range st.actions[string(t)] {
+			if row.SelfID == selfID && row.TargetID == targetID {
+				deleted = true
+				continue
+			}
+			rows = append(rows, row)
+		}
+		st.actions[string(t)] = rows
+		return nil
+	})
+	return deleted, err
+}
+
+func (r *ActionRepository) Find(ctx context.Context, t action.Type, selfID string, after *pagination.Cursor, limit int) ([]action.Action, error) {
+	actions := make([]action.Action, 0)
+	err := r.store.run(ctx, func(st *state) error {
+		for _, row := range st.actions[string(t)] {
+			target, found := st.users[row.TargetID]
+			if row.SelfID != selfID || !found {
+				continue
+			}
+			actions = append(actions, action.Action{
+				ID:          target.ID,
+				BirthOfDate: target.BirthOfDate,
+				CreatedAt:   row.CreatedAt,
+			})
+		}
+		return nil
+	})
+	return page(actions, after, func(act action.Action) pagination.Cursor {
+		return pagination.Cursor{CreatedAt: act.CreatedAt, ID: act.ID.String()}
+	}, limit), err
+}
+
+func (r *ActionRepository) CountReceivedLikes(ctx context.Context, selfID string) (int64, error) {
+	var count int64
+	err := r.store.run(ctx, func(st *state) error {
+		count = int64(len(st.pendingLikes(selfID)))
+		return nil
+	})
+	return count, err
+}
+
+func (r *ActionRepository) FindReceivedLikes(ctx context.Context, selfID string, after *pagination.Cursor, limit int) ([]action.ReceivedLike, error) {
+	var likes []action.ReceivedLike
+	err := r.store.run(ctx, func(st *state) error {
+		likes = st.pendingLikes(selfID)
+		return nil
+	})
+	return page(likes, after, func(like action.ReceivedLike) pagination.Cursor {
+		return pagination.Cursor{CreatedAt: like.LikedAt, ID: like.ID.String()}
+	}, limit), err
+}
+
+func (q *QuotaStore) Count(ctx context.Context, selfID string) (int, error) {
+	var count int
+	err := q.store.run(ctx, func(st *state) error {
+		if quota, found := st.quotas[selfID]; found && quota.alive(time.Now()) {
+			count = len(quota.value)
+		}
+		return nil
+	})
+	return count, err
+}
+
+func (q *QuotaStore) Add(ctx context.Context, selfID, targetID string) error {
+	return q.store.run(ctx, func(st *state) error {
+		now := time.Now()
+		quota, found := st.quotas[selfID]
+		if !found || !quota.alive(now) {
+			quota = expiring[map[string]bool]{value: make(map[string]bool), expiresAt: now.Add(aDay)}
+		}
+		quota.value[targetID] = true
+		st.quotas[selfID] = quota
+		return nil
+	})
+}
+
+func (q *QuotaStore) Remove(ctx context.Context, selfID, targetID string) error {
+	return q.store.run(ctx, func(st *state) error {
+		quota, found := st.quotas[selfID]
+		if !found || !quota.alive(time.Now()) || !quota.value[targetID] {
+			return nil
+		}
+		delete(quota.value, targetID)
+		quota.value["withdrawn-"+uuid.NewString()] = true
+		return nil
+	})
+}
+
+// hasActed check whether the user already acted on the target
+func (st *state) hasActed(t action.Type, selfID, targetID string) bool {
+	for _, row := range st.actions[string(t)] {
+		if row.SelfID == selfID && row.TargetID == targetID {
+			return true
+		}
+	}
+	return false
+}
+
+// isActedOn check whether anyone liked or passed the user
+func (st *state) isActedOn(targetID string) bool {
+	for _, rows := range st.actions {
+		for _, row := range rows {
+			if row.TargetID == targetID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// pendingLikes give likes received by the user who has not liked nor passed the liker
+func (st *state) pendingLikes(selfID string) []action.ReceivedLike {
+	likes := make([]action.ReceivedLike, 0)
+	for _, row := range st.actions[string(action.Like)] {
+		liker, found := st.users[row.SelfID]
+		if row.TargetID != selfID || !found {
+			continue
+		}
+		if st.hasActed(action.Like, selfID, row.SelfID) || st.hasActed(action.Pass, selfID, row.SelfID) {
+			continue
+		}
+		like := action.ReceivedLike{
+			ID:          liker.ID,
+			BirthOfDate: liker.BirthOfDate,
+			LikedAt:     row.CreatedAt,
+		}
+		if latest, found := st.latestLocations[row.SelfID]; found {
+			like.Place = latest.Place
+		}
+		likes = append(likes, like)
+	}
+	return likes
+}
@@ -0,0 +1,330 @@
+package memory
+
+import (
+	"context"
+	"gotinder/apperr"
+	"gotinder/coupon"
+	"gotinder/pagination"
+	"gotinder/subscription"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+type (
+	// CouponRepository store coupons and their redemptions on the store
+	CouponRepository struct {
+		store *Store
+	}
+
+	couponRow struct {
+		ID                    string
+		CreatedAt             int64
+		Code                  string
+		DurationInSecond      int64
+		ValidUntil            int64
+		MaxRedemptions        *int64
+		MaxRedemptionsPerUser int64
+		RevokedAt             *int64
+		Campaign              *string
+		PlanID                uuid.NullUUID
+		IsPublic              bool
+	}
+
+	userCouponRow struct {
+		ID       string
+		UserID   string
+		CouponID string
+		UsedAt   *int64
+	}
+)
+
+var _ coupon.Repository = &CouponRepository{}
+
+func NewCouponRepository(store *Store) *CouponRepository {
+	return &CouponRepository{store: store}
+}
+
+func (r *CouponRepository) Create(ctx context.Context, c coupon.NewCoupon, planID uuid.NullUUID) error {
+	return r.store.run(ctx, func(st *state) error {
+		if _, found := st.couponByCode(c.Code); found {
+			return errors.Errorf("coupon %s already exists", c.Code)
+		}
+
+		row := couponRow{
+			ID:                    uuid.NewString(),
+			CreatedAt:             time.Now().Unix(),
+			Code:                  c.Code,
+			DurationInSecond:      c.DurationInSecond,
+			ValidUntil:            c.ValidUntil,
+			MaxRedemptions:        c.MaxRedemptions,
+			MaxRedemptionsPerUser: c.MaxRedemptionsPerUser,
+			PlanID:                planID,
+			IsPublic:              c.IsPublic,
+		}
+		if c.Campaign != "" {
+			row.Campaign = &c.Campaign
+		}
+		st.coupons[row.ID] = row
+		return nil
+	})
+}
+
+func (r *CouponRepository) Find(ctx context.Context, campaign string, after *pagination.Cursor, limit int) ([]coupon.Coupon, error) {
+	coupons := make([]coupon.Coupon, 0)
+	err := r.store.run(ctx, func(st *state) error {
+		for _, row := range st.coupons {
+			if campaign == "" || (row.Campaign != nil && *row.Campaign == campaign) {
+				coupons = append(coupons, st.coupon(row))
+			}
+		}
+		return nil
+	})
+	return page(coupons, after, func(c coupon.Coupon) pagination.Cursor {
+		return pagination.Cursor{CreatedAt: c.CreatedAt, ID: c.ID}
+	}, limit), err
+}
+
+func (r *CouponRepository) FindByID(ctx context.Context, id string) (coupon.Coupon, error) {
+	return r.findOne(ctx, func(row couponRow) bool {
+		return row.ID == id
+	})
+}
+
+func (r *CouponRepository) EachByCampaign(ctx context.Context, campaign string, fn func(coupon.Coupon) error) error {
+	coupons := make([]coupon.Coupon, 0)
+	if err := r.store.run(ctx, func(st *state) error {
+		for _, row := range st.coupons {
+			if row.Campaign != nil && *row.Campaign == campaign {
+				coupons = append(coupons, st.coupon(row))
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	sort.Slice(coupons, func(i, j int) bool {
+		if coupons[i].CreatedAt != coupons[j].CreatedAt {
+			return coupons[i].CreatedAt < coupons[j].CreatedAt
+		}
+		return coupons[i].Code < coupons[j].Code
+	})
+	for _, c := range coupons {
+		if err := fn(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *CouponRepository) Revoke(ctx context.Context, id string) (bool, error) {
+	var revoked bool
+	err := r.store.run(ctx, func(st *state) error {
+		row, found := st.coupons[id]
+		if !found {
+			return nil
+		}
+		if row.RevokedAt == nil {
+			revokedAt := time.Now().Unix()
+			row.RevokedAt = &revokedAt
+			st.coupons[id] = row
+		}
+		revoked = true
+		return nil
+	})
+	return revoked, err
+}
+
+func (r *CouponRepository) LockByCode(ctx context.Context, code string, publicOnly bool) (coupon.Coupon, error) {
+	return r.findOne(ctx, func(row couponRow) bool {
+		return row.Code == code && (!publicOnly || row.IsPublic)
+	})
+}
+
+func (r *CouponRepository) LockByID(ctx context.Context, id string) (coupon.Coupon, error) {
+	return r.FindByID(ctx, id)
+}
+
+func (r *CouponRepository) CountRedemptions(ctx context.Context, couponID, userID string, includePending bool) (int64, int64, error) {
+	var total, byUser int64
+	err := r.store.run(ctx, func(st *state) error {
+		for _, row := range st.userCoupons {
+			if row.CouponID != couponID || (!includePending && row.UsedAt == nil) {
+				continue
+			}
+			total++
+			if row.UserID == userID {
+				byUser++
+			}
+		}
+		return nil
+	})
+	return total, byUser, err
+}
+
+func (r *CouponRepository) CreateUserCoupon(ctx context.Context, userID, couponID string, usedAt *int64) error {
+	return r.store.run(ctx, func(st *state) error {
+		if _, found := st.users[userID]; !found {
+			return apperr.ErrReferenceNotFound.Wrap(errors.Errorf("user %s not found", userID))
+		}
+		for _, row := range st.userCoupons {
+			if usedAt == nil && row.UserID == userID && row.CouponID == couponID && row.UsedAt == nil {
+				return apperr.ErrConflict.Wrap(errors.New("coupon is already applied to the user"))
+			}
+		}
+		st.userCoupons = append(st.userCoupons, userCouponRow{
+			ID:       uuid.NewString(),
+			UserID:   userID,
+			CouponID: couponID,
+			UsedAt:   usedAt,
+		})
+		return nil
+	})
+}
+
+func (r *CouponRepository) LockUserCoupon(ctx context.Context, code, userID string) (coupon.UserCoupon, error) {
+	var userCoupon coupon.UserCoupon
+	err := r.store.run(ctx, func(st *state) error {
+		c, found := st.couponByCode(code)
+		if !found {
+			return coupon.ErrAlreadyUsed
+		}
+		for _, row := range st.userCoupons {
+			if row.UserID == userID && row.CouponID == c.ID && row.UsedAt == nil {
+				userCoupon = coupon.UserCoupon{ID: row.ID, CouponID: c.ID, DurationInSecond: c.DurationInSecond}
+				return nil
+			}
+		}
+		return coupon.ErrAlreadyUsed
+	})
+	return userCoupon, err
+}
+
+func (r *CouponRepository) MarkUsed(ctx context.Context, userCouponID string) (bool, error) {
+	var used bool
+	err := r.store.run(ctx, func(st *state) error {
+		for i := range st.userCoupons {
+			if st.userCoupons[i].ID == userCouponID && st.userCoupons[i].UsedAt == nil {
+				usedAt := time.Now().Unix()
+				st.userCoupons[i].UsedAt = &usedAt
+				used = true
+			}
+		}
+		return nil
+	})
+	return used, err
+}
+
+func (r *CouponRepository) InsertCodes(ctx context.Context, batch coupon.Batch, planID uuid.NullUUID, codes []string) ([]string, error) {
+	inserted := make([]string, 0, len(codes))
+	err := r.store.run(ctx, func(st *state) error {
+		now := time.Now().Unix()
+		for _, code := range codes {
+			// colliding codes are skipped like ON CONFLICT DO NOTHING
+			if _, found := st.couponByCode(code); found {
+				continue
+			}
+			row := couponRow{
+				ID:                    uuid.NewString(),
+				CreatedAt:             now,
+				Code:                  code,
+				DurationInSecond:      batch.DurationInSecond,
+				ValidUntil:            batch.ValidUntil,
+				MaxRedemptionsPerUser: batch.MaxRedemptionsPerUser,
+				Campaign:              &batch.Campaign,
+				PlanID:                planID,
+			}
+			st.coupons[row.ID] = row
+			inserted = append(inserted, code)
+		}
+		return nil
+	})
+	return inserted, err
+}
+
+func (r *CouponRepository) CampaignStats(ctx context.Context, campaign string) (coupon.CampaignStats, error) {
+	stats := coupon.CampaignStats{Campaign: campaign}
+	err := r.store.run(ctx, func(st *state) error {
+		campaignCoupons := make(map[string]bool)
+		for _, row := range st.coupons {
+			if row.Campaign == nil || *row.Campaign != campaign {
+				continue
+			}
+			campaignCoupons[row.ID] = true
+			stats.TotalCodes++
+			if row.RevokedAt != nil {
+				stats.RevokedCodes++
+			}
+		}
+
+		redeemed := make(map[string]bool)
+		for _, row := range st.userCoupons {
+			if !campaignCoupons[row.CouponID] {
+				continue
+			}
+			if row.UsedAt == nil {
+				stats.Pending++
+				continue
+			}
+			stats.Redemptions++
+			redeemed[row.CouponID] = true
+		}
+		stats.RedeemedCodes = int64(len(redeemed))
+		return nil
+	})
+	return stats, err
+}
+
+// findOne give the coupon matching fn, missing coupon is reported as ErrCouponNotFound
+func (r *CouponRepository) findOne(ctx context.Context, fn func(row couponRow) bool) (coupon.Coupon, error) {
+	var c coupon.Coupon
+	err := r.store.run(ctx, func(st *state) error {
+		for _, row := range st.coupons {
+			if fn(row) {
+				c = st.coupon(row)
+				return nil
+			}
+		}
+		return coupon.ErrCouponNotFound
+	})
+	return c, err
+}
+
+// couponByCode give coupon row of the code
+func (st *state) couponByCode(code string) (couponRow, bool) {
+	for _, row := range st.coupons {
+		if row.Code == code {
+			return row, true
+		}
+	}
+	return couponRow{}, false
+}
+
+// coupon give the coupon of the row along with tier of its plan and number of its redemptions
+func (st *state) coupon(row couponRow) coupon.Coupon {
+	c := coupon.Coupon{
+		ID:                    row.ID,
+		CreatedAt:             row.CreatedAt,
+		Code:                  row.Code,
+		DurationInSecond:      row.DurationInSecond,
+		ValidUntil:            row.ValidUntil,
+		MaxRedemptions:        row.MaxRedemptions,
+		MaxRedemptionsPerUser: row.MaxRedemptionsPerUser,
+		RevokedAt:             row.RevokedAt,
+		Campaign:              row.Campaign,
+		PlanTier:              subscription.PlanPremium,
+		IsPublic:              row.IsPublic,
+	}
+	if plan, found := st.planByID(row.PlanID.UUID); row.PlanID.Valid && found {
+		c.PlanTier = plan.Tier
+	}
+	for _, userCoupon := range st.userCoupons {
+		if userCoupon.CouponID == row.ID && userCoupon.UsedAt != nil {
+			c.Redemptions++
+		}
+	}
+	return c
+}
@@ -0,0 +1,317 @@
+package memory
+
+import (
+	"context"
+	"gotinder/geo"
+	"gotinder/infra"
+	"gotinder/location"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// nearbyIndexName is identifier of NearbyIndex
+const nearbyIndexName = "memory"
+
+type (
+	// LocationRepository store locations, cities and passports on the store
+	LocationRepository struct {
+		store *Store
+	}
+
+	// ThrottleStore keep throttle state on the store, expiring with the interval
+	ThrottleStore struct {
+		store *Store
+	}
+
+	// NearbyIndex find users around a point by measuring distance to every user, only fit for small data
+	NearbyIndex struct {
+		store *Store
+	}
+
+	latestLocationRow struct {
+		geo.Point
+		Place     *geo.Place
+		UpdatedAt int64
+		Jittered  bool
+	}
+
+	locationHistoryRow struct {
+		geo.Point
+		UserID    string
+		CreatedAt int64
+	}
+
+	cityRow struct {
+		location.City
+		Population int64
+	}
+
+	passportRow struct {
+		geo.Point
+		CityID    uuid.NullUUID
+		UpdatedAt int64
+	}
+)
+
+var (
+	_ location.Repository    = &LocationRepository{}
+	_ location.ThrottleStore = &ThrottleStore{}
+	_ infra.NearbyIndex      = &NearbyIndex{}
+)
+
+func NewLocationRepository(store *Store) *LocationRepository {
+	return &LocationRepository{store: store}
+}
+
+func NewThrottleStore(store *Store) *ThrottleStore {
+	return &ThrottleStore{store: store}
+}
+
+func NewNearbyIndex(store *Store) *NearbyIndex {
+	return &NearbyIndex{store: store}
+}
+
+func (r *LocationRepository) UpsertLatest(ctx context.Context, userID string, p geo.Point, place *geo.Place, at time.Time) error {
+	return r.store.run(ctx, func(st *state) error {
+		st.latestLocations[userID] = latestLocationRow{Point: p, Place: place, UpdatedAt: at.Unix(), Jittered: true}
+		return nil
+	})
+}
+
+func (r *LocationRepository) DeleteLatest(ctx context.Context, userID string) error {
+	return r.store.run(ctx, func(st *state) error {
+		delete(st.latestLocations, userID)
+		return nil
+	})
+}
+
+func (r *LocationRepository) RecordHistory(ctx context.Context, userID string, p geo.Point, at time.Time) error {
+	return r.store.run(ctx, func(st *state) error {
+		st.locationHistories = append(st.locationHistories, locationHistoryRow{Point: p, UserID: userID, CreatedAt: at.Unix()})
+		return nil
+	})
+}
+
+func (r *LocationRepository) DeleteHistoriesBefore(ctx context.Context, cutoff time.Time, limit int) (int64, error) {
+	var deleted int64
+	err := r.store.run(ctx, func(st *state) error {
+		histories := make([]locationHistoryRow, 0, len(st.locationHistories))
+		for _, row := range st.locationHistories {
+			if row.CreatedAt < cutoff.Unix() && deleted < int64(limit) {
+				deleted++
+				continue
+			}
+			histories = append(histories, row)
+		}
+		st.locationHistories = histories
+		return nil
+	})
+	return deleted, err
+}
+
+func (r *LocationRepository) JitterLatest(ctx context.Context, jitter func(userID string, p geo.Point) geo.Point) (int, error) {
+	var jittered int
+	err := r.store.run(ctx, func(st *state) error {
+		for userID, row := range st.latestLocations {
+			if row.Jittered {
+				continue
+			}
+			row.Point, row.Jittered = jitter(userID, row.Point), true
+			st.latestLocations[userID] = row
+			jittered++
+		}
+		return nil
+	})
+	return jittered, err
+}
+
+func (r *LocationRepository) EachLatest(ctx context.Context, fn func(userID string, p geo.Point) error) error {
+	latest := make(map[string]geo.Point)
+	if err := r.store.run(ctx, func(st *state) error {
+		for userID, row := range st.latestLocations {
+			latest[userID] = row.Point
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	for userID, p := range latest {
+		if err := fn(userID, p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *LocationRepository) UpsertCities(ctx context.Context, cities []geo.City) error {
+	return r.store.run(ctx, func(st *state) error {
+		for _, city := range cities {
+			i := slices.IndexFunc(st.cities, func(row cityRow) bool {
+				return strings.EqualFold(row.Name, city.City) && row.CountryCode == city.CountryCode
+			})
+			if i < 0 {
+				st.cities = append(st.cities, cityRow{City: location.City{ID: uuid.New(), Name: city.City, CountryCode: city.CountryCode}})
+				i = len(st.cities) - 1
+			}
+			st.cities[i].Lat, st.cities[i].Lng, st.cities[i].Population = city.Lat, city.Lng, city.Population
+		}
+		return nil
+	})
+}
+
+func (r *LocationRepository) FindCities(ctx context.Context, prefix string, limit int) ([]location.City, error) {
+	var rows []cityRow
+	err := r.store.run(ctx, func(st *state) error {
+		for _, row := range st.cities {
+			if strings.HasPrefix(strings.ToLower(row.Name), strings.ToLower(prefix)) {
+				rows = append(rows, row)
+			}
+		}
+		return nil
+	})
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Population != rows[j].Population {
+			return rows[i].Population > rows[j].Population
+		}
+		return rows[i].Name < rows[j].Name
+	})
+
+	cities := make([]location.City, 0, min(len(rows), limit))
+	for _, row := range rows[:min(len(rows), limit)] {
+		cities = append(cities, row.City)
+	}
+	return cities, err
+}
+
+func (r *LocationRepository) FindCityByName(ctx context.Context, name, countryCode string) (location.City, error) {
+	var city cityRow
+	err := r.store.run(ctx, func(st *state) error {
+		var found bool
+		for _, row := range st.cities {
+			if !strings.EqualFold(row.Name, name) || (countryCode != "" && row.CountryCode != strings.ToUpper(countryCode)) {
+				continue
+			}
+			if !found || row.Population > city.Population {
+				city, found = row, true
+			}
+		}
+		if !found {
+			return location.ErrCityNotFound
+		}
+		return nil
+	})
+	return city.City, err
+}
+
+func (r *LocationRepository) FindPassport(ctx context.Context, userID string) (location.Passport, error) {
+	var passport location.Passport
+	err := r.store.run(ctx, func(st *state) error {
+		row, found := st.passports[userID]
+		if !found {
+			return location.ErrPassportNotFound
+		}
+		passport = location.Passport{Lat: row.Lat, Lng: row.Lng, UpdatedAt: row.UpdatedAt}
+		for i := range st.cities {
+			if row.CityID.Valid && st.cities[i].ID == row.CityID.UUID {
+				city := st.cities[i].City
+				passport.City = &city
+			}
+		}
+		return nil
+	})
+	return passport, err
+}
+
+func (r *LocationRepository) UpsertPassport(ctx context.Context, userID string, p geo.Point, cityID uuid.NullUUID, at time.Time) error {
+	return r.store.run(ctx, func(st *state) error {
+		st.passports[userID] = passportRow{Point: p, CityID: cityID, UpdatedAt: at.Unix()}
+		return nil
+	})
+}
+
+func (r *LocationRepository) DeletePassport(ctx context.Context, userID string) error {
+	return r.store.run(ctx, func(st *state) error {
+		delete(st.passports, userID)
+		return nil
+	})
+}
+
+func (s *ThrottleStore) Last(ctx context.Context, userID string) (*geo.Point, error) {
+	var last *geo.Point
+	err := s.store.run(ctx, func(st *state) error {
+		if cached, found := st.lastLocations[userID]; found && cached.alive(time.Now()) {
+			last = &cached.value
+		}
+		return nil
+	})
+	return last, err
+}
+
+func (s *ThrottleStore) SetLast(ctx context.Context, userID string, p geo.Point, ttl time.Duration) error {
+	return s.store.run(ctx, func(st *state) error {
+		st.lastLocations[userID] = expiring[geo.Point]{value: p, expiresAt: time.Now().Add(ttl)}
+		return nil
+	})
+}
+
+func (s *ThrottleStore) ClearLast(ctx context.Context, userID string) error {
+	return s.store.run(ctx, func(st *state) error {
+		delete(st.lastLocations, userID)
+		return nil
+	})
+}
+
+func (s *ThrottleStore) Incr(ctx context.Context, userID string, ttl time.Duration) (int, error) {
+	var count int
+	err := s.store.run(ctx, func(st *state) error {
+		now := time.Now()
+		rate, found := st.locationRates[userID]
+		if !found || !rate.alive(now) {
+			rate = expiring[int]{expiresAt: now.Add(ttl)}
+		}
+		rate.value++
+		st.locationRates[userID] = rate
+		count = rate.value
+		return nil
+	})
+	return count, err
+}
+
+func (i *NearbyIndex) Name() string {
+	return nearbyIndexName
+}
+
+func (i *NearbyIndex) Put(ctx context.Context, userID string, p geo.Point) error {
+	return i.store.run(ctx, func(st *state) error {
+		st.nearby[userID] = p
+		return nil
+	})
+}
+
+func (i *NearbyIndex) Remove(ctx context.Context, userID string) error {
+	return i.store.run(ctx, func(st *state) error {
+		delete(st.nearby, userID)
+		return nil
+	})
+}
+
+func (i *NearbyIndex) Search(ctx context.Context, origin geo.Point, radiusInMeter float64, limit int) ([]infra.NearbyUser, error) {
+	users := make([]infra.NearbyUser, 0)
+	err := i.store.run(ctx, func(st *state) error {
+		for userID, p := range st.nearby {
+			if distance := origin.DistanceTo(p); distance <= radiusInMeter {
+				users = append(users, infra.NearbyUser{UserID: userID, DistanceInMeter: distance})
+			}
+		}
+		return nil
+	})
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].DistanceInMeter < users[j].DistanceInMeter
+	})
+	return users[:min(len(users), limit)], err
+}
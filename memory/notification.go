@@ -0,0 +1,53 @@
+package memory
+
+import (
+	"context"
+	"gotinder/notification"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type (
+	// NotificationRepository store notification events on the store
+	NotificationRepository struct {
+		store *Store
+	}
+
+	// Publisher keep published events on the store, given by Store.Published
+	Publisher struct {
+		store *Store
+	}
+)
+
+var (
+	_ notification.Repository = &NotificationRepository{}
+	_ notification.Publisher  = &Publisher{}
+)
+
+func NewNotificationRepository(store *Store) *NotificationRepository {
+	return &NotificationRepository{store: store}
+}
+
+func NewPublisher(store *Store) *Publisher {
+	return &Publisher{store: store}
+}
+
+func (r *NotificationRepository) Record(ctx context.Context, event *notification.Event) error {
+	return r.store.run(ctx, func(st *state) error {
+		event.ID = uuid.NewString()
+		event.CreatedAt = time.Now().Unix()
+		st.notificationEvents = append(st.notificationEvents, *event)
+		return nil
+	})
+}
+
+func (p *Publisher) Publish(ctx context.Context, events []notification.Event) error {
+	// published events are kept outside of tables, so they are not rolled back like messages sent on redis channel
+	if ctx.Value(storeKey{}) != p.store {
+		p.store.mu.Lock()
+		defer p.store.mu.Unlock()
+	}
+	p.store.published = append(p.store.published, events...)
+	return nil
+}
@@ -0,0 +1,128 @@
+package memory
+
+import (
+	"context"
+	"gotinder/apperr"
+	"gotinder/infra"
+	"gotinder/payment"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+type (
+	// PaymentRepository store checkout sessions and webhook events on the store
+	PaymentRepository struct {
+		store *Store
+	}
+
+	paymentSessionRow struct {
+		ID                string
+		Provider          string
+		ProviderSessionID string
+		UserID            string
+		PlanID            uuid.UUID
+		SubscriptionID    uuid.NullUUID
+		PaidUntil         int64
+		PaidInSecond      int64
+	}
+)
+
+var _ payment.Repository = &PaymentRepository{}
+
+func NewPaymentRepository(store *Store) *PaymentRepository {
+	return &PaymentRepository{store: store}
+}
+
+func (r *PaymentRepository) CreateSession(ctx context.Context, provider, userID string, planID uuid.UUID) (string, error) {
+	id := uuid.NewString()
+	err := r.store.run(ctx, func(st *state) error {
+		st.paymentSessions[id] = paymentSessionRow{
+			ID:       id,
+			Provider: provider,
+			UserID:   userID,
+			PlanID:   planID,
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+func (r *PaymentRepository) LinkProviderSession(ctx context.Context, sessionID, providerSessionID string) error {
+	return r.store.run(ctx, func(st *state) error {
+		row, found := st.paymentSessions[sessionID]
+		if !found {
+			return payment.ErrSessionNotFound
+		}
+		if other, found := st.paymentSession(row.Provider, providerSessionID); found && other.ID != sessionID {
+			return apperr.ErrConflict.Wrap(errors.New("failed to link checkout session: session already exists"))
+		}
+		row.ProviderSessionID = providerSessionID
+		st.paymentSessions[sessionID] = row
+		return nil
+	})
+}
+
+func (r *PaymentRepository) LockSession(ctx context.Context, provider, providerSessionID string) (payment.Session, error) {
+	var session payment.Session
+	err := r.store.run(ctx, func(st *state) error {
+		row, found := st.paymentSession(provider, providerSessionID)
+		if !found {
+			return payment.ErrSessionNotFound
+		}
+		plan, found := st.planByID(row.PlanID)
+		if !found {
+			return payment.ErrSessionNotFound
+		}
+		session = payment.Session{
+			ID:             row.ID,
+			UserID:         row.UserID,
+			PlanTier:       plan.Tier,
+			SubscriptionID: row.SubscriptionID,
+			PaidUntil:      row.PaidUntil,
+			PaidInSecond:   row.PaidInSecond,
+		}
+		return nil
+	})
+	return session, err
+}
+
+func (r *PaymentRepository) RecordEvent(ctx context.Context, provider, sessionID string, event infra.PaymentEvent) (bool, error) {
+	var recorded bool
+	err := r.store.run(ctx, func(st *state) error {
+		key := provider + ":" + event.ID
+		if st.paymentEvents[key] {
+			return nil
+		}
+		st.paymentEvents[key] = true
+		recorded = true
+		return nil
+	})
+	return recorded, err
+}
+
+func (r *PaymentRepository) RecordActivation(ctx context.Context, sessionID string, subscriptionID uuid.UUID, paidUntil, paidInSecond int64) error {
+	return r.store.run(ctx, func(st *state) error {
+		if row, found := st.paymentSessions[sessionID]; found {
+			row.SubscriptionID = uuid.NullUUID{UUID: subscriptionID, Valid: true}
+			row.PaidUntil = paidUntil
+			row.PaidInSecond = paidInSecond
+			st.paymentSessions[sessionID] = row
+		}
+		return nil
+	})
+}
+
+// paymentSession give checkout session of the provider
+func (st *state) paymentSession(provider, providerSessionID string) (paymentSessionRow, bool) {
+	for _, row := range st.paymentSessions {
+		// pending session has no provider session ID to be matched yet
+		if row.Provider == provider && row.ProviderSessionID != "" && row.ProviderSessionID == providerSessionID {
+			return row, true
+		}
+	}
+	return paymentSessionRow{}, false
+}
@@ -0,0 +1,107 @@
+package memory
+
+import (
+	"context"
+	"gotinder/geo"
+	"gotinder/recommendation"
+	"slices"
+	"sort"
+)
+
+// RecommendationRepository find recommendations on the store
+type RecommendationRepository struct {
+	store *Store
+}
+
+var _ recommendation.Repository = &RecommendationRepository{}
+
+func NewRecommendationRepository(store *Store) *RecommendationRepository {
+	return &RecommendationRepository{store: store}
+}
+
+func (r *RecommendationRepository) FindOrigin(ctx context.Context, email string, usePassport bool) (string, geo.Point, error) {
+	var userID string
+	var origin geo.Point
+	err := r.store.run(ctx, func(st *state) error {
+		row, found := st.userByEmail(email)
+		if !found {
+			return recommendation.ErrOriginNotFound
+		}
+		userID = row.ID.String()
+
+		if passport, found := st.passports[userID]; usePassport && found {
+			origin = passport.Point
+			return nil
+		}
+		latest, found := st.latestLocations[userID]
+		if !found {
+			return recommendation.ErrOriginNotFound
+		}
+		origin = latest.Point
+		return nil
+	})
+	if err != nil {
+		return "", geo.Point{}, err
+	}
+	return userID, origin, nil
+}
+
+func (r *RecommendationRepository) FindNearbyCandidates(ctx context.Context, userID string, origin geo.Point, radiusInMeter float64, limit int) ([]recommendation.Candidate, error) {
+	distances := make(map[string]float64)
+	err := r.store.run(ctx, func(st *state) error {
+		for id, latest := range st.latestLocations {
+			if distance := origin.DistanceTo(latest.Point); id != userID && distance <= radiusInMeter {
+				distances[id] = distance
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(distances))
+	for id := range distances {
+		ids = append(ids, id)
+	}
+	candidates, err := r.FindCandidates(ctx, ids, limit)
+	for i := range candidates {
+		candidates[i].DistanceInMeter = distances[candidates[i].ID.String()]
+	}
+	return candidates, err
+}
+
+func (r *RecommendationRepository) FindCandidates(ctx context.Context, ids []string, limit int) ([]recommendation.Candidate, error) {
+	type candidate struct {
+		recommendation.Candidate
+		updatedAt int64
+	}
+
+	var found []candidate
+	err := r.store.run(ctx, func(st *state) error {
+		for userID, row := range st.users {
+			latest, located := st.latestLocations[userID]
+			if !located || !slices.Contains(ids, userID) || st.isActedOn(userID) {
+				continue
+			}
+			found = append(found, candidate{
+				Candidate: recommendation.Candidate{
+					ID:          row.ID,
+					BirthOfDate: row.BirthOfDate,
+					Place:       latest.Place,
+				},
+				updatedAt: latest.UpdatedAt,
+			})
+		}
+		return nil
+	})
+	sort.Slice(found, func(i, j int) bool {
+		return found[i].updatedAt > found[j].updatedAt
+	})
+
+	candidates := make([]recommendation.Candidate, 0, min(len(found), limit))
+	for _, c := range found[:min(len(found), limit)] {
+		candidates = append(candidates, c.Candidate)
+	}
+	return candidates, err
+}
@@ -0,0 +1,205 @@
+package memory
+
+import (
+	"context"
+	"gotinder/geo"
+	"gotinder/infra"
+	"gotinder/notification"
+	"gotinder/pagination"
+	"gotinder/subscription"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type (
+	// Store keep every table and cache in memory, standing in for postgresql and redis where Docker is not available.
+	// operations are serialized, and a transaction holds the store until it ends so it is rolled back as a whole
+	Store struct {
+		mu        sync.Mutex
+		state     *state
+		published []notification.Event
+	}
+
+	// state is a type of all tables of the store, cloned to roll back a failed transaction
+	state struct {
+		users              map[string]userRow
+		plans              []subscription.Plan
+		subscriptions      map[uuid.UUID]subscriptionRow
+		histories          []historyRow
+		actions            map[string][]actionRow
+		coupons            map[string]couponRow
+		userCoupons        []userCouponRow
+		latestLocations    map[string]latestLocationRow
+		locationHistories  []locationHistoryRow
+		cities             []cityRow
+		passports          map[string]passportRow
+		paymentSessions    map[string]paymentSessionRow
+		paymentEvents      map[string]bool
+		notificationEvents []notification.Event
+		quotas             map[string]expiring[map[string]bool]
+		lastLocations      map[string]expiring[geo.Point]
+		locationRates      map[string]expiring[int]
+		nearby             map[string]geo.Point
+	}
+
+	// expiring is a type of cached value which is gone after expiresAt
+	expiring[T any] struct {
+		value     T
+		expiresAt time.Time
+	}
+
+	storeKey struct{}
+)
+
+var _ infra.Transactor = &Store{}
+
+// NewStore give empty store seeded with plans migrations insert, cities are seeded by location.Service.SeedCities
+func NewStore() *Store {
+	return &Store{
+		state: &state{
+			users: make(map[string]userRow),
+			plans: []subscription.Plan{
+				{
+					ID:       uuid.New(),
+					Tier:     subscription.PlanFree,
+					Name:     "Free",
+					Features: []string{},
+					Quotas:   map[string]int{"daily_actions": 10},
+					Currency: "IDR",
+				},
+				{
+					ID:               uuid.New(),
+					Tier:             subscription.PlanPremium,
+					Name:             "Premium",
+					Features:         []string{"unlimited_actions", "see_likes", "passport"},
+					Quotas:           map[string]int{},
+					Price:            49000,
+					Currency:         "IDR",
+					DurationInSecond: 2592000,
+				},
+			},
+			subscriptions:   make(map[uuid.UUID]subscriptionRow),
+			actions:         make(map[string][]actionRow),
+			coupons:         make(map[string]couponRow),
+			latestLocations: make(map[string]latestLocationRow),
+			passports:       make(map[string]passportRow),
+			paymentSessions: make(map[string]paymentSessionRow),
+			paymentEvents:   make(map[string]bool),
+			quotas:          make(map[string]expiring[map[string]bool]),
+			lastLocations:   make(map[string]expiring[geo.Point]),
+			locationRates:   make(map[string]expiring[int]),
+			nearby:          make(map[string]geo.Point),
+		},
+	}
+}
+
+// WithinTx keep changes of fn only when it succeeds, nested call joins the outer transaction
+func (s *Store) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if ctx.Value(storeKey{}) == s {
+		return fn(ctx)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snapshot := s.state.clone()
+	if err := fn(context.WithValue(ctx, storeKey{}, s)); err != nil {
+		s.state = snapshot
+		return err
+	}
+	return nil
+}
+
+// Published give notification events published so far, oldest first
+func (s *Store) Published() []notification.Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.published)
+}
+
+// run call fn on tables, joining transaction the context is within. it fails once ctx is done, like queries do
+func (s *Store) run(ctx context.Context, fn func(st *state) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if ctx.Value(storeKey{}) == s {
+		return fn(s.state)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return fn(s.state)
+}
+
+// clone copy tables so changes on the copy are not seen by the original,
+// rows are replaced rather than changed in place, so copying them shallowly is enough
+func (st *state) clone() *state {
+	actions := make(map[string][]actionRow, len(st.actions))
+	for t, rows := range st.actions {
+		actions[t] = slices.Clone(rows)
+	}
+	quotas := make(map[string]expiring[map[string]bool], len(st.quotas))
+	for selfID, quota := range st.quotas {
+		quotas[selfID] = expiring[map[string]bool]{value: maps.Clone(quota.value), expiresAt: quota.expiresAt}
+	}
+
+	return &state{
+		users:              maps.Clone(st.users),
+		plans:              slices.Clone(st.plans),
+		subscriptions:      maps.Clone(st.subscriptions),
+		histories:          slices.Clone(st.histories),
+		actions:            actions,
+		coupons:            maps.Clone(st.coupons),
+		userCoupons:        slices.Clone(st.userCoupons),
+		latestLocations:    maps.Clone(st.latestLocations),
+		locationHistories:  slices.Clone(st.locationHistories),
+		cities:             slices.Clone(st.cities),
+		passports:          maps.Clone(st.passports),
+		paymentSessions:    maps.Clone(st.paymentSessions),
+		paymentEvents:      maps.Clone(st.paymentEvents),
+		notificationEvents: slices.Clone(st.notificationEvents),
+		quotas:             quotas,
+		lastLocations:      maps.Clone(st.lastLocations),
+		locationRates:      maps.Clone(st.locationRates),
+		nearby:             maps.Clone(st.nearby),
+	}
+}
+
+// alive check whether cached value is not expired yet
+func (e expiring[T]) alive(now time.Time) bool {
+	return now.Before(e.expiresAt)
+}
+
+// page give records after cursor, newest first, with one extra record so pagination.Next can tell
+// whether there is next page, like pagination.Apply does on query
+func page[T any](records []T, c *pagination.Cursor, position func(T) pagination.Cursor, limit int) []T {
+	sort.Slice(records, func(i, j int) bool {
+		return isBefore(position(records[j]), position(records[i]))
+	})
+	if c != nil {
+		records = slices.DeleteFunc(records, func(record T) bool {
+			return !isBefore(position(record), *c)
+		})
+	}
+	if len(records) > limit+1 {
+		records = records[:limit+1]
+	}
+	return records
+}
+
+// isBefore compare cursor positions the way (created_at, id) is compared on postgresql
+func isBefore(a, b pagination.Cursor) bool {
+	if a.CreatedAt != b.CreatedAt {
+		return a.CreatedAt < b.CreatedAt
+	}
+	return strings.Compare(a.ID, b.ID) < 0
+}
@@ -0,0 +1,34 @@
+package memory_test
+
+import (
+	"context"
+	"errors"
+	"gotinder/memory"
+	"gotinder/user"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStore_WithinTx(t *testing.T) {
+	store := memory.NewStore()
+	users := memory.NewUserRepository(store)
+
+	err := store.WithinTx(context.Background(), func(ctx context.Context) error {
+		if err := users.Create(ctx, user.User{Email: "rollback@mail.com"}); err != nil {
+			return err
+		}
+		return errors.New("failed")
+	})
+	assert.EqualError(t, err, "failed")
+	_, err = users.FindByEmail(context.Background(), "rollback@mail.com")
+	assert.ErrorIs(t, err, user.ErrUserNotFound)
+
+	err = store.WithinTx(context.Background(), func(ctx context.Context) error {
+		return users.Create(ctx, user.User{Email: "commit@mail.com"})
+	})
+	assert.Nil(t, err)
+	u, err := users.FindByEmail(context.Background(), "commit@mail.com")
+	assert.Nil(t, err)
+	assert.Equal(t, "commit@mail.com", u.Email)
+}
@@ -0,0 +1,279 @@
+package memory
+
+import (
+	"context"
+	"gotinder/subscription"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+type (
+	// SubscriptionRepository store plans and subscriptions on the store
+	SubscriptionRepository struct {
+		store *Store
+	}
+
+	subscriptionRow struct {
+		subscription.Subscription
+		CreatedAt        time.Time
+		ExpiryNotifiedAt *int64
+	}
+
+	historyRow struct {
+		subscription.History
+		SubscriptionID uuid.UUID
+	}
+)
+
+var _ subscription.Repository = &SubscriptionRepository{}
+
+func NewSubscriptionRepository(store *Store) *SubscriptionRepository {
+	return &SubscriptionRepository{store: store}
+}
+
+func (r *SubscriptionRepository) FindPlans(ctx context.Context) ([]subscription.Plan, error) {
+	var plans []subscription.Plan
+	err := r.store.run(ctx, func(st *state) error {
+		plans = append(make([]subscription.Plan, 0, len(st.plans)), st.plans...)
+		sort.SliceStable(plans, func(i, j int) bool {
+			return plans[i].Price < plans[j].Price
+		})
+		return nil
+	})
+	return plans, err
+}
+
+func (r *SubscriptionRepository) FindPlanByTier(ctx context.Context, tier string) (subscription.Plan, error) {
+	var plan subscription.Plan
+	err := r.store.run(ctx, func(st *state) error {
+		var found bool
+		if plan, found = st.planByTier(tier); !found {
+			return errors.Wrapf(subscription.ErrPlanNotFound, "failed to find plan %s", tier)
+		}
+		return nil
+	})
+	return plan, err
+}
+
+func (r *SubscriptionRepository) FindPlanByID(ctx context.Context, id uuid.UUID) (subscription.Plan, error) {
+	var plan subscription.Plan
+	err := r.store.run(ctx, func(st *state) error {
+		var found bool
+		if plan, found = st.planByID(id); !found {
+			return subscription.ErrPlanNotFound
+		}
+		return nil
+	})
+	return plan, err
+}
+
+func (r *SubscriptionRepository) FindLatest(ctx context.Context, userID string) (subscription.Subscription, error) {
+	var latest subscriptionRow
+	err := r.store.run(ctx, func(st *state) error {
+		var found bool
+		for _, row := range st.subscriptions {
+			if row.UserID == userID && (!found || row.CreatedAt.After(latest.CreatedAt)) {
+				latest, found = row, true
+			}
+		}
+		if !found {
+			return subscription.ErrSubscriptionNotFound
+		}
+		return nil
+	})
+	return latest.Subscription, err
+}
+
+func (r *SubscriptionRepository) FindHistory(ctx context.Context, subscriptionID uuid.UUID) ([]subscription.History, error) {
+	history := make([]subscription.History, 0)
+	err := r.store.run(ctx, func(st *state) error {
+		for _, row := range st.histories {
+			if row.SubscriptionID == subscriptionID {
+				history = append(history, row.History)
+			}
+		}
+		return nil
+	})
+	return history, err
+}
+
+func (r *SubscriptionRepository) LockCurrent(ctx context.Context, userID string) (subscription.Subscription, error) {
+	var current subscription.Subscription
+	err := r.store.run(ctx, func(st *state) error {
+		for _, row := range st.subscriptions {
+			if row.UserID == userID && (row.Status == subscription.Active || row.Status == subscription.Grace) {
+				current = row.Subscription
+				return nil
+			}
+		}
+		return subscription.ErrSubscriptionNotFound
+	})
+	return current, err
+}
+
+func (r *SubscriptionRepository) Lock(ctx context.Context, id uuid.UUID) (subscription.Subscription, error) {
+	var s subscription.Subscription
+	err := r.store.run(ctx, func(st *state) error {
+		row, found := st.subscriptions[id]
+		if !found {
+			return subscription.ErrSubscriptionNotFound
+		}
+		s = row.Subscription
+		return nil
+	})
+	return s, err
+}
+
+func (r *SubscriptionRepository) Create(ctx context.Context, s *subscription.Subscription) error {
+	return r.store.run(ctx, func(st *state) error {
+		s.ID = uuid.New()
+		st.subscriptions[s.ID] = subscriptionRow{Subscription: *s, CreatedAt: time.Now()}
+		return nil
+	})
+}
+
+func (r *SubscriptionRepository) Renew(ctx context.Context, id, planID uuid.UUID, endsAt time.Time) error {
+	return r.store.run(ctx, func(st *state) error {
+		row, found := st.subscriptions[id]
+		if !found {
+			return nil
+		}
+		row.PlanID = planID
+		row.Status = subscription.Active
+		row.EndsAt = endsAt.Unix()
+		row.GraceUntil = nil
+		row.ExpiryNotifiedAt = nil
+		st.subscriptions[id] = row
+		return nil
+	})
+}
+
+func (r *SubscriptionRepository) UpdateStatus(ctx context.Context, id uuid.UUID, status subscription.Status) error {
+	return r.store.run(ctx, func(st *state) error {
+		if row, found := st.subscriptions[id]; found {
+			row.Status = status
+			st.subscriptions[id] = row
+		}
+		return nil
+	})
+}
+
+func (r *SubscriptionRepository) RecordHistory(ctx context.Context, subscriptionID uuid.UUID, from, to subscription.Status, endsAt int64) error {
+	return r.store.run(ctx, func(st *state) error {
+		h := subscription.History{ToStatus: to, EndsAt: endsAt, CreatedAt: time.Now().Unix()}
+		if from != "" {
+			h.FromStatus = &from
+		}
+		st.histories = append(st.histories, historyRow{History: h, SubscriptionID: subscriptionID})
+		return nil
+	})
+}
+
+func (r *SubscriptionRepository) MarkExpiring(ctx context.Context, now, until time.Time) ([]subscription.Subscription, error) {
+	return r.update(ctx, func(row *subscriptionRow) bool {
+		if row.Status != subscription.Active || row.EndsAt <= now.Unix() || row.EndsAt > until.Unix() || row.ExpiryNotifiedAt != nil {
+			return false
+		}
+		notifiedAt := now.Unix()
+		row.ExpiryNotifiedAt = &notifiedAt
+		return true
+	})
+}
+
+func (r *SubscriptionRepository) EnterGrace(ctx context.Context, now time.Time, gracePeriod time.Duration) ([]subscription.Subscription, error) {
+	return r.update(ctx, func(row *subscriptionRow) bool {
+		if row.Status != subscription.Active || row.EndsAt > now.Unix() {
+			return false
+		}
+		graceUntil := row.EndsAt + int64(gracePeriod.Seconds())
+		row.Status = subscription.Grace
+		row.GraceUntil = &graceUntil
+		return true
+	})
+}
+
+func (r *SubscriptionRepository) ExpireGrace(ctx context.Context, now time.Time) ([]subscription.Subscription, error) {
+	return r.update(ctx, func(row *subscriptionRow) bool {
+		if row.Status != subscription.Grace || row.GraceUntil == nil || *row.GraceUntil > now.Unix() {
+			return false
+		}
+		row.Status = subscription.Expired
+		return true
+	})
+}
+
+func (r *SubscriptionRepository) LockSubscriber(ctx context.Context, userID string) (*int64, error) {
+	var subscribeUntil *int64
+	err := r.store.run(ctx, func(st *state) error {
+		row, found := st.users[userID]
+		if !found {
+			return subscription.ErrSubscriberNotFound
+		}
+		subscribeUntil = row.SubscribeUntil
+		return nil
+	})
+	return subscribeUntil, err
+}
+
+func (r *SubscriptionRepository) UpdateSubscriber(ctx context.Context, userID string, subscribeUntil time.Time) error {
+	return r.store.run(ctx, func(st *state) error {
+		if row, found := st.users[userID]; found {
+			until := subscribeUntil.Unix()
+			row.SubscribeUntil = &until
+			st.users[userID] = row
+		}
+		return nil
+	})
+}
+
+// update call fn on every subscription, giving subscriptions it changed
+func (r *SubscriptionRepository) update(ctx context.Context, fn func(row *subscriptionRow) bool) ([]subscription.Subscription, error) {
+	subscriptions := make([]subscription.Subscription, 0)
+	err := r.store.run(ctx, func(st *state) error {
+		for id, row := range st.subscriptions {
+			if fn(&row) {
+				st.subscriptions[id] = row
+				subscriptions = append(subscriptions, row.Subscription)
+			}
+		}
+		return nil
+	})
+	return subscriptions, err
+}
+
+// planByTier give plan of the tier
+func (st *state) planByTier(tier string) (subscription.Plan, bool) {
+	for _, plan := range st.plans {
+		if plan.Tier == tier {
+			return plan, true
+		}
+	}
+	return subscription.Plan{}, false
+}
+
+// planByID give plan of the id
+func (st *state) planByID(id uuid.UUID) (subscription.Plan, bool) {
+	for _, plan := range st.plans {
+		if plan.ID == id {
+			return plan, true
+		}
+	}
+	return subscription.Plan{}, false
+}
+
+// runningSubscription give subscription of the user which still entitles them at now
+func (st *state) runningSubscription(userID string, now int64) (subscription.Subscription, bool) {
+	for _, row := range st.subscriptions {
+		if row.UserID != userID {
+			continue
+		}
+		if (row.Status == subscription.Active && row.EndsAt > now) ||
+			(row.Status == subscription.Grace && row.GraceUntil != nil && *row.GraceUntil > now) {
+			return row.Subscription, true
+		}
+	}
+	return subscription.Subscription{}, false
+}
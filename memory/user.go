@@ -0,0 +1,111 @@
+package memory
+
+import (
+	"context"
+	"gotinder/apperr"
+	"gotinder/subscription"
+	"gotinder/user"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+type (
+	// UserRepository store users on the store
+	UserRepository struct {
+		store *Store
+	}
+
+	userRow struct {
+		user.User
+		IsAdmin        bool
+		SubscribeUntil *int64
+	}
+)
+
+var _ user.Repository = &UserRepository{}
+
+func NewUserRepository(store *Store) *UserRepository {
+	return &UserRepository{store: store}
+}
+
+// SetAdmin grant admin access to the user of the email
+func (s *Store) SetAdmin(email string) error {
+	return s.run(context.Background(), func(st *state) error {
+		row, found := st.userByEmail(email)
+		if !found {
+			return user.ErrUserNotFound
+		}
+		row.IsAdmin = true
+		st.users[row.ID.String()] = row
+		return nil
+	})
+}
+
+func (r *UserRepository) Create(ctx context.Context, u user.User) error {
+	return r.store.run(ctx, func(st *state) error {
+		if _, found := st.userByEmail(u.Email); found {
+			return apperr.ErrConflict.Wrap(errors.New("failed to record request: email already registered"))
+		}
+		u.ID = uuid.New()
+		st.users[u.ID.String()] = userRow{User: u}
+		return nil
+	})
+}
+
+func (r *UserRepository) FindByEmail(ctx context.Context, email string) (user.User, error) {
+	var u user.User
+	err := r.store.run(ctx, func(st *state) error {
+		row, found := st.userByEmail(email)
+		if !found {
+			return user.ErrUserNotFound
+		}
+		u = row.User
+		return nil
+	})
+	return u, err
+}
+
+func (r *UserRepository) FindActor(ctx context.Context, email string) (user.Actor, error) {
+	var actor user.Actor
+	err := r.store.run(ctx, func(st *state) error {
+		row, found := st.userByEmail(email)
+		if !found {
+			return user.ErrUserNotFound
+		}
+
+		now := time.Now().Unix()
+		var plan subscription.Plan
+		if current, found := st.runningSubscription(row.ID.String(), now); found {
+			plan, _ = st.planByID(current.PlanID)
+		} else {
+			// users subscribed before plans were introduced only have subscribe_until
+			tier := subscription.PlanFree
+			if row.SubscribeUntil != nil && *row.SubscribeUntil > now {
+				tier = subscription.PlanPremium
+			}
+			plan, _ = st.planByTier(tier)
+		}
+
+		actor = user.Actor{
+			ID:       row.ID.String(),
+			IsAdmin:  row.IsAdmin,
+			Tier:     plan.Tier,
+			Features: plan.Features,
+			Quotas:   plan.Quotas,
+		}
+		return nil
+	})
+	return actor, err
+}
+
+// userByEmail give user row of the email
+func (st *state) userByEmail(email string) (userRow, bool) {
+	for _, row := range st.users {
+		if row.Email == email {
+			return row, true
+		}
+	}
+	return userRow{}, false
+}
@@ -0,0 +1,119 @@
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "gotinder"
+
+type (
+	// Metrics is a type of prometheus collectors of one app, registered on its own registry
+	// so several apps in one process do not collide
+	Metrics struct {
+		registry *prometheus.Registry
+
+		Requests        *prometheus.CounterVec
+		RequestDuration *prometheus.HistogramVec
+
+		Likes                 prometheus.Counter
+		Passes                prometheus.Counter
+		QuotaRejections       prometheus.Counter
+		SubscriptionsRedeemed prometheus.Counter
+		Registrations         prometheus.Counter
+	}
+
+	// redisPoolCollector is a type of collector of redis pool connection counts
+	redisPoolCollector struct {
+		pool   *redis.Pool
+		active *prometheus.Desc
+		idle   *prometheus.Desc
+	}
+)
+
+// New give metrics with HTTP, domain, go runtime and process collectors registered
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		Requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "Total number of HTTP requests by method, route and status code.",
+		}, []string{"method", "route", "status"}),
+		RequestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Latency of HTTP requests by method and route.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+		Likes:                 newCounter("likes_total", "Total number of likes."),
+		Passes:                newCounter("passes_total", "Total number of passes."),
+		QuotaRejections:       newCounter("quota_rejections_total", "Total number of actions rejected by daily quota."),
+		SubscriptionsRedeemed: newCounter("subscriptions_redeemed_total", "Total number of subscriptions redeemed by coupon."),
+		Registrations:         newCounter("registrations_total", "Total number of registered users."),
+	}
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.Requests,
+		m.RequestDuration,
+		m.Likes,
+		m.Passes,
+		m.QuotaRejections,
+		m.SubscriptionsRedeemed,
+		m.Registrations,
+	)
+	return m
+}
+
+// RegisterDB register connection pool stats of postgresql
+func (m *Metrics) RegisterDB(db *sql.DB) {
+	m.registry.MustRegister(collectors.NewDBStatsCollector(db, "postgres"))
+}
+
+// RegisterRedisPool register active and idle connection counts of redis pool
+func (m *Metrics) RegisterRedisPool(pool *redis.Pool) {
+	m.registry.MustRegister(&redisPoolCollector{
+		pool: pool,
+		active: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "redis_pool", "active_connections"),
+			"Number of connections in the pool, including idle ones.",
+			nil, nil,
+		),
+		idle: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "redis_pool", "idle_connections"),
+			"Number of idle connections in the pool.",
+			nil, nil,
+		),
+	})
+}
+
+// Handler give HTTP handler exposing the metrics in prometheus format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
+
+func (c *redisPoolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.active
+	ch <- c.idle
+}
+
+func (c *redisPoolCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.pool.Stats()
+	ch <- prometheus.MustNewConstMetric(c.active, prometheus.GaugeValue, float64(stats.ActiveCount))
+	ch <- prometheus.MustNewConstMetric(c.idle, prometheus.GaugeValue, float64(stats.IdleCount))
+}
+
+// newCounter give counter of the app namespace
+func newCounter(name, help string) prometheus.Counter {
+	return prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      name,
+		Help:      help,
+	})
+}
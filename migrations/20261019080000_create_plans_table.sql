@@ -0,0 +1,23 @@
+-- migrate:up
+CREATE TABLE IF NOT EXISTS plans (
+  id uuid NOT NULL PRIMARY KEY DEFAULT uuid_generate_v4(),
+  created_at BIGINT NOT NULL DEFAULT DATE_PART('EPOCH', NOW()),
+  tier VARCHAR(32) NOT NULL,
+  name VARCHAR(255) NOT NULL,
+  features TEXT[] NOT NULL DEFAULT '{}',
+  quotas JSONB NOT NULL DEFAULT '{}',
+  price BIGINT NOT NULL DEFAULT 0,
+  currency VARCHAR(3) NOT NULL DEFAULT 'IDR',
+  duration_in_second INT NOT NULL DEFAULT 0
+);
+
+CREATE UNIQUE INDEX uidx_plans_tier ON plans(tier);
+
+INSERT INTO plans (tier, name, features, quotas, price, duration_in_second) VALUES
+  ('free', 'Free', '{}', '{"daily_actions": 10}', 0, 0),
+  ('premium', 'Premium', '{unlimited_actions,see_likes}', '{}', 49000, 2592000);
+
+-- migrate:down
+DROP INDEX uidx_plans_tier;
+
+DROP TABLE IF EXISTS plans;
@@ -0,0 +1,52 @@
+-- migrate:up
+CREATE TABLE IF NOT EXISTS subscriptions (
+  id uuid NOT NULL PRIMARY KEY DEFAULT uuid_generate_v4(),
+  created_at BIGINT NOT NULL DEFAULT DATE_PART('EPOCH', NOW()),
+  updated_at BIGINT NOT NULL DEFAULT DATE_PART('EPOCH', NOW()),
+  user_id uuid NOT NULL,
+  plan_id uuid NOT NULL,
+  status VARCHAR(16) NOT NULL,
+  started_at BIGINT NOT NULL,
+  ends_at BIGINT NOT NULL,
+  grace_until BIGINT,
+  CONSTRAINT chk_subscriptions_status CHECK (status IN ('active', 'grace', 'cancelled', 'expired')),
+  CONSTRAINT fk_users_subscriptions FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+  CONSTRAINT fk_plans_subscriptions FOREIGN KEY (plan_id) REFERENCES plans(id)
+);
+
+CREATE UNIQUE INDEX uidx_subscriptions_user_id_current ON subscriptions(user_id) WHERE status IN ('active', 'grace');
+
+CREATE TABLE IF NOT EXISTS subscription_histories (
+  id uuid NOT NULL PRIMARY KEY DEFAULT uuid_generate_v4(),
+  created_at BIGINT NOT NULL DEFAULT DATE_PART('EPOCH', NOW()),
+  subscription_id uuid NOT NULL,
+  from_status VARCHAR(16),
+  to_status VARCHAR(16) NOT NULL,
+  ends_at BIGINT NOT NULL,
+  CONSTRAINT fk_subscriptions_subscription_histories FOREIGN KEY (subscription_id) REFERENCES subscriptions(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_subscription_histories_subscription_id ON subscription_histories(subscription_id);
+
+INSERT INTO subscriptions (user_id, plan_id, status, started_at, ends_at)
+SELECT
+  users.id,
+  plans.id,
+  CASE WHEN users.subscribe_until > DATE_PART('EPOCH', NOW()) THEN 'active' ELSE 'expired' END,
+  users.updated_at,
+  users.subscribe_until
+FROM users
+INNER JOIN plans ON plans.tier = 'premium'
+WHERE users.subscribe_until IS NOT NULL;
+
+INSERT INTO subscription_histories (subscription_id, to_status, ends_at)
+SELECT id, status, ends_at FROM subscriptions;
+
+-- migrate:down
+DROP INDEX idx_subscription_histories_subscription_id;
+
+DROP TABLE IF EXISTS subscription_histories;
+
+DROP INDEX uidx_subscriptions_user_id_current;
+
+DROP TABLE IF EXISTS subscriptions;
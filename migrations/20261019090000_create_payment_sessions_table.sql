@@ -0,0 +1,36 @@
+-- migrate:up
+CREATE TABLE IF NOT EXISTS payment_sessions (
+  id uuid NOT NULL PRIMARY KEY DEFAULT uuid_generate_v4(),
+  created_at BIGINT NOT NULL DEFAULT DATE_PART('EPOCH', NOW()),
+  provider VARCHAR(32) NOT NULL,
+  provider_session_id VARCHAR(255) NOT NULL,
+  user_id uuid NOT NULL,
+  plan_id uuid NOT NULL,
+  subscription_id uuid,
+  CONSTRAINT fk_users_payment_sessions FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+  CONSTRAINT fk_plans_payment_sessions FOREIGN KEY (plan_id) REFERENCES plans(id),
+  CONSTRAINT fk_subscriptions_payment_sessions FOREIGN KEY (subscription_id) REFERENCES subscriptions(id) ON DELETE SET NULL
+);
+
+CREATE UNIQUE INDEX uidx_payment_sessions_provider_session ON payment_sessions(provider, provider_session_id);
+
+CREATE TABLE IF NOT EXISTS payment_events (
+  id uuid NOT NULL PRIMARY KEY DEFAULT uuid_generate_v4(),
+  created_at BIGINT NOT NULL DEFAULT DATE_PART('EPOCH', NOW()),
+  provider VARCHAR(32) NOT NULL,
+  provider_event_id VARCHAR(255) NOT NULL,
+  type VARCHAR(64) NOT NULL,
+  payment_session_id uuid NOT NULL,
+  CONSTRAINT fk_payment_sessions_payment_events FOREIGN KEY (payment_session_id) REFERENCES payment_sessions(id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX uidx_payment_events_provider_event ON payment_events(provider, provider_event_id);
+
+-- migrate:down
+DROP INDEX uidx_payment_events_provider_event;
+
+DROP TABLE IF EXISTS payment_events;
+
+DROP INDEX uidx_payment_sessions_provider_session;
+
+DROP TABLE IF EXISTS payment_sessions;
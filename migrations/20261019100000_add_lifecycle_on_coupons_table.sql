@@ -0,0 +1,25 @@
+-- migrate:up
+ALTER TABLE coupons
+  ADD COLUMN max_redemptions INT,
+  ADD COLUMN max_redemptions_per_user INT NOT NULL DEFAULT 1,
+  ADD COLUMN revoked_at BIGINT,
+  ADD COLUMN campaign VARCHAR(255),
+  ADD COLUMN plan_id uuid,
+  ADD CONSTRAINT fk_plans_coupons FOREIGN KEY (plan_id) REFERENCES plans(id);
+
+CREATE INDEX idx_coupons_campaign ON coupons(campaign);
+
+CREATE INDEX idx_user_coupons_coupon_id ON user_coupons(coupon_id);
+
+-- migrate:down
+DROP INDEX idx_user_coupons_coupon_id;
+
+DROP INDEX idx_coupons_campaign;
+
+ALTER TABLE coupons
+  DROP CONSTRAINT fk_plans_coupons,
+  DROP COLUMN plan_id,
+  DROP COLUMN campaign,
+  DROP COLUMN revoked_at,
+  DROP COLUMN max_redemptions_per_user,
+  DROP COLUMN max_redemptions;
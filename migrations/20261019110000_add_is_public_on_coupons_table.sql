@@ -0,0 +1,5 @@
+-- migrate:up
+ALTER TABLE coupons ADD COLUMN is_public BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- migrate:down
+ALTER TABLE coupons DROP COLUMN is_public;
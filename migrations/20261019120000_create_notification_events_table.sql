@@ -0,0 +1,24 @@
+-- migrate:up
+ALTER TABLE subscriptions ADD COLUMN expiry_notified_at BIGINT;
+
+CREATE INDEX idx_subscriptions_status_ends_at ON subscriptions(status, ends_at);
+
+CREATE TABLE IF NOT EXISTS notification_events (
+  id uuid NOT NULL PRIMARY KEY DEFAULT uuid_generate_v4(),
+  created_at BIGINT NOT NULL DEFAULT DATE_PART('EPOCH', NOW()),
+  user_id uuid NOT NULL,
+  type VARCHAR(64) NOT NULL,
+  payload JSONB NOT NULL DEFAULT '{}',
+  CONSTRAINT fk_users_notification_events FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_notification_events_user_id ON notification_events(user_id, created_at);
+
+-- migrate:down
+DROP INDEX idx_notification_events_user_id;
+
+DROP TABLE IF EXISTS notification_events;
+
+DROP INDEX idx_subscriptions_status_ends_at;
+
+ALTER TABLE subscriptions DROP COLUMN expiry_notified_at;
@@ -0,0 +1,30 @@
+-- migrate:up
+CREATE TABLE IF NOT EXISTS location_histories (
+  id uuid NOT NULL PRIMARY KEY DEFAULT uuid_generate_v4(),
+  created_at BIGINT NOT NULL DEFAULT DATE_PART('EPOCH', NOW()),
+  lat REAL NOT NULL,
+  lng REAL NOT NULL,
+  location geography(Point, 4326) NOT NULL,
+  user_id uuid NOT NULL,
+  CONSTRAINT fk_users_location_histories FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_location_histories_user_id ON location_histories(user_id, created_at);
+
+CREATE INDEX idx_location_histories_created_at ON location_histories(created_at);
+
+CREATE TRIGGER generate_location_trigger
+BEFORE INSERT OR UPDATE ON location_histories
+FOR each ROW EXECUTE PROCEDURE generate_location();
+
+INSERT INTO location_histories (created_at, lat, lng, user_id)
+SELECT updated_at, lat, lng, user_id FROM latest_locations;
+
+-- migrate:down
+DROP TRIGGER generate_location_trigger ON location_histories;
+
+DROP INDEX idx_location_histories_created_at;
+
+DROP INDEX idx_location_histories_user_id;
+
+DROP TABLE IF EXISTS location_histories;
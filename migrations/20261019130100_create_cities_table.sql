@@ -0,0 +1,23 @@
+-- migrate:up
+CREATE TABLE IF NOT EXISTS cities (
+  id uuid NOT NULL PRIMARY KEY DEFAULT uuid_generate_v4(),
+  name VARCHAR(255) NOT NULL,
+  country_code VARCHAR(2) NOT NULL,
+  population BIGINT NOT NULL DEFAULT 0,
+  lat REAL NOT NULL,
+  lng REAL NOT NULL,
+  location geography(Point, 4326) NOT NULL
+);
+
+CREATE UNIQUE INDEX uidx_cities_name_country_code ON cities(LOWER(name), country_code);
+
+CREATE TRIGGER generate_location_trigger
+BEFORE INSERT OR UPDATE ON cities
+FOR each ROW EXECUTE PROCEDURE generate_location();
+
+-- migrate:down
+DROP TRIGGER generate_location_trigger ON cities;
+
+DROP INDEX uidx_cities_name_country_code;
+
+DROP TABLE IF EXISTS cities;
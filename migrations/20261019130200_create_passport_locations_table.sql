@@ -0,0 +1,29 @@
+-- migrate:up
+CREATE TABLE IF NOT EXISTS passport_locations (
+  id uuid NOT NULL PRIMARY KEY DEFAULT uuid_generate_v4(),
+  updated_at BIGINT NOT NULL,
+  lat REAL NOT NULL,
+  lng REAL NOT NULL,
+  location geography(Point, 4326) NOT NULL,
+  city_id uuid,
+  user_id uuid NOT NULL,
+  CONSTRAINT fk_users_passport_locations FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+  CONSTRAINT fk_cities_passport_locations FOREIGN KEY (city_id) REFERENCES cities(id) ON DELETE SET NULL
+);
+
+CREATE UNIQUE INDEX uidx_passport_locations_user_id ON passport_locations(user_id);
+
+CREATE TRIGGER generate_location_trigger
+BEFORE INSERT OR UPDATE ON passport_locations
+FOR each ROW EXECUTE PROCEDURE generate_location();
+
+UPDATE plans SET features = array_append(features, 'passport') WHERE tier = 'premium';
+
+-- migrate:down
+UPDATE plans SET features = array_remove(features, 'passport') WHERE tier = 'premium';
+
+DROP TRIGGER generate_location_trigger ON passport_locations;
+
+DROP INDEX uidx_passport_locations_user_id;
+
+DROP TABLE IF EXISTS passport_locations;
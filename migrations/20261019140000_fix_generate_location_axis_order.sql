@@ -0,0 +1,39 @@
+-- migrate:up
+CREATE OR REPLACE function generate_location ()
+RETURNS trigger LANGUAGE plpgsql as $$
+BEGIN
+    new.location := ST_SetSRID(ST_MakePoint(new.lng,new.lat), 4326);
+    return new;
+END $$;
+
+UPDATE latest_locations SET location = ST_SetSRID(ST_MakePoint(lng,lat), 4326);
+
+UPDATE location_histories SET location = ST_SetSRID(ST_MakePoint(lng,lat), 4326);
+
+UPDATE cities SET location = ST_SetSRID(ST_MakePoint(lng,lat), 4326);
+
+UPDATE passport_locations SET location = ST_SetSRID(ST_MakePoint(lng,lat), 4326);
+
+CREATE INDEX idx_latest_locations_location ON latest_locations USING GIST (location);
+
+CREATE INDEX idx_cities_location ON cities USING GIST (location);
+
+-- migrate:down
+DROP INDEX idx_cities_location;
+
+DROP INDEX idx_latest_locations_location;
+
+CREATE OR REPLACE function generate_location ()
+RETURNS trigger LANGUAGE plpgsql as $$
+BEGIN
+    new.location := ST_SetSRID(ST_MakePoint(new.lat,new.lng), 4326);
+    return new;
+END $$;
+
+UPDATE latest_locations SET location = ST_SetSRID(ST_MakePoint(lat,lng), 4326);
+
+UPDATE location_histories SET location = ST_SetSRID(ST_MakePoint(lat,lng), 4326);
+
+UPDATE cities SET location = ST_SetSRID(ST_MakePoint(lat,lng), 4326);
+
+UPDATE passport_locations SET location = ST_SetSRID(ST_MakePoint(lat,lng), 4326);
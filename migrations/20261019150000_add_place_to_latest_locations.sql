@@ -0,0 +1,9 @@
+-- migrate:up
+ALTER TABLE latest_locations ADD COLUMN city VARCHAR(255);
+
+ALTER TABLE latest_locations ADD COLUMN country_code VARCHAR(2);
+
+-- migrate:down
+ALTER TABLE latest_locations DROP COLUMN country_code;
+
+ALTER TABLE latest_locations DROP COLUMN city;
@@ -0,0 +1,9 @@
+-- migrate:up
+ALTER TABLE payment_sessions ADD COLUMN paid_until BIGINT NOT NULL DEFAULT 0;
+
+ALTER TABLE payment_sessions ADD COLUMN paid_in_second BIGINT NOT NULL DEFAULT 0;
+
+-- migrate:down
+ALTER TABLE payment_sessions DROP COLUMN paid_in_second;
+
+ALTER TABLE payment_sessions DROP COLUMN paid_until;
@@ -0,0 +1,6 @@
+-- migrate:up
+-- rows recorded before jittering keep real location until the backfill of location.Service.JitterLatest runs
+ALTER TABLE latest_locations ADD COLUMN jittered BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- migrate:down
+ALTER TABLE latest_locations DROP COLUMN jittered;
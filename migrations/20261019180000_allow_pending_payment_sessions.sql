@@ -0,0 +1,7 @@
+-- migrate:up
+ALTER TABLE payment_sessions ALTER COLUMN provider_session_id DROP NOT NULL;
+
+-- migrate:down
+DELETE FROM payment_sessions WHERE provider_session_id IS NULL;
+
+ALTER TABLE payment_sessions ALTER COLUMN provider_session_id SET NOT NULL;
@@ -0,0 +1,7 @@
+-- migrate:up
+ALTER TABLE subscription_histories ADD COLUMN recorded_at TIMESTAMP(6) WITH TIME ZONE NOT NULL DEFAULT CLOCK_TIMESTAMP();
+
+UPDATE subscription_histories SET recorded_at = TO_TIMESTAMP(created_at);
+
+-- migrate:down
+ALTER TABLE subscription_histories DROP COLUMN recorded_at;
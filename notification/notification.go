@@ -0,0 +1,34 @@
+package notification
+
+import (
+	"context"
+)
+
+const (
+	SubscriptionExpiring = "subscription.expiring"
+	SubscriptionGrace    = "subscription.grace"
+	SubscriptionExpired  = "subscription.expired"
+)
+
+type (
+	// Event is a type of event to be delivered to the user
+	Event struct {
+		ID        string                 `json:"id"`
+		CreatedAt int64                  `json:"created_at"`
+		UserID    string                 `json:"user_id"`
+		Type      string                 `json:"type"`
+		Payload   map[string]interface{} `json:"payload"`
+	}
+
+	// Repository is an interface of notification event storage
+	Repository interface {
+		// Record store the event, filling its id and creation time.
+		// within transaction it is only emitted when the change is committed
+		Record(ctx context.Context, event *Event) error
+	}
+
+	// Publisher is an interface of delivering committed events to consumers
+	Publisher interface {
+		Publish(ctx context.Context, events []Event) error
+	}
+)
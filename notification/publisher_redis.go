@@ -0,0 +1,47 @@
+package notification
+
+import (
+	"context"
+	"encoding/json"
+	"gotinder/infra"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/pkg/errors"
+)
+
+// redisChannel is redis channel where recorded notification events are published
+const redisChannel = "notification-events"
+
+// RedisPublisher publish events on redis channel, events stay recorded for consumer which missed them
+type RedisPublisher struct {
+	pool *redis.Pool
+}
+
+var _ Publisher = &RedisPublisher{}
+
+func NewRedisPublisher(pool *redis.Pool) *RedisPublisher {
+	return &RedisPublisher{pool: pool}
+}
+
+func (p *RedisPublisher) Publish(ctx context.Context, events []Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	cacheConn, err := infra.RedisConn(ctx, p.pool)
+	if err != nil {
+		return err
+	}
+	defer cacheConn.Close()
+
+	for _, event := range events {
+		message, err := json.Marshal(event)
+		if err != nil {
+			return errors.Wrap(err, "failed to encode notification event")
+		}
+		if _, err := cacheConn.Do("PUBLISH", redisChannel, message); err != nil {
+			return errors.Wrap(err, "failed to publish notification event")
+		}
+	}
+	return nil
+}
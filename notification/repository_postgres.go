@@ -0,0 +1,45 @@
+package notification
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"gotinder/infra"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/pkg/errors"
+)
+
+// PostgresRepository store notification events on postgresql
+type PostgresRepository struct {
+	db *sql.DB
+}
+
+var _ Repository = &PostgresRepository{}
+
+func NewPostgresRepository(db *sql.DB) *PostgresRepository {
+	return &PostgresRepository{db: db}
+}
+
+func (r *PostgresRepository) Record(ctx context.Context, event *Event) error {
+	payload, err := json.Marshal(event.Payload)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode notification payload")
+	}
+
+	event.CreatedAt = time.Now().Unix()
+	if err := sq.
+		StatementBuilder.
+		PlaceholderFormat(sq.Dollar).
+		Insert("notification_events").
+		Columns("created_at", "user_id", "type", "payload").
+		Values(event.CreatedAt, event.UserID, event.Type, payload).
+		Suffix("RETURNING id").
+		RunWith(infra.PgRunner(ctx, r.db)).
+		QueryRowContext(ctx).
+		Scan(&event.ID); err != nil {
+		return errors.Wrap(err, "failed to record notification event")
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package pagination
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+type (
+	// Cursor is a type of keyset pagination position, pointing to the last returned record
+	Cursor struct {
+		CreatedAt int64
+		ID        string
+	}
+)
+
+// Encode serialize cursor into opaque string for client
+func (c Cursor) Encode() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d:%s", c.CreatedAt, c.ID)))
+}
+
+// Decode parse opaque string from client into cursor, empty string means first page
+func Decode(raw string) (*Cursor, error) {
+	if raw == "" {
+		return nil, nil
+	}
+
+	decoded, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	createdAt, id, found := strings.Cut(string(decoded), ":")
+	if !found {
+		return nil, ErrInvalidCursor
+	}
+
+	c := new(Cursor)
+	if c.CreatedAt, err = strconv.ParseInt(createdAt, 10, 64); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	c.ID = id
+
+	return c, nil
+}
+
+// Apply narrow down query to records after cursor, ordered by newest first.
+// one extra record is fetched so Next can tell whether there is next page
+func Apply(query sq.SelectBuilder, c *Cursor, createdAtColumn, idColumn string, limit int) sq.SelectBuilder {
+	if c != nil {
+		query = query.Where(
+			fmt.Sprintf("(%s, %s) < (?, ?)", createdAtColumn, idColumn),
+			c.CreatedAt,
+			c.ID,
+		)
+	}
+	return query.
+		OrderBy(fmt.Sprintf("%s DESC", createdAtColumn), fmt.Sprintf("%s DESC", idColumn)).
+		Limit(uint64(limit) + 1)
+}
+
+// Next trim extra record fetched by Apply and give encoded cursor for next page if any
+func Next[T any](records []T, limit int, position func(T) Cursor) ([]T, string) {
+	if len(records) <= limit {
+		return records, ""
+	}
+	records = records[:limit]
+	return records, position(records[limit-1]).Encode()
+}
@@ -0,0 +1,59 @@
+package payment
+
+import (
+	"context"
+	"gotinder/infra"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrNotPurchasable  = errors.New("plan is not purchasable")
+	ErrSessionNotFound = errors.New("payment session not found")
+	ErrProviderFailure = errors.New("payment provider failure")
+	ErrEventIgnored    = errors.New("event ignored")
+	ErrEventProcessed  = errors.New("event already processed")
+	ErrPeriodEnded     = errors.New("paid period already ended")
+)
+
+type (
+	// Session is a type of recorded checkout session along with tier of the plan being paid.
+	// PaidUntil is when its paid period ends, PaidInSecond is subscription time it granted beyond
+	// time granted otherwise, so only that time is revoked on cancellation
+	Session struct {
+		ID             string
+		UserID         string
+		PlanTier       string
+		SubscriptionID uuid.NullUUID
+		PaidUntil      int64
+		PaidInSecond   int64
+	}
+
+	// Repository is an interface of payment storage, scoped by provider name
+	Repository interface {
+		// CreateSession record pending checkout session not yet created on the provider, giving its ID
+		CreateSession(ctx context.Context, provider, userID string, planID uuid.UUID) (string, error)
+		// LinkProviderSession set ID given by the provider to pending checkout session
+		LinkProviderSession(ctx context.Context, sessionID, providerSessionID string) error
+		// LockSession find and lock checkout session until the transaction ends
+		LockSession(ctx context.Context, provider, providerSessionID string) (Session, error)
+		// RecordEvent record event for idempotency, false means event was recorded before
+		RecordEvent(ctx context.Context, provider, sessionID string, event infra.PaymentEvent) (bool, error)
+		// RecordActivation link session to the subscription it activated along with its paid time so far
+		RecordActivation(ctx context.Context, sessionID string, subscriptionID uuid.UUID, paidUntil, paidInSecond int64) error
+	}
+
+	// providerError is a type of error returned by payment provider
+	providerError struct {
+		err error
+	}
+)
+
+func (e providerError) Error() string {
+	return errors.Wrap(e.err, "failed to create checkout session").Error()
+}
+
+func (e providerError) Is(target error) bool {
+	return target == ErrProviderFailure
+}
@@ -0,0 +1,108 @@
+package payment
+
+import (
+	"context"
+	"database/sql"
+	"gotinder/infra"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// PostgresRepository store payment sessions and events on postgresql
+type PostgresRepository struct {
+	db *sql.DB
+}
+
+var _ Repository = &PostgresRepository{}
+
+func NewPostgresRepository(db *sql.DB) *PostgresRepository {
+	return &PostgresRepository{db: db}
+}
+
+var psql = sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+func (r *PostgresRepository) CreateSession(ctx context.Context, provider, userID string, planID uuid.UUID) (string, error) {
+	var id string
+	if err := psql.
+		Insert("payment_sessions").
+		Columns("provider", "user_id", "plan_id").
+		Values(provider, userID, planID).
+		Suffix("RETURNING id").
+		RunWith(infra.PgRunner(ctx, r.db)).
+		QueryRowContext(ctx).
+		Scan(&id); err != nil {
+		return "", errors.Wrap(err, "failed to record checkout session")
+	}
+	return id, nil
+}
+
+func (r *PostgresRepository) LinkProviderSession(ctx context.Context, sessionID, providerSessionID string) error {
+	if _, err := psql.
+		Update("payment_sessions").
+		Set("provider_session_id", providerSessionID).
+		Where("id = ?", sessionID).
+		RunWith(infra.PgRunner(ctx, r.db)).
+		ExecContext(ctx); err != nil {
+		return errors.Wrap(err, "failed to link checkout session")
+	}
+	return nil
+}
+
+func (r *PostgresRepository) LockSession(ctx context.Context, provider, providerSessionID string) (Session, error) {
+	var session Session
+	err := psql.
+		Select(
+			"payment_sessions.id",
+			"payment_sessions.user_id",
+			"plans.tier",
+			"payment_sessions.subscription_id",
+			"payment_sessions.paid_until",
+			"payment_sessions.paid_in_second",
+		).
+		From("payment_sessions").
+		InnerJoin("plans ON plans.id = payment_sessions.plan_id").
+		Where("payment_sessions.provider = ?", provider).
+		Where("payment_sessions.provider_session_id = ?", providerSessionID).
+		Suffix("FOR UPDATE OF payment_sessions").
+		RunWith(infra.PgRunner(ctx, r.db)).
+		QueryRowContext(ctx).
+		Scan(&session.ID, &session.UserID, &session.PlanTier, &session.SubscriptionID, &session.PaidUntil, &session.PaidInSecond)
+	if errors.Is(err, sql.ErrNoRows) {
+		return session, ErrSessionNotFound
+	}
+	return session, err
+}
+
+func (r *PostgresRepository) RecordEvent(ctx context.Context, provider, sessionID string, event infra.PaymentEvent) (bool, error) {
+	result, err := psql.
+		Insert("payment_events").
+		Columns("provider", "provider_event_id", "type", "payment_session_id").
+		Values(provider, event.ID, event.Type, sessionID).
+		Suffix("ON CONFLICT (provider, provider_event_id) DO NOTHING").
+		RunWith(infra.PgRunner(ctx, r.db)).
+		ExecContext(ctx)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to record payment event")
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func (r *PostgresRepository) RecordActivation(ctx context.Context, sessionID string, subscriptionID uuid.UUID, paidUntil, paidInSecond int64) error {
+	if _, err := psql.
+		Update("payment_sessions").
+		Set("subscription_id", subscriptionID).
+		Set("paid_until", paidUntil).
+		Set("paid_in_second", paidInSecond).
+		Where("id = ?", sessionID).
+		RunWith(infra.PgRunner(ctx, r.db)).
+		ExecContext(ctx); err != nil {
+		return errors.Wrap(err, "failed to link payment session")
+	}
+	return nil
+}
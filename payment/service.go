@@ -0,0 +1,126 @@
+package payment
+
+import (
+	"context"
+	"gotinder/infra"
+	"gotinder/subscription"
+	"net/http"
+	"time"
+)
+
+// Service is a type of payment business logic
+type Service struct {
+	repo          Repository
+	tx            infra.Transactor
+	provider      infra.PaymentProvider
+	subscriptions *subscription.Service
+}
+
+func NewService(repo Repository, tx infra.Transactor, provider infra.PaymentProvider, subscriptions *subscription.Service) *Service {
+	return &Service{
+		repo:          repo,
+		tx:            tx,
+		provider:      provider,
+		subscriptions: subscriptions,
+	}
+}
+
+// Checkout start payment of the plan of the tier for the user. pending session is recorded before
+// the provider creates one, so every checkout url given out can be matched by its webhook
+func (s *Service) Checkout(ctx context.Context, userID, tier string) (infra.CheckoutSession, error) {
+	plan, err := s.subscriptions.PlanByTier(ctx, tier)
+	if err != nil {
+		return infra.CheckoutSession{}, err
+	}
+	if plan.Price <= 0 {
+		return infra.CheckoutSession{}, ErrNotPurchasable
+	}
+
+	sessionID, err := s.repo.CreateSession(ctx, s.provider.Name(), userID, plan.ID)
+	if err != nil {
+		return infra.CheckoutSession{}, err
+	}
+
+	session, err := s.provider.CreateCheckoutSession(infra.CheckoutRequest{
+		UserID:   userID,
+		PlanTier: plan.Tier,
+		Amount:   plan.Price,
+		Currency: plan.Currency,
+	})
+	if err != nil {
+		return infra.CheckoutSession{}, providerError{err: err}
+	}
+
+	if err := s.repo.LinkProviderSession(ctx, sessionID, session.ID); err != nil {
+		return infra.CheckoutSession{}, err
+	}
+	return session, nil
+}
+
+// ParseWebhook verify signature of webhook request and decode its event
+func (s *Service) ParseWebhook(payload []byte, header http.Header) (infra.PaymentEvent, error) {
+	return s.provider.ParseWebhook(payload, header)
+}
+
+// Apply update subscription of the session owner based on the event within one transaction,
+// redelivered event is reported as ErrEventProcessed without being applied twice.
+// activation or renewal whose paid period is missing or already ended is rejected
+func (s *Service) Apply(ctx context.Context, event infra.PaymentEvent) error {
+	switch event.Type {
+	case infra.PaymentEventActivated, infra.PaymentEventRenewed:
+		if event.PeriodEnd <= time.Now().Unix() {
+			return ErrPeriodEnded
+		}
+	case infra.PaymentEventCancelled:
+	default:
+		return ErrEventIgnored
+	}
+
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		session, err := s.repo.LockSession(ctx, s.provider.Name(), event.SessionID)
+		if err != nil {
+			return err
+		}
+
+		isNew, err := s.repo.RecordEvent(ctx, s.provider.Name(), session.ID, event)
+		if err != nil {
+			return err
+		}
+		if !isNew {
+			return ErrEventProcessed
+		}
+
+		if event.Type == infra.PaymentEventCancelled {
+			return s.cancel(ctx, session)
+		}
+		return s.activate(ctx, session, time.Unix(event.PeriodEnd, 0))
+	})
+}
+
+// activate activate or renew session owner's subscription until periodEnd and link it to the session,
+// along with time it granted
+func (s *Service) activate(ctx context.Context, session Session, periodEnd time.Time) error {
+	subscriptionID, granted, err := s.subscriptions.Activate(ctx, session.UserID, session.PlanTier, periodEnd)
+	if err != nil {
+		return err
+	}
+	paidInSecond := session.PaidInSecond + int64(granted/time.Second)
+	return s.repo.RecordActivation(ctx, session.ID, subscriptionID, periodEnd.Unix(), paidInSecond)
+}
+
+// cancel cancel subscription activated by the session, revoking its paid time left.
+// time granted otherwise (e.g. by coupons) is kept
+func (s *Service) cancel(ctx context.Context, session Session) error {
+	if !session.SubscriptionID.Valid {
+		return nil
+	}
+	left := session.PaidUntil - time.Now().Unix()
+	if left > session.PaidInSecond {
+		left = session.PaidInSecond
+	}
+	if left < 0 {
+		left = 0
+	}
+	_, err := s.subscriptions.Cancel(ctx, session.UserID, session.SubscriptionID.UUID, time.Duration(left)*time.Second)
+	return err
+}
@@ -0,0 +1,43 @@
+package recommendation
+
+import (
+	"context"
+	"gotinder/geo"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// ErrOriginNotFound is reported when the user is unknown or has no location to be recommended around
+var ErrOriginNotFound = errors.New("user not found")
+
+type (
+	// Recommendation is a type of recommended user
+	Recommendation struct {
+		ID          uuid.UUID
+		BirthOfDate int64
+		Distance    geo.Distance
+		// Place is nil when location of the user is not resolved to any place
+		Place *geo.Place
+	}
+
+	// Candidate is a type of user who can be recommended
+	Candidate struct {
+		ID              uuid.UUID
+		BirthOfDate     int64
+		Place           *geo.Place
+		DistanceInMeter float64
+	}
+
+	// Repository is an interface of recommendation storage
+	Repository interface {
+		// FindOrigin give id and location of the user of the email,
+		// usePassport put the user on their passport location when they have one
+		FindOrigin(ctx context.Context, email string, usePassport bool) (string, geo.Point, error)
+		// FindCandidates give the users of ids who are not yet liked nor passed by anyone, most recently active first
+		FindCandidates(ctx context.Context, ids []string, limit int) ([]Candidate, error)
+		// FindNearbyCandidates give the users other than userID within radiusInMeter of origin who are not yet
+		// liked nor passed by anyone, most recently active first along with their distance
+		FindNearbyCandidates(ctx context.Context, userID string, origin geo.Point, radiusInMeter float64, limit int) ([]Candidate, error)
+	}
+)
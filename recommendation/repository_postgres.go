@@ -0,0 +1,125 @@
+package recommendation
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"gotinder/geo"
+	"gotinder/infra"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+// PostgresRepository find recommendations on postgresql
+type PostgresRepository struct {
+	db *sql.DB
+}
+
+var _ Repository = &PostgresRepository{}
+
+func NewPostgresRepository(db *sql.DB) *PostgresRepository {
+	return &PostgresRepository{db: db}
+}
+
+var psql = sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+func (r *PostgresRepository) FindOrigin(ctx context.Context, email string, usePassport bool) (string, geo.Point, error) {
+	lat, lng := "latest_locations.lat", "latest_locations.lng"
+	if usePassport {
+		lat = "COALESCE(passport_locations.lat, latest_locations.lat)"
+		lng = "COALESCE(passport_locations.lng, latest_locations.lng)"
+	}
+
+	var userID string
+	var origin geo.Point
+	err := psql.
+		Select("users.id", lat, lng).
+		From("users").
+		LeftJoin("latest_locations ON users.id = latest_locations.user_id").
+		LeftJoin("passport_locations ON users.id = passport_locations.user_id").
+		Where("email = ?", email).
+		Where(fmt.Sprintf("%s IS NOT NULL", lat)).
+		RunWith(infra.PgRunner(ctx, r.db)).
+		QueryRowContext(ctx).
+		Scan(&userID, &origin.Lat, &origin.Lng)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", origin, ErrOriginNotFound
+	}
+	if err != nil {
+		return "", origin, errors.Wrap(err, "failed to find user")
+	}
+	return userID, origin, nil
+}
+
+func (r *PostgresRepository) FindCandidates(ctx context.Context, ids []string, limit int) ([]Candidate, error) {
+	rows, err := psql.
+		Select("users.id", "users.birth_of_date", "latest_locations.city", "latest_locations.country_code").
+		From("users").
+		LeftJoin("passes ON passes.target_id = users.id").
+		LeftJoin("likes ON likes.target_id = users.id").
+		InnerJoin("latest_locations ON users.id = latest_locations.user_id").
+		Where("users.id = ANY(?::uuid[])", pq.Array(ids)).
+		Where("passes.self_id IS NULL").
+		Where("likes.self_id IS NULL").
+		OrderBy("latest_locations.updated_at DESC").
+		Limit(uint64(limit)).
+		RunWith(infra.PgRunner(ctx, r.db)).
+		QueryContext(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to find recommendations")
+	}
+	defer rows.Close()
+
+	candidates := make([]Candidate, 0)
+	for rows.Next() {
+		var candidate Candidate
+		var city, countryCode sql.NullString
+		if err := rows.Scan(&candidate.ID, &candidate.BirthOfDate, &city, &countryCode); err != nil {
+			return nil, err
+		}
+		if city.Valid && countryCode.Valid {
+			candidate.Place = &geo.Place{City: city.String, CountryCode: countryCode.String}
+		}
+		candidates = append(candidates, candidate)
+	}
+	return candidates, rows.Err()
+}
+
+func (r *PostgresRepository) FindNearbyCandidates(ctx context.Context, userID string, origin geo.Point, radiusInMeter float64, limit int) ([]Candidate, error) {
+	point := sq.Expr("ST_SetSRID(ST_MakePoint(?::float8, ?::float8), ?::int)", origin.Lng, origin.Lat, geo.SRID)
+	rows, err := psql.
+		Select("users.id", "users.birth_of_date", "latest_locations.city", "latest_locations.country_code").
+		Column(sq.Expr("ST_DistanceSphere(latest_locations.location::geometry, ?)", point)).
+		From("users").
+		LeftJoin("passes ON passes.target_id = users.id").
+		LeftJoin("likes ON likes.target_id = users.id").
+		InnerJoin("latest_locations ON users.id = latest_locations.user_id").
+		Where("users.id != ?", userID).
+		Where("passes.self_id IS NULL").
+		Where("likes.self_id IS NULL").
+		Where(sq.Expr("ST_DWithin(latest_locations.location, (?)::geography, ?::float8)", point, radiusInMeter)).
+		OrderBy("latest_locations.updated_at DESC").
+		Limit(uint64(limit)).
+		RunWith(infra.PgRunner(ctx, r.db)).
+		QueryContext(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to find recommendations")
+	}
+	defer rows.Close()
+
+	candidates := make([]Candidate, 0)
+	for rows.Next() {
+		var candidate Candidate
+		var city, countryCode sql.NullString
+		if err := rows.Scan(&candidate.ID, &candidate.BirthOfDate, &city, &countryCode, &candidate.DistanceInMeter); err != nil {
+			return nil, err
+		}
+		if city.Valid && countryCode.Valid {
+			candidate.Place = &geo.Place{City: city.String, CountryCode: countryCode.String}
+		}
+		candidates = append(candidates, candidate)
+	}
+	return candidates, rows.Err()
+}
@@ -0,0 +1,95 @@
+package recommendation
+
+import (
+	"context"
+	"gotinder/geo"
+	"gotinder/infra"
+)
+
+const (
+	maxDistanceInMeter = 150000
+	// nearbyCandidateLimit is how many nearest users are searched on nearby index at first,
+	// it is doubled until enough of them are not acted on or every user within the distance is searched
+	nearbyCandidateLimit = 1000
+)
+
+// Service is a type of recommendation business logic
+type Service struct {
+	repo   Repository
+	nearby infra.NearbyIndex
+	policy geo.FuzzPolicy
+}
+
+// NewService give service searching candidates on nearby index, or on the repository along with filtering out
+// acted on users when nearby is nil, e.g. when the index is the database itself
+func NewService(repo Repository, nearby infra.NearbyIndex, policy geo.FuzzPolicy) *Service {
+	return &Service{
+		repo:   repo,
+		nearby: nearby,
+		policy: policy,
+	}
+}
+
+// Find give users nearby the user of the email, most recently active first
+func (s *Service) Find(ctx context.Context, email string, usePassport bool, limit int) ([]Recommendation, error) {
+	userID, origin, err := s.repo.FindOrigin(ctx, email, usePassport)
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates []Candidate
+	if s.nearby == nil {
+		candidates, err = s.repo.FindNearbyCandidates(ctx, userID, origin, maxDistanceInMeter, limit)
+	} else {
+		candidates, err = s.findIndexedCandidates(ctx, userID, origin, limit)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	recommendations := make([]Recommendation, 0, len(candidates))
+	for _, candidate := range candidates {
+		recommendations = append(recommendations, Recommendation{
+			ID:          candidate.ID,
+			BirthOfDate: candidate.BirthOfDate,
+			Distance:    s.policy.Bucket(candidate.DistanceInMeter),
+			Place:       candidate.Place,
+		})
+	}
+	return recommendations, nil
+}
+
+// findIndexedCandidates search nearest users on nearby index, widening the search while too few of them are
+// left once acted on ones are filtered out and there are more users within the distance
+func (s *Service) findIndexedCandidates(ctx context.Context, userID string, origin geo.Point, limit int) ([]Candidate, error) {
+	for count := nearbyCandidateLimit; ; count *= 2 {
+		nearby, err := s.nearby.Search(ctx, origin, maxDistanceInMeter, count)
+		if err != nil {
+			return nil, err
+		}
+
+		distances := make(map[string]float64, len(nearby))
+		ids := make([]string, 0, len(nearby))
+		for _, candidate := range nearby {
+			if candidate.UserID == userID {
+				continue
+			}
+			distances[candidate.UserID] = candidate.DistanceInMeter
+			ids = append(ids, candidate.UserID)
+		}
+		if len(ids) == 0 {
+			return []Candidate{}, nil
+		}
+
+		candidates, err := s.repo.FindCandidates(ctx, ids, limit)
+		if err != nil {
+			return nil, err
+		}
+		if len(candidates) >= limit || len(nearby) < count {
+			for i := range candidates {
+				candidates[i].DistanceInMeter = distances[candidates[i].ID.String()]
+			}
+			return candidates, nil
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package recommendation_test
+
+import (
+	"context"
+	"fmt"
+	"gotinder/geo"
+	"gotinder/infra"
+	"gotinder/recommendation"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type (
+	stubRepository struct {
+		ids     map[string]uuid.UUID
+		actedOn map[string]bool
+	}
+
+	// stubNearbyIndex give users in the order they are kept, nearest first
+	stubNearbyIndex struct {
+		users    []infra.NearbyUser
+		searches int
+	}
+)
+
+func (r *stubRepository) FindOrigin(ctx context.Context, email string, usePassport bool) (string, geo.Point, error) {
+	return "self", geo.Point{}, nil
+}
+
+func (r *stubRepository) FindCandidates(ctx context.Context, ids []string, limit int) ([]recommendation.Candidate, error) {
+	candidates := make([]recommendation.Candidate, 0)
+	for _, id := range ids {
+		if !r.actedOn[id] && len(candidates) < limit {
+			candidates = append(candidates, recommendation.Candidate{ID: r.ids[id]})
+		}
+	}
+	return candidates, nil
+}
+
+func (r *stubRepository) FindNearbyCandidates(ctx context.Context, userID string, origin geo.Point, radiusInMeter float64, limit int) ([]recommendation.Candidate, error) {
+	return nil, nil
+}
+
+func (i *stubNearbyIndex) Name() string { return "stub" }
+
+func (i *stubNearbyIndex) Put(ctx context.Context, userID string, p geo.Point) error { return nil }
+
+func (i *stubNearbyIndex) Remove(ctx context.Context, userID string) error { return nil }
+
+func (i *stubNearbyIndex) Search(ctx context.Context, origin geo.Point, radiusInMeter float64, limit int) ([]infra.NearbyUser, error) {
+	i.searches++
+	return i.users[:min(len(i.users), limit)], nil
+}
+
+func TestService_Find_WidenSearchPastActedOnUsers(t *testing.T) {
+	repo := &stubRepository{ids: map[string]uuid.UUID{}, actedOn: map[string]bool{}}
+	index := &stubNearbyIndex{}
+	for i := 0; i < 1500; i++ {
+		id := fmt.Sprintf("user-%d", i)
+		repo.ids[id] = uuid.New()
+		// nearest 1200 users are all liked or passed already
+		repo.actedOn[id] = i < 1200
+		index.users = append(index.users, infra.NearbyUser{UserID: id, DistanceInMeter: float64(i)})
+	}
+	service := recommendation.NewService(repo, index, geo.DefaultFuzzPolicy)
+
+	recommendations, err := service.Find(context.Background(), "self@mail.com", false, 10)
+	require.Nil(t, err)
+	assert.Len(t, recommendations, 10)
+	assert.Equal(t, repo.ids["user-1200"], recommendations[0].ID)
+	assert.Equal(t, 2, index.searches)
+
+	// the search stops once every user within the distance is searched
+	for id := range repo.ids {
+		repo.actedOn[id] = true
+	}
+	index.searches = 0
+	recommendations, err = service.Find(context.Background(), "self@mail.com", false, 10)
+	require.Nil(t, err)
+	assert.Len(t, recommendations, 0)
+	assert.Equal(t, 2, index.searches)
+}
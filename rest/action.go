@@ -1,46 +1,44 @@
 package rest
 
 import (
-	"fmt"
-	"gotinder/infra"
+	"gotinder/action"
+	"gotinder/apperr"
+	"gotinder/pagination"
 	"net/http"
 
-	sq "github.com/Masterminds/squirrel"
 	"github.com/gin-gonic/gin"
 	"github.com/go-pkgz/auth/token"
-	"github.com/gomodule/redigo/redis"
 	"github.com/pkg/errors"
 )
 
 type (
 	// actionRequest is a type of action (like/pass) request body
 	actionRequest struct {
-		ID string `json:"id" validate:"required,uuid"`
+		ID string `json:"id" uri:"id" validate:"required,uuid"`
 	}
-
-	// actionType is a type of available actions
-	actionType string
 )
 
-const (
-	actionLike actionType = "likes"
-	actionPass actionType = "passes"
-)
+// defaultMaxActionAllowed is used when actor's plan has no daily action quota
+const defaultMaxActionAllowed = 10
 
 // RegisterAction register like handler
 func (v v1) RegisterAction() {
 	authMiddleware := v.auth.service.Middleware()
 
-	locationGroup := v.group.Group("/actions", asGin(authMiddleware.Auth), enrichActor)
-	locationGroup.POST("/likes", like)
-	locationGroup.POST("/passes", pass)
+	locationGroup := v.group.Group("/actions", asGin(authMiddleware.Auth), v.enrichActor)
+	locationGroup.POST("/likes", v.like)
+	locationGroup.POST("/passes", v.pass)
+	locationGroup.GET("/likes", v.findLikes)
+	locationGroup.GET("/passes", v.findPasses)
+	locationGroup.DELETE("/likes/:id", v.withdrawLike)
 }
 
 // like will record that the actor is liking the target
-func like(ctx *gin.Context) {
-	if !action(ctx, actionLike) {
+func (v v1) like(ctx *gin.Context) {
+	if !v.act(ctx, action.Like) {
 		return
 	}
+	v.metrics.Likes.Inc()
 
 	ctx.JSON(http.StatusOK, gin.H{
 		"message": "success like user",
@@ -48,115 +46,94 @@ func like(ctx *gin.Context) {
 }
 
 // pass will record that the actor is passing the target
-func pass(ctx *gin.Context) {
-	if !action(ctx, actionPass) {
+func (v v1) pass(ctx *gin.Context) {
+	if !v.act(ctx, action.Pass) {
 		return
 	}
+	v.metrics.Passes.Inc()
 
 	ctx.JSON(http.StatusOK, gin.H{
 		"message": "success pass user",
 	})
 }
 
-// action is a common functionality of like and pass
-func action(ctx *gin.Context, actType actionType) bool {
-	var req actionRequest
-	if err := ctx.ShouldBind(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
-		return false
-	}
-
-	user := token.MustGetUserInfo(ctx.Request)
-	self := user.StrAttr("user_id")
-
-	actionKey := fmt.Sprintf("action-%s", self)
-	if !user.IsPaidSub() && !isActionAllowed(ctx, actionKey) {
-		return false
-	}
+// findLikes give list of users liked by the actor
+func (v v1) findLikes(ctx *gin.Context) {
+	v.findActions(ctx, action.Like)
+}
 
-	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
-	query, _, err := psql.
-		Insert(string(actType)).
-		Columns("self_id", "target_id").
-		Values("$1", "$2").
-		Suffix("ON CONFLICT (self_id,target_id) DO NOTHING").
-		ToSql()
-	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{
-			"error": errors.Wrap(err, fmt.Sprintf("failed to build create %s query", string(actType))).Error(),
-		})
-		return false
-	}
+// findPasses give list of users passed by the actor
+func (v v1) findPasses(ctx *gin.Context) {
+	v.findActions(ctx, action.Pass)
+}
 
-	if _, err := infra.PgConn.Exec(query, self, req.ID); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{
-			"error": errors.Wrap(err, "failed to record request").Error(),
-		})
-		return false
+// withdrawLike remove the actor's like on the target, the daily action quota the like consumed is not given back
+func (v v1) withdrawLike(ctx *gin.Context) {
+	var req actionRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		abortWithErr(ctx, apperr.InvalidRequest(err))
+		return
 	}
 
-	if !cacheAction(ctx, actionKey, req.ID) {
-		return false
+	user := token.MustGetUserInfo(ctx.Request)
+	if err := v.Actions.Withdraw(ctx.Request.Context(), user.StrAttr("user_id"), req.ID); err != nil {
+		abortWithErr(ctx, err)
+		return
 	}
 
-	return true
+	ctx.JSON(http.StatusOK, gin.H{
+		"message": "success withdraw like",
+	})
 }
 
-// isActionAllowed check if action's actor is allowed to do the action
-func isActionAllowed(ctx *gin.Context, actionKey string) bool {
-	cacheConn := infra.RedisPool.Get()
-	defer cacheConn.Close()
-
-	existsResponse, err := redis.Int(cacheConn.Do("EXISTS", actionKey))
-	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{
-			"error": err.Error(),
-		})
-		return false
+// findActions is a common functionality of listing outgoing likes and passes
+func (v v1) findActions(ctx *gin.Context, t action.Type) {
+	var param cursorQueryParam
+	if err := ctx.ShouldBind(&param); err != nil {
+		abortWithErr(ctx, apperr.InvalidRequest(err))
+		return
 	}
 
-	if existsResponse <= 0 {
-		return true
+	after, err := pagination.Decode(param.Cursor)
+	if err != nil {
+		abortWithErr(ctx, err)
+		return
 	}
 
-	actionCount, err := redis.Int(cacheConn.Do("SCARD", actionKey))
+	user := token.MustGetUserInfo(ctx.Request)
+	actions, next, err := v.Actions.Find(ctx.Request.Context(), t, user.StrAttr("user_id"), after, param.Limit)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{
-			"error": err.Error(),
-		})
-		return false
+		abortWithErr(ctx, err)
+		return
 	}
 
-	const maxActionAllowed int = 10
-	if actionCount >= maxActionAllowed {
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"error": "exceed max action allowed",
-		})
+	ctx.JSON(http.StatusOK, gin.H{
+		"data":        actions,
+		"next_cursor": next,
+	})
+}
+
+// act is a common functionality of like and pass
+func (v v1) act(ctx *gin.Context, t action.Type) bool {
+	var req actionRequest
+	if err := ctx.ShouldBind(&req); err != nil {
+		abortWithErr(ctx, apperr.InvalidRequest(err))
 		return false
 	}
 
-	return true
-}
-
-// cacheAction will record current action
-func cacheAction(ctx *gin.Context, actionKey string, target string) bool {
-	cacheConn := infra.RedisPool.Get()
-	defer cacheConn.Close()
+	user := token.MustGetUserInfo(ctx.Request)
 
-	if _, err := cacheConn.Do("SADD", actionKey, target); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{
-			"error": err.Error(),
-		})
-		return false
+	// zero daily quota means unlimited
+	var maxActionAllowed int
+	if !hasFeature(user, featureUnlimitedActions) {
+		maxActionAllowed = planQuota(user, quotaDailyActions, defaultMaxActionAllowed)
 	}
 
-	const aDayInSecond = 60 * 60 * 24
-	if _, err := cacheConn.Do("EXPIRE", actionKey, aDayInSecond, "NX"); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{
-			"error": err.Error(),
-		})
+	if err := v.Actions.Act(ctx.Request.Context(), t, user.StrAttr("user_id"), req.ID, maxActionAllowed); err != nil {
+		if errors.Is(err, action.ErrQuotaExceeded) {
+			v.metrics.QuotaRejections.Inc()
+		}
+		abortWithErr(ctx, err)
 		return false
 	}
 
@@ -1,9 +1,10 @@
+//go:build integration
+
 package rest_test
 
 import (
 	"encoding/json"
 	"fmt"
-	"gotinder/infra"
 	"io"
 	"net/http"
 	"testing"
@@ -25,18 +26,16 @@ func TestActionTestSuite(t *testing.T) {
 }
 
 func (s *ActionTestSuite) SetupSuite() {
-	pg := newPostgresTest(s.T())
-	infra.NewPgConnection(pg.connStr)
+	newPostgresTest(s.T())
 }
 
 func (s *ActionTestSuite) SetupTest() {
-	pgTest.migrate(s.T(), infra.PgConn)
-	redis := newRedisTest(s.T())
-	infra.NewRedisPool(redis.connStr, "", 0)
+	pgTest.migrate(s.T(), pgTest.conn)
+	newRedisTest(s.T())
 }
 
 func (s *ActionTestSuite) Test_Post_ActionLike_Success() {
-	tokens := getAuthToken(s.T(), infra.PgConn)
+	tokens := getAuthToken(s.T(), pgTest.conn)
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte("Secret1234!"), bcrypt.DefaultCost)
 	s.Nil(err)
 	userRow := sq.
@@ -46,7 +45,7 @@ func (s *ActionTestSuite) Test_Post_ActionLike_Success() {
 		Columns("email", "password", "birth_of_date").
 		Values("target@mail.com", string(hashedPassword), time.Now().Unix()).
 		Suffix("RETURNING id").
-		RunWith(infra.PgConn).
+		RunWith(pgTest.conn).
 		QueryRow()
 	s.Nil(err)
 	var targetId string
@@ -70,14 +69,14 @@ func (s *ActionTestSuite) Test_Post_ActionLike_Success() {
 		From("likes").
 		Join("users ON users.id = likes.self_id").
 		Where("users.email = $1", "base@mail.com").
-		RunWith(infra.PgConn).
+		RunWith(pgTest.conn).
 		QueryRow()
 
 	var recordedTargetId, selfId string
 	s.Nil(row.Scan(&recordedTargetId, &selfId))
 	s.Equal(targetId, recordedTargetId)
 
-	conn := infra.RedisPool.Get()
+	conn := rdsTest.pool.Get()
 	defer conn.Close()
 	actionKey := fmt.Sprintf("action-%s", selfId)
 	cached, err := redis.Strings(conn.Do("SMEMBERS", actionKey))
@@ -87,7 +86,7 @@ func (s *ActionTestSuite) Test_Post_ActionLike_Success() {
 }
 
 func (s *ActionTestSuite) Test_Post_ActionPass_Success() {
-	tokens := getAuthToken(s.T(), infra.PgConn)
+	tokens := getAuthToken(s.T(), pgTest.conn)
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte("Secret1234!"), bcrypt.DefaultCost)
 	s.Nil(err)
 	userRow := sq.
@@ -97,7 +96,7 @@ func (s *ActionTestSuite) Test_Post_ActionPass_Success() {
 		Columns("email", "password", "birth_of_date").
 		Values("target@mail.com", string(hashedPassword), time.Now().Unix()).
 		Suffix("RETURNING id").
-		RunWith(infra.PgConn).
+		RunWith(pgTest.conn).
 		QueryRow()
 	s.Nil(err)
 	var targetId string
@@ -121,14 +120,14 @@ func (s *ActionTestSuite) Test_Post_ActionPass_Success() {
 		From("passes").
 		Join("users ON users.id = passes.self_id").
 		Where("users.email = $1", "base@mail.com").
-		RunWith(infra.PgConn).
+		RunWith(pgTest.conn).
 		QueryRow()
 
 	var recordedTargetId, selfId string
 	s.Nil(row.Scan(&recordedTargetId, &selfId))
 	s.Equal(targetId, recordedTargetId)
 
-	conn := infra.RedisPool.Get()
+	conn := rdsTest.pool.Get()
 	defer conn.Close()
 	actionKey := fmt.Sprintf("action-%s", selfId)
 	cached, err := redis.Strings(conn.Do("SMEMBERS", actionKey))
@@ -138,7 +137,7 @@ func (s *ActionTestSuite) Test_Post_ActionPass_Success() {
 }
 
 func (s *ActionTestSuite) Test_Post_ActionLike_HitLimitAction() {
-	tokens := getAuthToken(s.T(), infra.PgConn)
+	tokens := getAuthToken(s.T(), pgTest.conn)
 
 	row := sq.
 		StatementBuilder.
@@ -146,13 +145,13 @@ func (s *ActionTestSuite) Test_Post_ActionLike_HitLimitAction() {
 		Select("id").
 		From("users").
 		Where("email = $1", "base@mail.com").
-		RunWith(infra.PgConn).
+		RunWith(pgTest.conn).
 		QueryRow()
 
 	var selfId string
 	s.Nil(row.Scan(&selfId))
 
-	conn := infra.RedisPool.Get()
+	conn := rdsTest.pool.Get()
 	defer conn.Close()
 	actionKey := fmt.Sprintf("action-%s", selfId)
 	for i := 0; i < 10; i++ {
@@ -179,7 +178,7 @@ func (s *ActionTestSuite) Test_Post_ActionLike_HitLimitAction() {
 }
 
 func (s *ActionTestSuite) Test_Post_ActionLike_SubscribedUser_HitLimitAction() {
-	tokens := getAuthToken(s.T(), infra.PgConn)
+	tokens := getAuthToken(s.T(), pgTest.conn)
 
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte("Secret1234!"), bcrypt.DefaultCost)
 	s.Nil(err)
@@ -190,7 +189,7 @@ func (s *ActionTestSuite) Test_Post_ActionLike_SubscribedUser_HitLimitAction() {
 		Columns("email", "password", "birth_of_date").
 		Values("target@mail.com", string(hashedPassword), time.Now().Unix()).
 		Suffix("RETURNING id").
-		RunWith(infra.PgConn).
+		RunWith(pgTest.conn).
 		QueryRow()
 	s.Nil(err)
 	var targetId string
@@ -203,14 +202,14 @@ func (s *ActionTestSuite) Test_Post_ActionLike_SubscribedUser_HitLimitAction() {
 		Set("subscribe_until", time.Now().Add(24*time.Hour).Unix()).
 		Where("email = ?", "base@mail.com").
 		Suffix("RETURNING id").
-		RunWith(infra.PgConn).
+		RunWith(pgTest.conn).
 		QueryRow()
 	s.Nil(err)
 
 	var selfId string
 	s.Nil(row.Scan(&selfId))
 
-	conn := infra.RedisPool.Get()
+	conn := rdsTest.pool.Get()
 	defer conn.Close()
 	actionKey := fmt.Sprintf("action-%s", selfId)
 	for i := 0; i < 10; i++ {
@@ -230,3 +229,152 @@ func (s *ActionTestSuite) Test_Post_ActionLike_SubscribedUser_HitLimitAction() {
 
 	s.Equal(http.StatusOK, res.StatusCode)
 }
+
+func (s *ActionTestSuite) Test_Get_ActionLikes_Success() {
+	tokens := getAuthToken(s.T(), pgTest.conn)
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte("Secret1234!"), bcrypt.DefaultCost)
+	s.Nil(err)
+	rows, err := sq.
+		StatementBuilder.
+		PlaceholderFormat(sq.Dollar).
+		Insert("users").
+		Columns("email", "password", "birth_of_date").
+		Values("target.1@mail.com", string(hashedPassword), time.Now().Unix()).
+		Values("target.2@mail.com", string(hashedPassword), time.Now().Unix()).
+		Suffix("RETURNING id").
+		RunWith(pgTest.conn).
+		Query()
+	s.Nil(err)
+	targetIds := make([]string, 0)
+	for rows.Next() {
+		var targetId string
+		s.Nil(rows.Scan(&targetId))
+		targetIds = append(targetIds, targetId)
+	}
+
+	row := sq.
+		StatementBuilder.
+		PlaceholderFormat(sq.Dollar).
+		Select("id").
+		From("users").
+		Where("email = $1", "base@mail.com").
+		RunWith(pgTest.conn).
+		QueryRow()
+	var selfId string
+	s.Nil(row.Scan(&selfId))
+
+	_, err = sq.
+		StatementBuilder.
+		PlaceholderFormat(sq.Dollar).
+		Insert("likes").
+		Columns("self_id", "target_id", "created_at").
+		Values(selfId, targetIds[0], time.Now().Add(-1*time.Hour).Unix()).
+		Values(selfId, targetIds[1], time.Now().Unix()).
+		RunWith(pgTest.conn).
+		Exec()
+	s.Nil(err)
+
+	res := newHttpTest().
+		withPath("/v1/actions/likes?limit=1").
+		withHeader("Cookie", fmt.Sprintf("%s=%s", tokens[0][0], tokens[0][1])).
+		withHeader("Cookie", fmt.Sprintf("%s=%s", tokens[1][0], tokens[1][1])).
+		do()
+
+	s.Equal(http.StatusOK, res.StatusCode)
+	body, err := io.ReadAll(res.Body)
+	s.Nil(err)
+	defer res.Body.Close()
+	var response struct {
+		Data []struct {
+			ID        string `json:"id"`
+			CreatedAt int64  `json:"created_at"`
+		} `json:"data"`
+		NextCursor string `json:"next_cursor"`
+	}
+	s.Nil(json.Unmarshal(body, &response))
+	s.Len(response.Data, 1)
+	s.Equal(targetIds[1], response.Data[0].ID)
+	s.NotEmpty(response.NextCursor)
+
+	res = newHttpTest().
+		withPath(fmt.Sprintf("/v1/actions/likes?limit=1&cursor=%s", response.NextCursor)).
+		withHeader("Cookie", fmt.Sprintf("%s=%s", tokens[0][0], tokens[0][1])).
+		withHeader("Cookie", fmt.Sprintf("%s=%s", tokens[1][0], tokens[1][1])).
+		do()
+
+	s.Equal(http.StatusOK, res.StatusCode)
+	body, err = io.ReadAll(res.Body)
+	s.Nil(err)
+	defer res.Body.Close()
+	s.Nil(json.Unmarshal(body, &response))
+	s.Len(response.Data, 1)
+	s.Equal(targetIds[0], response.Data[0].ID)
+	s.Empty(response.NextCursor)
+}
+
+func (s *ActionTestSuite) Test_Delete_ActionLike_Success() {
+	tokens := getAuthToken(s.T(), pgTest.conn)
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte("Secret1234!"), bcrypt.DefaultCost)
+	s.Nil(err)
+	userRow := sq.
+		StatementBuilder.
+		PlaceholderFormat(sq.Dollar).
+		Insert("users").
+		Columns("email", "password", "birth_of_date").
+		Values("target@mail.com", string(hashedPassword), time.Now().Unix()).
+		Suffix("RETURNING id").
+		RunWith(pgTest.conn).
+		QueryRow()
+	var targetId string
+	s.Nil(userRow.Scan(&targetId))
+
+	res := newHttpTest().
+		withPath("/v1/actions/likes").
+		withMethod(http.MethodPost).
+		withBody(map[string]interface{}{
+			"id": targetId,
+		}).
+		withHeader("Cookie", fmt.Sprintf("%s=%s", tokens[0][0], tokens[0][1])).
+		withHeader("Cookie", fmt.Sprintf("%s=%s", tokens[1][0], tokens[1][1])).
+		do()
+	s.Equal(http.StatusOK, res.StatusCode)
+
+	res = newHttpTest().
+		withPath(fmt.Sprintf("/v1/actions/likes/%s", targetId)).
+		withMethod(http.MethodDelete).
+		withHeader("Cookie", fmt.Sprintf("%s=%s", tokens[0][0], tokens[0][1])).
+		withHeader("Cookie", fmt.Sprintf("%s=%s", tokens[1][0], tokens[1][1])).
+		do()
+	s.Equal(http.StatusOK, res.StatusCode)
+
+	row := sq.
+		StatementBuilder.
+		PlaceholderFormat(sq.Dollar).
+		Select("users.id", "COUNT(likes.target_id)").
+		From("users").
+		LeftJoin("likes ON users.id = likes.self_id").
+		Where("users.email = $1", "base@mail.com").
+		GroupBy("users.id").
+		RunWith(pgTest.conn).
+		QueryRow()
+	var selfId string
+	var likeCount int
+	s.Nil(row.Scan(&selfId, &likeCount))
+	s.Zero(likeCount)
+
+	conn := rdsTest.pool.Get()
+	defer conn.Close()
+	// target is taken off the action set while the withdrawn like keeps consuming the daily quota
+	cached, err := redis.Strings(conn.Do("SMEMBERS", fmt.Sprintf("action-%s", selfId)))
+	s.Nil(err)
+	s.NotContains(cached, targetId)
+	s.Len(cached, 1)
+
+	res = newHttpTest().
+		withPath(fmt.Sprintf("/v1/actions/likes/%s", targetId)).
+		withMethod(http.MethodDelete).
+		withHeader("Cookie", fmt.Sprintf("%s=%s", tokens[0][0], tokens[0][1])).
+		withHeader("Cookie", fmt.Sprintf("%s=%s", tokens[1][0], tokens[1][1])).
+		do()
+	s.Equal(http.StatusNotFound, res.StatusCode)
+}
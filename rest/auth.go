@@ -1,26 +1,31 @@
 package rest
 
 import (
-	"database/sql"
-	"gotinder/infra"
-	"log"
+	"context"
+	"fmt"
+	"gotinder/apperr"
+	"gotinder/user"
+	"log/slog"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
 
-	sq "github.com/Masterminds/squirrel"
 	"github.com/gin-gonic/gin"
 	"github.com/go-pkgz/auth"
 	"github.com/go-pkgz/auth/avatar"
 	"github.com/go-pkgz/auth/logger"
 	"github.com/go-pkgz/auth/provider"
 	"github.com/go-pkgz/auth/token"
-	"github.com/pkg/errors"
-	passwordvalidator "github.com/wagslane/go-password-validator"
-	"golang.org/x/crypto/bcrypt"
 )
 
-var ()
+// authLogLevels map level prefix of auth library messages to log level
+var authLogLevels = map[string]slog.Level{
+	"[DEBUG]": slog.LevelDebug,
+	"[INFO]":  slog.LevelInfo,
+	"[WARN]":  slog.LevelWarn,
+	"[ERROR]": slog.LevelError,
+}
 
 type (
 	// authService is a type to wrap go-auth service instance
@@ -37,8 +42,9 @@ type (
 	}
 )
 
-// init do initialize of authService
-func (s *authService) init() {
+// init do initialize of authService, credentials are checked against users within timeout,
+// as the auth library does not pass context of the request
+func (s *authService) init(users *user.Service, timeout time.Duration) {
 	s.once.Do(func() {
 		opt := auth.Opts{
 			SecretReader: token.SecretFunc(func(aud string) (string, error) {
@@ -54,10 +60,14 @@ func (s *authService) init() {
 				return claims.Issuer == "gotinder"
 			}),
 			AvatarStore: avatar.NewNoOp(),
-			Logger:      logger.Func(log.Printf),
+			Logger:      logger.Func(logAuth),
 		}
 		s.service = auth.NewService(opt)
-		s.service.AddDirectProvider("direct", provider.CredCheckerFunc(checkCred))
+		s.service.AddDirectProvider("direct", provider.CredCheckerFunc(func(email, password string) (bool, error) {
+			ctx, cancel := withTimeout(context.Background(), timeout)
+			defer cancel()
+			return users.CheckCredential(ctx, email, password)
+		}))
 	})
 }
 
@@ -67,79 +77,42 @@ func (v v1) RegisterAuth() {
 	v.group.Match([]string{http.MethodGet, http.MethodPost}, "/auth/*provider", func(ctx *gin.Context) {
 		provider := ctx.Param("provider")
 		if provider == "/register" && ctx.Request.Method == http.MethodPost {
-			register(ctx)
+			v.register(ctx)
 			return
 		}
 		authHandler.ServeHTTP(ctx.Writer, ctx.Request)
 	})
 }
 
-// checkCred validate user's credential
-func checkCred(email, password string) (bool, error) {
-	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
-	query, _, err := psql.Select("password").From("users").Where("email = $1").ToSql()
-	if err != nil {
-		return false, err
-	}
-
-	row := infra.PgConn.QueryRow(query, email)
-	var recordedPassword string
-	if err := row.Scan(&recordedPassword); err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return false, nil
-		}
-		return false, err
-	}
-
-	if err := bcrypt.CompareHashAndPassword([]byte(recordedPassword), []byte(password)); err != nil {
-		return false, err
-	}
-
-	return true, nil
-}
-
 // register processing user registration (validating, securing, recording)
-func register(ctx *gin.Context) {
+func (v v1) register(ctx *gin.Context) {
 	var req registerRequest
 	if err := ctx.ShouldBind(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
+		abortWithErr(ctx, apperr.InvalidRequest(err))
 		return
 	}
 
-	if err := passwordvalidator.Validate(req.Password, 35); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
-		return
-	}
-
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
-	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{
-			"error": errors.Wrap(err, "failed to process request").Error(),
-		})
-		return
-	}
-
-	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
-	query, _, err := psql.Insert("users").Columns("email", "password", "birth_of_date").Values("$1", "$2", "$3").ToSql()
-	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{
-			"error": errors.Wrap(err, "failed to build query").Error(),
-		})
-		return
-	}
-
-	if _, err := infra.PgConn.Exec(query, req.Email, string(hashedPassword), req.BirthOfDate); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{
-			"error": errors.Wrap(err, "failed to record request").Error(),
-		})
+	if err := v.Users.Register(ctx.Request.Context(), req.Email, req.Password, req.BirthOfDate); err != nil {
+		abortWithErr(ctx, err)
 		return
 	}
+	v.metrics.Registrations.Inc()
 
 	ctx.JSON(http.StatusOK, gin.H{
 		"message": "register success",
 	})
 }
+
+// logAuth log message of auth library on level of its prefix, e.g. "[DEBUG] ..."
+func logAuth(format string, args ...interface{}) {
+	message := fmt.Sprintf(format, args...)
+	level := slog.LevelInfo
+	for prefix, l := range authLogLevels {
+		if strings.HasPrefix(message, prefix) {
+			level = l
+			message = strings.TrimSpace(strings.TrimPrefix(message, prefix))
+			break
+		}
+	}
+	slog.Log(context.Background(), level, message, "component", "auth")
+}
@@ -1,7 +1,8 @@
+//go:build integration
+
 package rest_test
 
 import (
-	"gotinder/infra"
 	"net/http"
 	"testing"
 	"time"
@@ -20,12 +21,11 @@ func TestAuthTestSuite(t *testing.T) {
 }
 
 func (s *AuthTestSuite) SetupSuite() {
-	pg := newPostgresTest(s.T())
-	infra.NewPgConnection(pg.connStr)
+	newPostgresTest(s.T())
 }
 
 func (s *AuthTestSuite) SetupTest() {
-	pgTest.migrate(s.T(), infra.PgConn)
+	pgTest.migrate(s.T(), pgTest.conn)
 }
 
 func (s *AuthTestSuite) Test_Post_AuthDirectLogin_Success() {
@@ -37,7 +37,7 @@ func (s *AuthTestSuite) Test_Post_AuthDirectLogin_Success() {
 		Insert("users").
 		Columns("email", "password", "birth_of_date").
 		Values("valid@mail.com", string(hashedPassword), time.Now().Unix()).
-		RunWith(infra.PgConn).
+		RunWith(pgTest.conn).
 		Exec()
 	s.Nil(err)
 
@@ -74,7 +74,7 @@ func (s *AuthTestSuite) Test_Post_AuthRegister_Success() {
 		Select("email", "password", "birth_of_date").
 		From("users").
 		Where("email = $1", "valid@mail.com").
-		RunWith(infra.PgConn).
+		RunWith(pgTest.conn).
 		QueryRow()
 
 	var user struct {
@@ -0,0 +1,191 @@
+//go:build integration
+
+package rest_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"gotinder/app"
+	"gotinder/config"
+	"gotinder/geo"
+	"gotinder/infra"
+	"gotinder/location"
+	"gotinder/rest"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	_ "github.com/amacneil/dbmate/v2/pkg/driver/postgres"
+	redigo "github.com/gomodule/redigo/redis"
+	_ "github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/modules/postgres"
+	"github.com/testcontainers/testcontainers-go/modules/redis"
+	"github.com/testcontainers/testcontainers-go/wait"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	pgTest      *postgresTest
+	pgTestOnce  sync.Once
+	rdsTest     *redisTest
+	rdsTestOnce sync.Once
+)
+
+type (
+	postgresTest struct {
+		container *postgres.PostgresContainer
+		connStr   string
+		conn      *sql.DB
+	}
+
+	redisTest struct {
+		connStr string
+		pool    *redigo.Pool
+	}
+)
+
+func newPostgresTest(t *testing.T) *postgresTest {
+	pgTestOnce.Do(func() {
+		var err error
+		ctx := context.Background()
+		pgTest = new(postgresTest)
+		pgTest.container, err = postgres.RunContainer(
+			ctx,
+			testcontainers.WithImage("docker.io/postgis/postgis:15-3.4"),
+			postgres.WithDatabase("test"),
+			testcontainers.WithWaitStrategy(wait.ForLog("database system is ready to accept connections").WithOccurrence(2).WithStartupTimeout(5*time.Second)),
+		)
+		require.NoError(t, err)
+
+		pgTest.connStr, err = pgTest.container.ConnectionString(ctx, "sslmode=disable", "application_name=test")
+		require.NoError(t, err)
+
+		infra.Migrate(fmt.Sprintf("%s&search_path=public", pgTest.connStr), "../migrations", "test_scheme_migrations")
+		pgTest.conn = infra.NewPgConnection(pgTest.connStr, 0)
+	})
+	return pgTest
+}
+
+func (p *postgresTest) migrate(t *testing.T, conn *sql.DB) {
+	scheme := testSchema(t)
+	createSchema := fmt.Sprintf(`CREATE SCHEMA %s;`, scheme)
+	_, err := conn.Exec(createSchema)
+	require.NoError(t, err)
+
+	setSchema := fmt.Sprintf(`SET search_path TO %s,public;`, scheme)
+	_, err = conn.Exec(setSchema)
+	require.NoError(t, err)
+
+	infra.Migrate(fmt.Sprintf("%s&search_path=%s,public", p.connStr, scheme), "../migrations", "test_scheme_migrations")
+
+	// cities are seeded on start like main does, not by migration
+	schemaConn := p.schemaConn(t)
+	defer schemaConn.Close()
+	require.NoError(t, location.NewPostgresRepository(schemaConn).UpsertCities(context.Background(), geo.Cities()))
+}
+
+// schemaConn open connection pool which every connection uses schema of the test,
+// unlike search_path set by migrate which only applies to a single pooled connection
+func (p *postgresTest) schemaConn(t *testing.T) *sql.DB {
+	conn, err := sql.Open("postgres", fmt.Sprintf("%s&search_path=%s,public", p.connStr, testSchema(t)))
+	require.NoError(t, err)
+	require.NoError(t, conn.Ping())
+	return conn
+}
+
+// newTestApp give app on the connection, redis is only connected once a suite started its container
+func newTestApp(conn *sql.DB, configure ...func(cfg *config.Configuration)) *app.App {
+	cfg := new(config.Configuration)
+	cfg.Payment.Provider = "fake"
+	cfg.Payment.WebhookSecret = "test_webhook_secret"
+	cfg.Discovery.Fuzzing.Secret = "test_fuzzing_secret"
+	for _, fn := range configure {
+		fn(cfg)
+	}
+	var pool *redigo.Pool
+	if rdsTest != nil {
+		pool = rdsTest.pool
+	}
+	return app.New(cfg, conn, pool)
+}
+
+// do serve the request by handler of app on the test postgres
+func (b *httpTestBuilder) do() *http.Response {
+	return b.doWith(rest.NewHandler(newTestApp(pgTest.conn)))
+}
+
+// testSchema give schema name of the test
+func testSchema(t *testing.T) string {
+	return strings.ToLower(regexp.MustCompile(`\W`).ReplaceAllString(t.Name(), "_"))
+}
+
+func getAuthToken(t *testing.T, pgConn *sql.DB) [][]string {
+	password := "Secret1234!"
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	require.NoError(t, err)
+	_, err = sq.
+		StatementBuilder.
+		PlaceholderFormat(sq.Dollar).
+		Insert("users").
+		Columns("email", "password", "birth_of_date").
+		Values("base@mail.com", string(hashedPassword), time.Now().Unix()).
+		RunWith(pgConn).
+		Exec()
+	require.NoError(t, err)
+
+	res := newHttpTest().
+		withPath("/v1/auth/direct/login").
+		withMethod(http.MethodPost).
+		withBody(map[string]interface{}{
+			"user":   "base@mail.com",
+			"passwd": password,
+		}).
+		do()
+
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	cookies := make([][]string, 0)
+	for _, cookie := range res.Cookies() {
+		cookies = append(cookies, []string{cookie.Name, cookie.Value})
+	}
+	require.Len(t, cookies, 2)
+	return cookies
+}
+
+func setAdmin(t *testing.T, pgConn *sql.DB, email string) {
+	_, err := sq.
+		StatementBuilder.
+		PlaceholderFormat(sq.Dollar).
+		Update("users").
+		Set("is_admin", true).
+		Where("email = ?", email).
+		RunWith(pgConn).
+		Exec()
+	require.NoError(t, err)
+}
+
+func newRedisTest(t *testing.T) *redisTest {
+	rdsTestOnce.Do(func() {
+		container, err := redis.RunContainer(context.Background(),
+			testcontainers.WithImage("docker.io/redis:7"),
+			redis.WithSnapshotting(10, 1),
+			redis.WithLogLevel(redis.LogLevelVerbose),
+		)
+		require.NoError(t, err)
+
+		_, err = container.MappedPort(context.Background(), "6379")
+		require.NoError(t, err)
+
+		rdsTest = &redisTest{
+			connStr: "localhost:6379",
+		}
+		rdsTest.pool = infra.NewRedisPool(rdsTest.connStr, "", 0, 0)
+	})
+	return rdsTest
+}
@@ -1,22 +1,26 @@
 package rest
 
 import (
-	"database/sql"
-	"errors"
-	"gotinder/infra"
+	"gotinder/apperr"
+	"gotinder/coupon"
+	"gotinder/pagination"
 	"net/http"
-	"time"
 
-	sq "github.com/Masterminds/squirrel"
 	"github.com/gin-gonic/gin"
+	"github.com/go-pkgz/auth/token"
 )
 
 type (
 	// couponRequest is a type of "/coupons" request body
 	couponRequest struct {
-		Code             string `json:"code" validate:"required,alphanum,min=5"`
-		DurationInSecond int64  `json:"duration_in_second" validate:"required,gte=0"`
-		ValidUntil       int64  `json:"valid_until" validate:"required,gte=0"`
+		Code                  string `json:"code" validate:"required,alphanum,min=5"`
+		DurationInSecond      int64  `json:"duration_in_second" validate:"required,gte=0"`
+		ValidUntil            int64  `json:"valid_until" validate:"required,gte=0"`
+		MaxRedemptions        *int64 `json:"max_redemptions" validate:"omitempty,gte=1"`
+		MaxRedemptionsPerUser *int64 `json:"max_redemptions_per_user" validate:"omitempty,gte=1"`
+		Campaign              string `json:"campaign" validate:"omitempty,max=255"`
+		PlanTier              string `json:"plan_tier"`
+		IsPublic              bool   `json:"is_public"`
 	}
 
 	// applyCouponRequest is a type of "/coupons/apply" request body
@@ -24,6 +28,22 @@ type (
 		Code   string `json:"code" validate:"required"`
 		UserID string `json:"user_id" validate:"required,uuid"`
 	}
+
+	// redeemCouponRequest is a type of "/coupons/redeem" request body
+	redeemCouponRequest struct {
+		Code string `json:"code" validate:"required"`
+	}
+
+	// couponURI is a type of coupon path param
+	couponURI struct {
+		ID string `uri:"id" validate:"required,uuid"`
+	}
+
+	// findCouponsQueryParam is a type of "/coupons" query param
+	findCouponsQueryParam struct {
+		cursorQueryParam
+		Campaign string `form:"campaign"`
+	}
 )
 
 // CouponLocation register location handler
@@ -31,32 +51,42 @@ func (v v1) CouponLocation() {
 	authMiddleware := v.auth.service.Middleware()
 
 	locationGroup := v.group.Group("/coupons", asGin(authMiddleware.Auth))
-	locationGroup.POST("", createCoupon)
-	locationGroup.POST("/apply", applyCoupon)
+	locationGroup.POST("/redeem", v.enrichActor, v.redeemCoupon)
+
+	adminGroup := locationGroup.Group("", v.enrichActor, requireAdmin)
+	adminGroup.POST("", v.createCoupon)
+	adminGroup.POST("/apply", v.applyCoupon)
+	adminGroup.GET("", v.findCoupons)
+	adminGroup.GET("/:id", v.findCoupon)
+	adminGroup.POST("/:id/revoke", v.revokeCoupon)
+	adminGroup.POST("/campaigns", v.generateCampaign)
+	adminGroup.GET("/campaigns/:campaign/export", v.exportCampaign)
+	adminGroup.GET("/campaigns/:campaign/stats", v.findCampaignStats)
 }
 
 // createCoupon creating coupon
-func createCoupon(ctx *gin.Context) {
+func (v v1) createCoupon(ctx *gin.Context) {
 	var req couponRequest
 	if err := ctx.ShouldBind(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
+		abortWithErr(ctx, apperr.InvalidRequest(err))
 		return
 	}
 
-	_, err := sq.
-		StatementBuilder.
-		PlaceholderFormat(sq.Dollar).
-		Insert("coupons").
-		Columns("code", "duration_in_second", "valid_until").
-		Values(req.Code, req.DurationInSecond, req.ValidUntil).
-		RunWith(infra.PgConn).
-		Exec()
-	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
+	c := coupon.NewCoupon{
+		Code:             req.Code,
+		DurationInSecond: req.DurationInSecond,
+		ValidUntil:       req.ValidUntil,
+		MaxRedemptions:   req.MaxRedemptions,
+		Campaign:         req.Campaign,
+		PlanTier:         req.PlanTier,
+		IsPublic:         req.IsPublic,
+	}
+	if req.MaxRedemptionsPerUser != nil {
+		c.MaxRedemptionsPerUser = *req.MaxRedemptionsPerUser
+	}
+
+	if err := v.Coupons.Create(ctx.Request.Context(), c); err != nil {
+		abortWithErr(ctx, err)
 		return
 	}
 
@@ -65,64 +95,107 @@ func createCoupon(ctx *gin.Context) {
 	})
 }
 
-// applyCoupon applying coupon to user
-func applyCoupon(ctx *gin.Context) {
+// applyCoupon applying targeted coupon to user, redeemed later by the user through "/users/subscribe"
+func (v v1) applyCoupon(ctx *gin.Context) {
 	var req applyCouponRequest
 	if err := ctx.ShouldBind(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
+		abortWithErr(ctx, apperr.InvalidRequest(err))
 		return
 	}
 
-	row := sq.
-		StatementBuilder.
-		PlaceholderFormat(sq.Dollar).
-		Select("id", "valid_until").
-		From("coupons").
-		Where("code = ?", req.Code).
-		RunWith(infra.PgConn).
-		QueryRow()
-	var coupon struct {
-		ID         string
-		ValidUntil int64
-	}
-	if err := row.Scan(&coupon.ID, &coupon.ValidUntil); err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			ctx.JSON(http.StatusNotFound, gin.H{
-				"error": "coupon not found",
-			})
-			return
-		}
-		ctx.JSON(http.StatusInternalServerError, gin.H{
-			"error": err.Error(),
-		})
+	if err := v.Coupons.Apply(ctx.Request.Context(), req.Code, req.UserID); err != nil {
+		abortWithErr(ctx, err)
 		return
 	}
 
-	if time.Now().After(time.Unix(coupon.ValidUntil, 0)) {
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"error": "coupon expired",
-		})
+	ctx.JSON(http.StatusOK, gin.H{
+		"message": "success apply coupon",
+	})
+}
+
+// redeemCoupon redeem public campaign coupon for the actor in one step
+func (v v1) redeemCoupon(ctx *gin.Context) {
+	var req redeemCouponRequest
+	if err := ctx.ShouldBind(&req); err != nil {
+		abortWithErr(ctx, apperr.InvalidRequest(err))
 		return
 	}
 
-	_, err := sq.
-		StatementBuilder.
-		PlaceholderFormat(sq.Dollar).
-		Insert("user_coupons").
-		Columns("user_id", "coupon_id").
-		Values(req.UserID, coupon.ID).
-		RunWith(infra.PgConn).
-		Exec()
+	user := token.MustGetUserInfo(ctx.Request)
+	subscribeUntil, err := v.Coupons.Redeem(ctx.Request.Context(), req.Code, user.StrAttr("user_id"))
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{
-			"error": err.Error(),
-		})
+		abortWithErr(ctx, err)
 		return
 	}
+	v.metrics.SubscriptionsRedeemed.Inc()
 
 	ctx.JSON(http.StatusOK, gin.H{
-		"message": "success apply coupon",
+		"message": "success redeem coupon",
+		"data": gin.H{
+			"subscribe_until": subscribeUntil.Unix(),
+		},
+	})
+}
+
+// findCoupons give list of coupons, optionally filtered by campaign
+func (v v1) findCoupons(ctx *gin.Context) {
+	var param findCouponsQueryParam
+	if err := ctx.ShouldBind(&param); err != nil {
+		abortWithErr(ctx, apperr.InvalidRequest(err))
+		return
+	}
+
+	after, err := pagination.Decode(param.Cursor)
+	if err != nil {
+		abortWithErr(ctx, err)
+		return
+	}
+
+	coupons, next, err := v.Coupons.Find(ctx.Request.Context(), param.Campaign, after, param.Limit)
+	if err != nil {
+		abortWithErr(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"data":        coupons,
+		"next_cursor": next,
+	})
+}
+
+// findCoupon give detail of a coupon
+func (v v1) findCoupon(ctx *gin.Context) {
+	var uri couponURI
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		abortWithErr(ctx, apperr.InvalidRequest(err))
+		return
+	}
+
+	found, err := v.Coupons.FindByID(ctx.Request.Context(), uri.ID)
+	if err != nil {
+		abortWithErr(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"data": found,
+	})
+}
+
+// revokeCoupon stop coupon from being applied or redeemed, already granted subscriptions are kept
+func (v v1) revokeCoupon(ctx *gin.Context) {
+	var uri couponURI
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		abortWithErr(ctx, apperr.InvalidRequest(err))
+		return
+	}
+
+	if err := v.Coupons.Revoke(ctx.Request.Context(), uri.ID); err != nil {
+		abortWithErr(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"message": "success revoke coupon",
 	})
 }
@@ -0,0 +1,118 @@
+package rest
+
+import (
+	"gotinder/apperr"
+	"gotinder/coupon"
+	"log/slog"
+	"mime"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type (
+	// CouponBatch is a type of bulk coupon generation request
+	CouponBatch struct {
+		Campaign              string `json:"campaign" validate:"required,max=255"`
+		Count                 int    `json:"count" validate:"required,gte=1,lte=100000"`
+		DurationInSecond      int64  `json:"duration_in_second" validate:"required,gte=0"`
+		ValidUntil            int64  `json:"valid_until" validate:"required,gte=0"`
+		MaxRedemptionsPerUser int64  `json:"max_redemptions_per_user" validate:"omitempty,gte=1"`
+		PlanTier              string `json:"plan_tier"`
+	}
+
+	// campaignURI is a type of campaign path param
+	campaignURI struct {
+		Campaign string `uri:"campaign" validate:"required"`
+	}
+
+	// attachmentWriter is a type of writer sending response as CSV attachment once anything is written,
+	// so error found before that is still rendered as JSON
+	attachmentWriter struct {
+		ctx      *gin.Context
+		filename string
+	}
+)
+
+func (w attachmentWriter) Write(p []byte) (int, error) {
+	if !w.ctx.Writer.Written() {
+		w.ctx.Header("Content-Type", "text/csv")
+		w.ctx.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": w.filename}))
+	}
+	return w.ctx.Writer.Write(p)
+}
+
+// generateCampaign generate random coupons of a campaign
+func (v v1) generateCampaign(ctx *gin.Context) {
+	var req CouponBatch
+	if err := ctx.ShouldBind(&req); err != nil {
+		abortWithErr(ctx, apperr.InvalidRequest(err))
+		return
+	}
+
+	codes, err := v.Coupons.Generate(ctx.Request.Context(), req.batch())
+	if err != nil {
+		abortWithErr(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"message": "success generate coupons",
+		"data": gin.H{
+			"campaign":  req.Campaign,
+			"generated": len(codes),
+		},
+	})
+}
+
+// exportCampaign give coupons of a campaign as CSV file
+func (v v1) exportCampaign(ctx *gin.Context) {
+	var uri campaignURI
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		abortWithErr(ctx, apperr.InvalidRequest(err))
+		return
+	}
+
+	w := attachmentWriter{ctx: ctx, filename: uri.Campaign + ".csv"}
+	if err := v.Coupons.ExportCampaign(ctx.Request.Context(), w, uri.Campaign); err != nil {
+		if !ctx.Writer.Written() {
+			abortWithErr(ctx, err)
+			return
+		}
+		// header is already sent once any row is written, so the error can only be logged
+		slog.ErrorContext(ctx.Request.Context(), "failed to export campaign", "campaign", uri.Campaign, "error", err)
+		ctx.Status(http.StatusInternalServerError)
+		return
+	}
+}
+
+// findCampaignStats give redemption statistics of a campaign
+func (v v1) findCampaignStats(ctx *gin.Context) {
+	var uri campaignURI
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		abortWithErr(ctx, apperr.InvalidRequest(err))
+		return
+	}
+
+	stats, err := v.Coupons.CampaignStats(ctx.Request.Context(), uri.Campaign)
+	if err != nil {
+		abortWithErr(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"data": stats,
+	})
+}
+
+// batch give coupon generation spec of the request
+func (b CouponBatch) batch() coupon.Batch {
+	return coupon.Batch{
+		Campaign:              b.Campaign,
+		Count:                 b.Count,
+		DurationInSecond:      b.DurationInSecond,
+		ValidUntil:            b.ValidUntil,
+		MaxRedemptionsPerUser: b.MaxRedemptionsPerUser,
+		PlanTier:              b.PlanTier,
+	}
+}
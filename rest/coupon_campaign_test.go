@@ -0,0 +1,155 @@
+//go:build integration
+
+package rest_test
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/stretchr/testify/suite"
+)
+
+type CouponCampaignTestSuite struct {
+	suite.Suite
+}
+
+func TestCouponCampaignTestSuite(t *testing.T) {
+	suite.Run(t, new(CouponCampaignTestSuite))
+}
+
+func (s *CouponCampaignTestSuite) SetupSuite() {
+	newPostgresTest(s.T())
+}
+
+func (s *CouponCampaignTestSuite) SetupTest() {
+	pgTest.migrate(s.T(), pgTest.conn)
+}
+
+func (s *CouponCampaignTestSuite) Test_Post_CouponCampaign_Success() {
+	tokens := getAuthToken(s.T(), pgTest.conn)
+	setAdmin(s.T(), pgTest.conn, "base@mail.com")
+
+	res := newHttpTest().
+		withPath("/v1/coupons/campaigns").
+		withMethod(http.MethodPost).
+		withBody(map[string]interface{}{
+			"campaign":           "newyear",
+			"count":              1200,
+			"duration_in_second": 60 * 60 * 24 * 7,
+			"valid_until":        time.Now().Add(24 * time.Hour).Unix(),
+		}).
+		withHeader("Cookie", fmt.Sprintf("%s=%s", tokens[0][0], tokens[0][1])).
+		withHeader("Cookie", fmt.Sprintf("%s=%s", tokens[1][0], tokens[1][1])).
+		do()
+
+	s.Equal(http.StatusOK, res.StatusCode)
+
+	rows, err := sq.
+		StatementBuilder.
+		PlaceholderFormat(sq.Dollar).
+		Select("code").
+		From("coupons").
+		Where("campaign = ?", "newyear").
+		RunWith(pgTest.conn).
+		Query()
+	s.Nil(err)
+	defer rows.Close()
+	codes := make(map[string]bool)
+	for rows.Next() {
+		var code string
+		s.Nil(rows.Scan(&code))
+		s.Regexp(regexp.MustCompile(`^[A-Z0-9]{10}$`), code)
+		codes[code] = true
+	}
+	s.Len(codes, 1200)
+
+	res = newHttpTest().
+		withPath("/v1/coupons/campaigns/newyear/export").
+		withHeader("Cookie", fmt.Sprintf("%s=%s", tokens[0][0], tokens[0][1])).
+		withHeader("Cookie", fmt.Sprintf("%s=%s", tokens[1][0], tokens[1][1])).
+		do()
+
+	s.Equal(http.StatusOK, res.StatusCode)
+	defer res.Body.Close()
+	records, err := csv.NewReader(res.Body).ReadAll()
+	s.Nil(err)
+	s.Len(records, 1201)
+	s.Equal("code", records[0][0])
+	s.True(codes[records[1][0]])
+}
+
+func (s *CouponCampaignTestSuite) Test_Get_CouponCampaignStats_Success() {
+	tokens := getAuthToken(s.T(), pgTest.conn)
+	setAdmin(s.T(), pgTest.conn, "base@mail.com")
+
+	rows, err := sq.
+		StatementBuilder.
+		PlaceholderFormat(sq.Dollar).
+		Insert("coupons").
+		Columns("code", "duration_in_second", "valid_until", "campaign").
+		Values("PROMO00001", 60*60*24, time.Now().Add(24*time.Hour).Unix(), "promo").
+		Values("PROMO00002", 60*60*24, time.Now().Add(24*time.Hour).Unix(), "promo").
+		Suffix("RETURNING id").
+		RunWith(pgTest.conn).
+		Query()
+	s.Nil(err)
+	couponIds := make([]string, 0)
+	for rows.Next() {
+		var couponId string
+		s.Nil(rows.Scan(&couponId))
+		couponIds = append(couponIds, couponId)
+	}
+
+	row := sq.
+		StatementBuilder.
+		PlaceholderFormat(sq.Dollar).
+		Select("id").
+		From("users").
+		Where("email = ?", "base@mail.com").
+		RunWith(pgTest.conn).
+		QueryRow()
+	var userId string
+	s.Nil(row.Scan(&userId))
+
+	_, err = sq.
+		StatementBuilder.
+		PlaceholderFormat(sq.Dollar).
+		Insert("user_coupons").
+		Columns("user_id", "coupon_id", "used_at").
+		Values(userId, couponIds[0], time.Now().Unix()).
+		Values(userId, couponIds[1], nil).
+		RunWith(pgTest.conn).
+		Exec()
+	s.Nil(err)
+
+	res := newHttpTest().
+		withPath("/v1/coupons/campaigns/promo/stats").
+		withHeader("Cookie", fmt.Sprintf("%s=%s", tokens[0][0], tokens[0][1])).
+		withHeader("Cookie", fmt.Sprintf("%s=%s", tokens[1][0], tokens[1][1])).
+		do()
+
+	s.Equal(http.StatusOK, res.StatusCode)
+	body, err := io.ReadAll(res.Body)
+	s.Nil(err)
+	defer res.Body.Close()
+	var response struct {
+		Data struct {
+			TotalCodes    int `json:"total_codes"`
+			RedeemedCodes int `json:"redeemed_codes"`
+			Redemptions   int `json:"redemptions"`
+			Pending       int `json:"pending"`
+		} `json:"data"`
+	}
+	s.Nil(json.Unmarshal(body, &response))
+	s.Equal(2, response.Data.TotalCodes)
+	s.Equal(1, response.Data.RedeemedCodes)
+	s.Equal(1, response.Data.Redemptions)
+	s.Equal(1, response.Data.Pending)
+}
@@ -1,10 +1,15 @@
+//go:build integration
+
 package rest_test
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
-	"gotinder/infra"
+	"gotinder/rest"
+	"io"
 	"net/http"
+	"sync"
 	"testing"
 	"time"
 
@@ -21,16 +26,16 @@ func TestCouponTestSuite(t *testing.T) {
 }
 
 func (s *CouponTestSuite) SetupSuite() {
-	pg := newPostgresTest(s.T())
-	infra.NewPgConnection(pg.connStr)
+	newPostgresTest(s.T())
 }
 
 func (s *CouponTestSuite) SetupTest() {
-	pgTest.migrate(s.T(), infra.PgConn)
+	pgTest.migrate(s.T(), pgTest.conn)
 }
 
 func (s *CouponTestSuite) Test_Post_Coupon_Success() {
-	tokens := getAuthToken(s.T(), infra.PgConn)
+	tokens := getAuthToken(s.T(), pgTest.conn)
+	setAdmin(s.T(), pgTest.conn, "base@mail.com")
 
 	currTime := time.Now()
 	res := newHttpTest().
@@ -53,7 +58,7 @@ func (s *CouponTestSuite) Test_Post_Coupon_Success() {
 		Select("duration_in_second", "valid_until").
 		From("coupons").
 		Where("code = $1", "NEWUSER123").
-		RunWith(infra.PgConn).
+		RunWith(pgTest.conn).
 		QueryRow()
 
 	var coupon struct {
@@ -66,7 +71,8 @@ func (s *CouponTestSuite) Test_Post_Coupon_Success() {
 }
 
 func (s *CouponTestSuite) Test_Post_CouponApply_Success() {
-	tokens := getAuthToken(s.T(), infra.PgConn)
+	tokens := getAuthToken(s.T(), pgTest.conn)
+	setAdmin(s.T(), pgTest.conn, "base@mail.com")
 
 	rowCreateCoupon := sq.
 		StatementBuilder.
@@ -75,7 +81,7 @@ func (s *CouponTestSuite) Test_Post_CouponApply_Success() {
 		Columns("code", "duration_in_second", "valid_until").
 		Values("NEWUSER123", 60*60*24*365, time.Now().Add(24*14*time.Hour).Unix()).
 		Suffix("RETURNING id").
-		RunWith(infra.PgConn).
+		RunWith(pgTest.conn).
 		QueryRow()
 	var couponId string
 	s.Nil(rowCreateCoupon.Scan(&couponId))
@@ -87,7 +93,7 @@ func (s *CouponTestSuite) Test_Post_CouponApply_Success() {
 		Columns("email", "password", "birth_of_date").
 		Values("sub@mail.com", "password", time.Now().Unix()).
 		Suffix("RETURNING id").
-		RunWith(infra.PgConn).
+		RunWith(pgTest.conn).
 		QueryRow()
 	var subId string
 	s.Nil(rowCreateUser.Scan(&subId))
@@ -111,7 +117,7 @@ func (s *CouponTestSuite) Test_Post_CouponApply_Success() {
 		Select("user_id", "coupon_id", "used_at").
 		From("user_coupons").
 		Where("user_id = $1", subId).
-		RunWith(infra.PgConn).
+		RunWith(pgTest.conn).
 		QueryRow()
 
 	var userCoupon struct {
@@ -124,3 +130,279 @@ func (s *CouponTestSuite) Test_Post_CouponApply_Success() {
 	s.Equal(couponId, userCoupon.CouponId)
 	s.False(userCoupon.UsedAt.Valid)
 }
+
+func (s *CouponTestSuite) createSubscriber(email string) string {
+	row := sq.
+		StatementBuilder.
+		PlaceholderFormat(sq.Dollar).
+		Insert("users").
+		Columns("email", "password", "birth_of_date").
+		Values(email, "password", time.Now().Unix()).
+		Suffix("RETURNING id").
+		RunWith(pgTest.conn).
+		QueryRow()
+	var id string
+	s.Nil(row.Scan(&id))
+	return id
+}
+
+func (s *CouponTestSuite) Test_Post_CouponApply_MaxRedemptionsReached() {
+	tokens := getAuthToken(s.T(), pgTest.conn)
+	setAdmin(s.T(), pgTest.conn, "base@mail.com")
+
+	_, err := sq.
+		StatementBuilder.
+		PlaceholderFormat(sq.Dollar).
+		Insert("coupons").
+		Columns("code", "duration_in_second", "valid_until", "max_redemptions").
+		Values("LIMITED123", 60*60*24, time.Now().Add(24*time.Hour).Unix(), 1).
+		RunWith(pgTest.conn).
+		Exec()
+	s.Nil(err)
+
+	for i, email := range []string{"sub.1@mail.com", "sub.2@mail.com"} {
+		res := newHttpTest().
+			withPath("/v1/coupons/apply").
+			withMethod(http.MethodPost).
+			withBody(map[string]interface{}{
+				"code":    "LIMITED123",
+				"user_id": s.createSubscriber(email),
+			}).
+			withHeader("Cookie", fmt.Sprintf("%s=%s", tokens[0][0], tokens[0][1])).
+			withHeader("Cookie", fmt.Sprintf("%s=%s", tokens[1][0], tokens[1][1])).
+			do()
+
+		if i == 0 {
+			s.Equal(http.StatusOK, res.StatusCode)
+			continue
+		}
+		s.Equal(http.StatusBadRequest, res.StatusCode)
+		body, err := io.ReadAll(res.Body)
+		s.Nil(err)
+		defer res.Body.Close()
+		var response map[string]interface{}
+		s.Nil(json.Unmarshal(body, &response))
+		s.Equal("coupon redemption limit reached", response["error"])
+	}
+}
+
+func (s *CouponTestSuite) Test_Post_CouponRevoke_Success() {
+	tokens := getAuthToken(s.T(), pgTest.conn)
+	setAdmin(s.T(), pgTest.conn, "base@mail.com")
+
+	row := sq.
+		StatementBuilder.
+		PlaceholderFormat(sq.Dollar).
+		Insert("coupons").
+		Columns("code", "duration_in_second", "valid_until").
+		Values("REVOKED123", 60*60*24, time.Now().Add(24*time.Hour).Unix()).
+		Suffix("RETURNING id").
+		RunWith(pgTest.conn).
+		QueryRow()
+	var couponId string
+	s.Nil(row.Scan(&couponId))
+
+	res := newHttpTest().
+		withPath(fmt.Sprintf("/v1/coupons/%s/revoke", couponId)).
+		withMethod(http.MethodPost).
+		withHeader("Cookie", fmt.Sprintf("%s=%s", tokens[0][0], tokens[0][1])).
+		withHeader("Cookie", fmt.Sprintf("%s=%s", tokens[1][0], tokens[1][1])).
+		do()
+	s.Equal(http.StatusOK, res.StatusCode)
+
+	res = newHttpTest().
+		withPath("/v1/coupons/apply").
+		withMethod(http.MethodPost).
+		withBody(map[string]interface{}{
+			"code":    "REVOKED123",
+			"user_id": s.createSubscriber("sub@mail.com"),
+		}).
+		withHeader("Cookie", fmt.Sprintf("%s=%s", tokens[0][0], tokens[0][1])).
+		withHeader("Cookie", fmt.Sprintf("%s=%s", tokens[1][0], tokens[1][1])).
+		do()
+	s.Equal(http.StatusBadRequest, res.StatusCode)
+	body, err := io.ReadAll(res.Body)
+	s.Nil(err)
+	defer res.Body.Close()
+	var response map[string]interface{}
+	s.Nil(json.Unmarshal(body, &response))
+	s.Equal("coupon revoked", response["error"])
+}
+
+func (s *CouponTestSuite) Test_Get_Coupons_Success() {
+	tokens := getAuthToken(s.T(), pgTest.conn)
+	setAdmin(s.T(), pgTest.conn, "base@mail.com")
+
+	_, err := sq.
+		StatementBuilder.
+		PlaceholderFormat(sq.Dollar).
+		Insert("coupons").
+		Columns("code", "duration_in_second", "valid_until", "campaign").
+		Values("PROMO00001", 60*60*24, time.Now().Add(24*time.Hour).Unix(), "promo").
+		Values("PROMO00002", 60*60*24, time.Now().Add(24*time.Hour).Unix(), "promo").
+		Values("OTHER00001", 60*60*24, time.Now().Add(24*time.Hour).Unix(), "other").
+		RunWith(pgTest.conn).
+		Exec()
+	s.Nil(err)
+
+	res := newHttpTest().
+		withPath("/v1/coupons?limit=10&campaign=promo").
+		withHeader("Cookie", fmt.Sprintf("%s=%s", tokens[0][0], tokens[0][1])).
+		withHeader("Cookie", fmt.Sprintf("%s=%s", tokens[1][0], tokens[1][1])).
+		do()
+
+	s.Equal(http.StatusOK, res.StatusCode)
+	body, err := io.ReadAll(res.Body)
+	s.Nil(err)
+	defer res.Body.Close()
+	var response struct {
+		Data []struct {
+			Code     string `json:"code"`
+			Campaign string `json:"campaign"`
+			PlanTier string `json:"plan_tier"`
+		} `json:"data"`
+	}
+	s.Nil(json.Unmarshal(body, &response))
+	s.Len(response.Data, 2)
+	for _, coupon := range response.Data {
+		s.Equal("promo", coupon.Campaign)
+		s.Equal("premium", coupon.PlanTier)
+	}
+}
+
+func (s *CouponTestSuite) Test_Get_Coupons_NonAdmin() {
+	tokens := getAuthToken(s.T(), pgTest.conn)
+
+	res := newHttpTest().
+		withPath("/v1/coupons?limit=10").
+		withHeader("Cookie", fmt.Sprintf("%s=%s", tokens[0][0], tokens[0][1])).
+		withHeader("Cookie", fmt.Sprintf("%s=%s", tokens[1][0], tokens[1][1])).
+		do()
+
+	s.Equal(http.StatusForbidden, res.StatusCode)
+}
+
+func (s *CouponTestSuite) Test_Post_CouponApply_NonAdmin() {
+	tokens := getAuthToken(s.T(), pgTest.conn)
+
+	res := newHttpTest().
+		withPath("/v1/coupons/apply").
+		withMethod(http.MethodPost).
+		withBody(map[string]interface{}{
+			"code":    "NEWUSER123",
+			"user_id": s.createSubscriber("sub@mail.com"),
+		}).
+		withHeader("Cookie", fmt.Sprintf("%s=%s", tokens[0][0], tokens[0][1])).
+		withHeader("Cookie", fmt.Sprintf("%s=%s", tokens[1][0], tokens[1][1])).
+		do()
+
+	s.Equal(http.StatusForbidden, res.StatusCode)
+}
+
+func (s *CouponTestSuite) Test_Post_CouponRedeem_Success() {
+	tokens := getAuthToken(s.T(), pgTest.conn)
+
+	couponDuration := 60 * 60 * 24 * 7
+	_, err := sq.
+		StatementBuilder.
+		PlaceholderFormat(sq.Dollar).
+		Insert("coupons").
+		Columns("code", "duration_in_second", "valid_until", "is_public").
+		Values("CAMPAIGN1", couponDuration, time.Now().Add(24*time.Hour).Unix(), true).
+		Values("TARGETED1", couponDuration, time.Now().Add(24*time.Hour).Unix(), false).
+		RunWith(pgTest.conn).
+		Exec()
+	s.Nil(err)
+
+	redeem := func(code string) *http.Response {
+		return newHttpTest().
+			withPath("/v1/coupons/redeem").
+			withMethod(http.MethodPost).
+			withBody(map[string]interface{}{
+				"code": code,
+			}).
+			withHeader("Cookie", fmt.Sprintf("%s=%s", tokens[0][0], tokens[0][1])).
+			withHeader("Cookie", fmt.Sprintf("%s=%s", tokens[1][0], tokens[1][1])).
+			do()
+	}
+
+	s.Equal(http.StatusOK, redeem("CAMPAIGN1").StatusCode)
+	s.Equal(http.StatusBadRequest, redeem("CAMPAIGN1").StatusCode)
+	s.Equal(http.StatusNotFound, redeem("TARGETED1").StatusCode)
+
+	row := sq.
+		StatementBuilder.
+		PlaceholderFormat(sq.Dollar).
+		Select("users.subscribe_until", "user_coupons.used_at").
+		From("users").
+		InnerJoin("user_coupons ON user_coupons.user_id = users.id").
+		Where("users.email = ?", "base@mail.com").
+		RunWith(pgTest.conn).
+		QueryRow()
+	var subscribeUntil, usedAt sql.NullInt64
+	s.Nil(row.Scan(&subscribeUntil, &usedAt))
+	s.Equal(time.Now().Add(time.Duration(couponDuration)*time.Second).Unix(), subscribeUntil.Int64)
+	s.True(usedAt.Valid)
+}
+
+func (s *CouponTestSuite) Test_Post_CouponRedeem_Concurrent() {
+	tokens := getAuthToken(s.T(), pgTest.conn)
+
+	couponDuration := 60 * 60 * 24 * 7
+	codes := []string{"CAMPAIGN1", "CAMPAIGN2", "CAMPAIGN3"}
+	insert := sq.
+		StatementBuilder.
+		PlaceholderFormat(sq.Dollar).
+		Insert("coupons").
+		Columns("code", "duration_in_second", "valid_until", "is_public")
+	for _, code := range codes {
+		insert = insert.Values(code, couponDuration, time.Now().Add(24*time.Hour).Unix(), true)
+	}
+	_, err := insert.RunWith(pgTest.conn).Exec()
+	s.Nil(err)
+
+	// every pooled connection must see the test schema while requests run concurrently
+	conn := pgTest.schemaConn(s.T())
+	defer conn.Close()
+
+	// different coupons redeemed by the same user at the same time must not deadlock on the user row
+	handler := rest.NewHandler(newTestApp(conn))
+	statuses := make(chan int, len(codes))
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for _, code := range codes {
+		wg.Add(1)
+		go func(code string) {
+			defer wg.Done()
+			req := newHttpTest().
+				withPath("/v1/coupons/redeem").
+				withMethod(http.MethodPost).
+				withBody(map[string]interface{}{
+					"code": code,
+				}).
+				withHeader("Cookie", fmt.Sprintf("%s=%s", tokens[0][0], tokens[0][1])).
+				withHeader("Cookie", fmt.Sprintf("%s=%s", tokens[1][0], tokens[1][1]))
+			<-start
+			statuses <- req.doWith(handler).StatusCode
+		}(code)
+	}
+	close(start)
+	wg.Wait()
+	close(statuses)
+
+	for status := range statuses {
+		s.Equal(http.StatusOK, status)
+	}
+
+	var subscribeUntil sql.NullInt64
+	s.Nil(sq.
+		StatementBuilder.
+		PlaceholderFormat(sq.Dollar).
+		Select("subscribe_until").
+		From("users").
+		Where("email = ?", "base@mail.com").
+		RunWith(pgTest.conn).
+		QueryRow().
+		Scan(&subscribeUntil))
+	s.InDelta(time.Now().Add(time.Duration(len(codes)*couponDuration)*time.Second).Unix(), subscribeUntil.Int64, 2)
+}
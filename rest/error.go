@@ -0,0 +1,160 @@
+package rest
+
+import (
+	"gotinder/action"
+	"gotinder/apperr"
+	"gotinder/coupon"
+	"gotinder/geo"
+	"gotinder/infra"
+	"gotinder/location"
+	"gotinder/pagination"
+	"gotinder/payment"
+	"gotinder/recommendation"
+	"gotinder/subscription"
+	"gotinder/user"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/pkg/errors"
+)
+
+var (
+	errAdminRequired   = apperr.New("admin_required", http.StatusForbidden, "admin access required")
+	errFeatureRequired = apperr.New("feature_required", http.StatusForbidden, "feature is not available on your plan")
+	// errPassportRequired is errFeatureRequired of passport, telling clients which feature is missing
+	errPassportRequired = apperr.New("passport_required", http.StatusForbidden, "passport is not available on your plan")
+
+	// domainErrs map domain errors to errors exposed to clients showing message of the domain error,
+	// detailed ones describe the request itself so message of the whole error is shown instead
+	domainErrs = []struct {
+		target   error
+		appErr   *apperr.Error
+		detailed bool
+	}{
+		{action.ErrQuotaExceeded, domainErr(action.ErrQuotaExceeded, "quota_exceeded", http.StatusBadRequest), false},
+		{action.ErrLikeNotFound, domainErr(action.ErrLikeNotFound, "like_not_found", http.StatusNotFound), false},
+		{coupon.ErrCouponNotFound, domainErr(coupon.ErrCouponNotFound, "coupon_not_found", http.StatusNotFound), false},
+		{coupon.ErrAlreadyUsed, domainErr(coupon.ErrAlreadyUsed, "coupon_already_used", http.StatusNotFound), false},
+		{coupon.ErrCampaignNotFound, domainErr(coupon.ErrCampaignNotFound, "coupon_campaign_not_found", http.StatusNotFound), false},
+		{coupon.ErrRevoked, domainErr(coupon.ErrRevoked, "coupon_revoked", http.StatusBadRequest), false},
+		{coupon.ErrExpired, domainErr(coupon.ErrExpired, "coupon_expired", http.StatusBadRequest), false},
+		{coupon.ErrExhausted, domainErr(coupon.ErrExhausted, "coupon_exhausted", http.StatusBadRequest), false},
+		{coupon.ErrUserExhausted, domainErr(coupon.ErrUserExhausted, "coupon_user_exhausted", http.StatusBadRequest), false},
+		{location.ErrPassportNotFound, domainErr(location.ErrPassportNotFound, "passport_not_found", http.StatusNotFound), false},
+		{location.ErrCityNotFound, domainErr(location.ErrCityNotFound, "city_not_found", http.StatusNotFound), false},
+		{payment.ErrNotPurchasable, domainErr(payment.ErrNotPurchasable, "plan_not_purchasable", http.StatusBadRequest), false},
+		{payment.ErrSessionNotFound, domainErr(payment.ErrSessionNotFound, "payment_session_not_found", http.StatusNotFound), false},
+		{payment.ErrProviderFailure, domainErr(payment.ErrProviderFailure, "payment_provider_failure", http.StatusBadGateway), false},
+		{payment.ErrPeriodEnded, domainErr(payment.ErrPeriodEnded, "period_ended", http.StatusBadRequest), false},
+		{infra.ErrInvalidSignature, domainErr(infra.ErrInvalidSignature, "invalid_signature", http.StatusUnauthorized), false},
+		{recommendation.ErrOriginNotFound, domainErr(recommendation.ErrOriginNotFound, "origin_not_found", http.StatusNotFound), false},
+		{subscription.ErrPlanNotFound, domainErr(subscription.ErrPlanNotFound, "plan_not_found", http.StatusNotFound), false},
+		{subscription.ErrSubscriptionNotFound, domainErr(subscription.ErrSubscriptionNotFound, "subscription_not_found", http.StatusNotFound), false},
+		{subscription.ErrSubscriberNotFound, domainErr(subscription.ErrSubscriberNotFound, "subscriber_not_found", http.StatusNotFound), false},
+		{user.ErrUserNotFound, domainErr(user.ErrUserNotFound, "user_not_found", http.StatusNotFound), false},
+		{user.ErrWeakPassword, domainErr(user.ErrWeakPassword, "weak_password", http.StatusBadRequest), true},
+		{geo.ErrInvalidPoint, domainErr(geo.ErrInvalidPoint, "invalid_point", http.StatusBadRequest), true},
+		{coupon.ErrInvalidBatch, domainErr(coupon.ErrInvalidBatch, "invalid_coupon_batch", http.StatusBadRequest), true},
+		{pagination.ErrInvalidCursor, domainErr(pagination.ErrInvalidCursor, "invalid_cursor", http.StatusBadRequest), false},
+	}
+)
+
+type (
+	// errorResponse is a type of JSON error envelope shared by all routes
+	errorResponse struct {
+		Code    apperr.Code  `json:"code"`
+		Message string       `json:"error"`
+		Fields  []fieldError `json:"fields,omitempty"`
+	}
+)
+
+// domainErr give error exposed to clients showing message of the domain error
+func domainErr(target error, code apperr.Code, status int) *apperr.Error {
+	return apperr.New(code, status, target.Error())
+}
+
+// toAppErr give error exposed to clients of err, app error set by handler takes precedence over
+// domain errors, which take precedence over infrastructure ones
+func toAppErr(err error) *apperr.Error {
+	var appErr *apperr.Error
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+	for _, domain := range domainErrs {
+		if !errors.Is(err, domain.target) {
+			continue
+		}
+		if domain.detailed {
+			return domain.appErr.WithMessage(err.Error()).Wrap(err)
+		}
+		return domain.appErr.Wrap(err)
+	}
+	return apperr.From(err)
+}
+
+// isDetailed check if err is shown as detailed domain error, whose message describes the request itself
+func isDetailed(err error) bool {
+	var appErr *apperr.Error
+	if errors.As(err, &appErr) {
+		return false
+	}
+	for _, domain := range domainErrs {
+		if errors.Is(err, domain.target) {
+			return domain.detailed
+		}
+	}
+	return false
+}
+
+// translateErr give message of app error of err in locale of the translator. detailed domain error is only
+// translated by message taking its detail as {0}, otherwise the detail would be lost so it is kept in english
+func translateErr(trans ut.Translator, err error, appErr *apperr.Error) (string, bool) {
+	if !isDetailed(err) {
+		message, err := trans.T(string(appErr.Code))
+		return message, err == nil
+	}
+	if !strings.Contains(errMessages[trans.Locale()][appErr.Code], "{0}") {
+		return "", false
+	}
+	message, err := trans.T(string(appErr.Code), appErr.Message)
+	return message, err == nil
+}
+
+// abortWithErr stop the request with err, which is rendered by renderErr
+func abortWithErr(ctx *gin.Context, err error) {
+	_ = ctx.Error(err)
+	ctx.Abort()
+}
+
+// renderErr render the last error of the request as JSON error envelope in locale of Accept-Language header,
+// cause of server errors is logged instead of being shown to the user
+func renderErr(ctx *gin.Context) {
+	ctx.Next()
+
+	if len(ctx.Errors) == 0 || ctx.Writer.Written() {
+		return
+	}
+	err := ctx.Errors.Last().Err
+	appErr := toAppErr(err)
+	if appErr.Status >= http.StatusInternalServerError {
+		slog.ErrorContext(ctx.Request.Context(), "request failed", "error", appErr)
+	}
+
+	trans := validate.translator(ctx.GetHeader("Accept-Language"))
+	res := errorResponse{
+		Code:    appErr.Code,
+		Message: appErr.Message,
+	}
+	if message, ok := translateErr(trans, err, appErr); ok {
+		res.Message = message
+	}
+	var validationErr validationError
+	if errors.As(err, &validationErr) {
+		res.Fields = validationErr.fields(trans)
+		res.Message = res.Fields[0].Message
+	}
+	ctx.JSON(appErr.Status, res)
+}
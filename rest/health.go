@@ -0,0 +1,91 @@
+package rest
+
+import (
+	"context"
+	"gotinder/app"
+	"gotinder/infra"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// readyCheckTimeout bound each dependency check, so a hanging dependency can't hang the probe
+	readyCheckTimeout = 2 * time.Second
+
+	checkOK     = "ok"
+	checkFailed = "failed"
+)
+
+type (
+	// readiness is a type of readiness state of the server, flipped to draining on graceful shutdown
+	readiness struct {
+		draining atomic.Bool
+	}
+
+	// readyResponse is a type of "/readyz" response body
+	readyResponse struct {
+		Status           string            `json:"status"`
+		Checks           map[string]string `json:"checks"`
+		MigrationVersion string            `json:"migration_version,omitempty"`
+	}
+)
+
+// drain mark the server as not ready, so it stops receiving traffic before shutting down
+func (r *readiness) drain() {
+	r.draining.Store(true)
+}
+
+// healthz report the process is alive, regardless of its dependencies
+func healthz(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, gin.H{
+		"status": "alive",
+	})
+}
+
+// readyz report whether the server can serve requests: it is not draining, postgresql and redis can be reached,
+// along with version of the latest applied migration. app without a dependency skips its check
+func readyz(a *app.App, r *readiness) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if r.draining.Load() {
+			ctx.JSON(http.StatusServiceUnavailable, readyResponse{Status: "draining", Checks: map[string]string{}})
+			return
+		}
+
+		res := readyResponse{Status: "ready", Checks: map[string]string{}}
+		check := func(name string, fn func(ctx context.Context) error) {
+			checkCtx, cancel := context.WithTimeout(ctx.Request.Context(), readyCheckTimeout)
+			defer cancel()
+			if err := fn(checkCtx); err != nil {
+				slog.WarnContext(ctx.Request.Context(), "readiness check failed", "check", name, "error", err)
+				res.Status = "not_ready"
+				res.Checks[name] = checkFailed
+				return
+			}
+			res.Checks[name] = checkOK
+		}
+
+		if a.DB != nil {
+			check("postgres", a.DB.PingContext)
+			check("migration", func(ctx context.Context) error {
+				version, err := infra.MigrationVersion(ctx, a.DB, a.Config.Store.Migration.TableName)
+				res.MigrationVersion = version
+				return err
+			})
+		}
+		if a.Cache != nil {
+			check("redis", func(ctx context.Context) error {
+				return infra.PingRedis(ctx, a.Cache)
+			})
+		}
+
+		status := http.StatusOK
+		if res.Status != "ready" {
+			status = http.StatusServiceUnavailable
+		}
+		ctx.JSON(status, res)
+	}
+}
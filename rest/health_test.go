@@ -0,0 +1,70 @@
+//go:build integration
+
+package rest_test
+
+import (
+	"database/sql"
+	"encoding/json"
+	"gotinder/config"
+	"gotinder/rest"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type HealthTestSuite struct {
+	suite.Suite
+}
+
+func TestHealthTestSuite(t *testing.T) {
+	suite.Run(t, new(HealthTestSuite))
+}
+
+func (s *HealthTestSuite) SetupSuite() {
+	newPostgresTest(s.T())
+	newRedisTest(s.T())
+}
+
+func (s *HealthTestSuite) readyz(conn *sql.DB) (int, map[string]interface{}) {
+	a := newTestApp(conn, func(cfg *config.Configuration) {
+		cfg.Store.Migration.TableName = "test_scheme_migrations"
+	})
+	res := newHttpTest().
+		withPath("/readyz").
+		doWith(rest.NewHandler(a))
+	defer res.Body.Close()
+
+	var response map[string]interface{}
+	s.NoError(json.NewDecoder(res.Body).Decode(&response))
+	return res.StatusCode, response
+}
+
+func (s *HealthTestSuite) Test_Get_Healthz_Alive() {
+	res := newHttpTest().
+		withPath("/healthz").
+		do()
+
+	s.Equal(http.StatusOK, res.StatusCode)
+}
+
+func (s *HealthTestSuite) Test_Get_Readyz_Ready() {
+	status, response := s.readyz(pgTest.conn)
+
+	s.Equal(http.StatusOK, status)
+	s.Equal("ready", response["status"])
+	s.Equal(map[string]interface{}{"postgres": "ok", "migration": "ok", "redis": "ok"}, response["checks"])
+	s.NotEmpty(response["migration_version"])
+}
+
+func (s *HealthTestSuite) Test_Get_Readyz_PostgresDown() {
+	conn, err := sql.Open("postgres", pgTest.connStr)
+	s.Require().NoError(err)
+	s.Require().NoError(conn.Close())
+
+	status, response := s.readyz(conn)
+
+	s.Equal(http.StatusServiceUnavailable, status)
+	s.Equal("not_ready", response["status"])
+	s.Equal(map[string]interface{}{"postgres": "failed", "migration": "failed", "redis": "ok"}, response["checks"])
+}
@@ -0,0 +1,78 @@
+package rest
+
+import (
+	"gotinder/apperr"
+	"gotinder/pagination"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-pkgz/auth/token"
+	"github.com/google/uuid"
+)
+
+type (
+	// receivedLikeResponse is a type of "/likes/received" response item,
+	// profile fields are omitted for user whose plan is not entitled to see likes
+	receivedLikeResponse struct {
+		ID          *uuid.UUID `json:"id,omitempty"`
+		BirthOfDate *int64     `json:"birth_of_date,omitempty"`
+		Location    *string    `json:"location,omitempty"`
+		LikedAt     int64      `json:"liked_at"`
+		Blurred     bool       `json:"blurred"`
+	}
+)
+
+// RegisterLike register like handler
+func (v v1) RegisterLike() {
+	authMiddleware := v.auth.service.Middleware()
+
+	likeGroup := v.group.Group("/likes", asGin(authMiddleware.Auth), v.enrichActor)
+	likeGroup.GET("/received", v.findReceivedLikes)
+}
+
+// findReceivedLikes give list of users who liked the actor and not yet acted on by the actor
+func (v v1) findReceivedLikes(ctx *gin.Context) {
+	var param cursorQueryParam
+	if err := ctx.ShouldBind(&param); err != nil {
+		abortWithErr(ctx, apperr.InvalidRequest(err))
+		return
+	}
+
+	after, err := pagination.Decode(param.Cursor)
+	if err != nil {
+		abortWithErr(ctx, err)
+		return
+	}
+
+	user := token.MustGetUserInfo(ctx.Request)
+	received, count, next, err := v.Actions.ReceivedLikes(ctx.Request.Context(), user.StrAttr("user_id"), after, param.Limit)
+	if err != nil {
+		abortWithErr(ctx, err)
+		return
+	}
+
+	likes := make([]receivedLikeResponse, 0, len(received))
+	for i := range received {
+		like := &received[i]
+		likes = append(likes, receivedLikeResponse{
+			ID:          &like.ID,
+			BirthOfDate: &like.BirthOfDate,
+			Location:    formatPlace(like.Place),
+			LikedAt:     like.LikedAt,
+		})
+	}
+
+	if !hasFeature(user, featureSeeLikes) {
+		// cursor carries the liker id, so user without the feature only get the first page
+		next = ""
+		for i := range likes {
+			likes[i] = receivedLikeResponse{LikedAt: likes[i].LikedAt, Blurred: true}
+		}
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"data":        likes,
+		"count":       count,
+		"next_cursor": next,
+	})
+}
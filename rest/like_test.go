@@ -0,0 +1,162 @@
+//go:build integration
+
+package rest_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/stretchr/testify/suite"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type LikeTestSuite struct {
+	suite.Suite
+}
+
+func TestLikeTestSuite(t *testing.T) {
+	suite.Run(t, new(LikeTestSuite))
+}
+
+func (s *LikeTestSuite) SetupSuite() {
+	newPostgresTest(s.T())
+}
+
+func (s *LikeTestSuite) SetupTest() {
+	pgTest.migrate(s.T(), pgTest.conn)
+}
+
+// seedReceivedLikes create 3 users liking base user, one of them already liked back by base user
+func (s *LikeTestSuite) seedReceivedLikes() (selfId string, likerIds []string) {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte("Secret1234!"), bcrypt.DefaultCost)
+	s.Nil(err)
+	rows, err := sq.
+		StatementBuilder.
+		PlaceholderFormat(sq.Dollar).
+		Insert("users").
+		Columns("email", "password", "birth_of_date").
+		Values("liker.1@mail.com", string(hashedPassword), time.Now().Unix()).
+		Values("liker.2@mail.com", string(hashedPassword), time.Now().Unix()).
+		Values("liker.3@mail.com", string(hashedPassword), time.Now().Unix()).
+		Suffix("RETURNING id").
+		RunWith(pgTest.conn).
+		Query()
+	s.Nil(err)
+	for rows.Next() {
+		var likerId string
+		s.Nil(rows.Scan(&likerId))
+		likerIds = append(likerIds, likerId)
+	}
+
+	row := sq.
+		StatementBuilder.
+		PlaceholderFormat(sq.Dollar).
+		Select("id").
+		From("users").
+		Where("email = $1", "base@mail.com").
+		RunWith(pgTest.conn).
+		QueryRow()
+	s.Nil(row.Scan(&selfId))
+
+	_, err = sq.
+		StatementBuilder.
+		PlaceholderFormat(sq.Dollar).
+		Insert("likes").
+		Columns("self_id", "target_id", "created_at").
+		Values(likerIds[0], selfId, time.Now().Add(-3*time.Hour).Unix()).
+		Values(likerIds[1], selfId, time.Now().Add(-2*time.Hour).Unix()).
+		Values(likerIds[2], selfId, time.Now().Add(-1*time.Hour).Unix()).
+		Values(selfId, likerIds[2], time.Now().Unix()).
+		RunWith(pgTest.conn).
+		Exec()
+	s.Nil(err)
+	return selfId, likerIds
+}
+
+func (s *LikeTestSuite) Test_Get_LikesReceived_SubscribedUser_Success() {
+	tokens := getAuthToken(s.T(), pgTest.conn)
+	_, likerIds := s.seedReceivedLikes()
+
+	_, err := sq.
+		StatementBuilder.
+		PlaceholderFormat(sq.Dollar).
+		Update("users").
+		Set("subscribe_until", time.Now().Add(24*time.Hour).Unix()).
+		Where("email = ?", "base@mail.com").
+		RunWith(pgTest.conn).
+		Exec()
+	s.Nil(err)
+
+	res := newHttpTest().
+		withPath("/v1/likes/received?limit=1").
+		withHeader("Cookie", fmt.Sprintf("%s=%s", tokens[0][0], tokens[0][1])).
+		withHeader("Cookie", fmt.Sprintf("%s=%s", tokens[1][0], tokens[1][1])).
+		do()
+
+	s.Equal(http.StatusOK, res.StatusCode)
+	body, err := io.ReadAll(res.Body)
+	s.Nil(err)
+	defer res.Body.Close()
+	var response struct {
+		Data []struct {
+			ID      string `json:"id"`
+			Blurred bool   `json:"blurred"`
+		} `json:"data"`
+		Count      int    `json:"count"`
+		NextCursor string `json:"next_cursor"`
+	}
+	s.Nil(json.Unmarshal(body, &response))
+	s.Equal(2, response.Count)
+	s.Len(response.Data, 1)
+	s.Equal(likerIds[1], response.Data[0].ID)
+	s.False(response.Data[0].Blurred)
+	s.NotEmpty(response.NextCursor)
+
+	res = newHttpTest().
+		withPath(fmt.Sprintf("/v1/likes/received?limit=1&cursor=%s", response.NextCursor)).
+		withHeader("Cookie", fmt.Sprintf("%s=%s", tokens[0][0], tokens[0][1])).
+		withHeader("Cookie", fmt.Sprintf("%s=%s", tokens[1][0], tokens[1][1])).
+		do()
+
+	s.Equal(http.StatusOK, res.StatusCode)
+	body, err = io.ReadAll(res.Body)
+	s.Nil(err)
+	defer res.Body.Close()
+	s.Nil(json.Unmarshal(body, &response))
+	s.Len(response.Data, 1)
+	s.Equal(likerIds[0], response.Data[0].ID)
+	s.Empty(response.NextCursor)
+}
+
+func (s *LikeTestSuite) Test_Get_LikesReceived_NonSubscribedUser_Blurred() {
+	tokens := getAuthToken(s.T(), pgTest.conn)
+	s.seedReceivedLikes()
+
+	res := newHttpTest().
+		withPath("/v1/likes/received?limit=1").
+		withHeader("Cookie", fmt.Sprintf("%s=%s", tokens[0][0], tokens[0][1])).
+		withHeader("Cookie", fmt.Sprintf("%s=%s", tokens[1][0], tokens[1][1])).
+		do()
+
+	s.Equal(http.StatusOK, res.StatusCode)
+	body, err := io.ReadAll(res.Body)
+	s.Nil(err)
+	defer res.Body.Close()
+	var response struct {
+		Data       []map[string]interface{} `json:"data"`
+		Count      int                      `json:"count"`
+		NextCursor string                   `json:"next_cursor"`
+	}
+	s.Nil(json.Unmarshal(body, &response))
+	s.Equal(2, response.Count)
+	s.Len(response.Data, 1)
+	s.NotContains(response.Data[0], "id")
+	s.NotContains(response.Data[0], "birth_of_date")
+	s.Equal(true, response.Data[0]["blurred"])
+	s.Empty(response.NextCursor)
+}
@@ -0,0 +1,48 @@
+package rest
+
+import "gotinder/apperr"
+
+// errMessages map error codes to messages shown to the user of each locale other than english,
+// english message is the one of the error itself. errors without message on the locale are shown in english,
+// so are detailed errors without message taking the detail as {0}
+var errMessages = map[string]map[apperr.Code]string{
+	"id": {
+		apperr.CodeInvalidRequest: "permintaan tidak valid",
+		apperr.CodeUnauthorized:   "tidak terautentikasi",
+		apperr.CodeForbidden:      "akses ditolak",
+		apperr.CodeNotFound:       "data tidak ditemukan",
+		apperr.CodeConflict:       "data sudah ada",
+		apperr.CodeInternal:       "terjadi kesalahan pada server",
+		apperr.CodeUnavailable:    "layanan sedang tidak tersedia, coba lagi nanti",
+		apperr.CodeTimeout:        "permintaan melebihi batas waktu",
+
+		"admin_required":            "akses admin diperlukan",
+		"feature_required":          "fitur tidak tersedia pada paket anda",
+		"passport_required":         "passport tidak tersedia pada paket anda",
+		"quota_exceeded":            "melebihi batas maksimal aksi",
+		"like_not_found":            "like tidak ditemukan",
+		"coupon_not_found":          "kupon tidak ditemukan",
+		"coupon_already_used":       "kupon tidak ditemukan atau sudah digunakan",
+		"coupon_campaign_not_found": "kampanye kupon tidak ditemukan",
+		"coupon_revoked":            "kupon telah dicabut",
+		"coupon_expired":            "kupon telah kedaluwarsa",
+		"coupon_exhausted":          "kupon telah mencapai batas penggunaan",
+		"coupon_user_exhausted":     "kupon telah mencapai batas penggunaan per pengguna",
+		"passport_not_found":        "passport tidak ditemukan",
+		"city_not_found":            "kota tidak ditemukan",
+		"plan_not_purchasable":      "paket tidak dapat dibeli",
+		"payment_session_not_found": "sesi pembayaran tidak ditemukan",
+		"payment_provider_failure":  "penyedia pembayaran gagal",
+		"period_ended":              "periode pembayaran telah berakhir",
+		"invalid_signature":         "tanda tangan tidak valid",
+		"origin_not_found":          "pengguna tidak ditemukan",
+		"plan_not_found":            "paket tidak ditemukan",
+		"subscription_not_found":    "langganan tidak ditemukan",
+		"subscriber_not_found":      "pelanggan tidak ditemukan",
+		"user_not_found":            "pengguna tidak ditemukan",
+		"weak_password":             "kata sandi tidak aman: {0}",
+		"invalid_point":             "koordinat tidak valid: {0}",
+		"invalid_coupon_batch":      "batch kupon tidak valid: {0}",
+		"invalid_cursor":            "cursor tidak valid",
+	},
+}
@@ -1,16 +1,12 @@
 package rest
 
 import (
-	"database/sql"
-	"gotinder/infra"
+	"gotinder/apperr"
+	"gotinder/geo"
 	"net/http"
-	"time"
 
-	sq "github.com/Masterminds/squirrel"
 	"github.com/gin-gonic/gin"
 	"github.com/go-pkgz/auth/token"
-	"github.com/google/uuid"
-	"github.com/pkg/errors"
 )
 
 type (
@@ -26,72 +22,76 @@ func (v v1) RegisterLocation() {
 	authMiddleware := v.auth.service.Middleware()
 
 	locationGroup := v.group.Group("/locations", asGin(authMiddleware.Auth))
-	locationGroup.POST("", updateLocation)
+	locationGroup.POST("", v.updateLocation)
+	locationGroup.DELETE("", v.enrichActor, v.stopLocationSharing)
+	locationGroup.GET("/cities", v.findCities)
+
+	passportGroup := locationGroup.Group("/passport", v.enrichActor)
+	passportGroup.GET("", v.findPassport)
+	passportGroup.PUT("", v.setPassport)
+	passportGroup.DELETE("", v.removePassport)
 }
 
 // updateLocation do process to update user current location
-func updateLocation(ctx *gin.Context) {
+func (v v1) updateLocation(ctx *gin.Context) {
 	var req locationRequest
 	if err := ctx.ShouldBind(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
+		abortWithErr(ctx, apperr.InvalidRequest(err))
 		return
 	}
 
-	user := token.MustGetUserInfo(ctx.Request)
-
-	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
-	findUserQuery, _, err := psql.Select("id").From("users").Where("email = $1").ToSql()
+	point, err := geo.ParsePoint(req.Lat, req.Lng)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{
-			"error": errors.Wrap(err, "failed to build find user query").Error(),
-		})
+		abortWithErr(ctx, err)
 		return
 	}
 
-	row := infra.PgConn.QueryRow(findUserQuery, user.Name)
-	var userID uuid.UUID
-	if err := row.Scan(&userID); err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			ctx.JSON(http.StatusNotFound, gin.H{
-				"error": "user not found",
-			})
-			return
-		}
-		ctx.JSON(http.StatusInternalServerError, gin.H{
-			"error": errors.Wrap(err, "failed to find user").Error(),
-		})
+	u := token.MustGetUserInfo(ctx.Request)
+	found, err := v.Users.FindByEmail(ctx.Request.Context(), u.Name)
+	if err != nil {
+		abortWithErr(ctx, err)
 		return
 	}
 
-	upsertLatestLocation, _, err := psql.
-		Insert("latest_locations").
-		Columns("updated_at", "lat", "lng", "user_id").
-		Values("$1", "$2", "$3", "$4").
-		Suffix(`
-			ON CONFLICT (user_id) DO UPDATE SET 
-			updated_at=EXCLUDED.updated_at, 
-			lat=EXCLUDED.lat, 
-			lng=EXCLUDED.lng, 
-			location=EXCLUDED.location
-		`).
-		ToSql()
+	updated, place, err := v.Locations.Update(ctx.Request.Context(), found.ID.String(), point)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{
-			"error": errors.Wrap(err, "failed to build upsert latest location query").Error(),
+		abortWithErr(ctx, err)
+		return
+	}
+	if !updated {
+		ctx.JSON(http.StatusOK, gin.H{
+			"message": "location update skipped",
+			"updated": false,
 		})
 		return
 	}
 
-	if _, err := infra.PgConn.Exec(upsertLatestLocation, time.Now().Unix(), req.Lat, req.Lng, userID); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{
-			"error": errors.Wrap(err, "failed to record request").Error(),
-		})
+	ctx.JSON(http.StatusOK, gin.H{
+		"message":  "success update location",
+		"updated":  true,
+		"location": formatPlace(place),
+	})
+}
+
+// stopLocationSharing do process to hide user from others nearby until they update location again
+func (v v1) stopLocationSharing(ctx *gin.Context) {
+	user := token.MustGetUserInfo(ctx.Request)
+
+	if err := v.Locations.StopSharing(ctx.Request.Context(), user.StrAttr("user_id")); err != nil {
+		abortWithErr(ctx, err)
 		return
 	}
 
 	ctx.JSON(http.StatusOK, gin.H{
-		"message": "success update location",
+		"message": "success stop sharing location",
 	})
 }
+
+// formatPlace give place as "City, CC", nil when location is not resolved to any place
+func formatPlace(place *geo.Place) *string {
+	if place == nil {
+		return nil
+	}
+	label := place.String()
+	return &label
+}
@@ -1,11 +1,18 @@
+//go:build integration
+
 package rest_test
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
-	"gotinder/infra"
+	"gotinder/config"
+	"gotinder/geo"
+	"gotinder/rest"
+	"io"
 	"net/http"
-	"strconv"
 	"testing"
+	"time"
 
 	sq "github.com/Masterminds/squirrel"
 	"github.com/stretchr/testify/suite"
@@ -20,16 +27,16 @@ func TestLocationTestSuite(t *testing.T) {
 }
 
 func (s *LocationTestSuite) SetupSuite() {
-	pg := newPostgresTest(s.T())
-	infra.NewPgConnection(pg.connStr)
+	newPostgresTest(s.T())
+	newRedisTest(s.T())
 }
 
 func (s *LocationTestSuite) SetupTest() {
-	pgTest.migrate(s.T(), infra.PgConn)
+	pgTest.migrate(s.T(), pgTest.conn)
 }
 
 func (s *LocationTestSuite) Test_Post_Location_Success() {
-	tokens := getAuthToken(s.T(), infra.PgConn)
+	tokens := getAuthToken(s.T(), pgTest.conn)
 
 	lat, lng := "-7.97727", "112.6341"
 	res := newHttpTest().
@@ -44,25 +51,381 @@ func (s *LocationTestSuite) Test_Post_Location_Success() {
 		do()
 
 	s.Equal(http.StatusOK, res.StatusCode)
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	s.Nil(err)
+	var response struct {
+		Location string `json:"location"`
+	}
+	s.Nil(json.Unmarshal(body, &response))
+	s.Equal("Malang, ID", response.Location)
+
 	row := sq.
 		StatementBuilder.
 		PlaceholderFormat(sq.Dollar).
-		Select("latest_locations.lat", "latest_locations.lng", fmt.Sprintf("st_distancesphere(latest_locations.location::geometry,ST_SetSRID(ST_MakePoint(%s,%s), 4326)) AS distance", lat, lng)).
+		Select(
+			"latest_locations.lat",
+			"latest_locations.lng",
+			"ST_Y(latest_locations.location::geometry)",
+			"ST_X(latest_locations.location::geometry)",
+			"latest_locations.city",
+			"latest_locations.country_code",
+		).
+		Column("ST_DistanceSphere(latest_locations.location::geometry, ST_SetSRID(ST_MakePoint(?::float8, ?::float8), 4326))", lng, lat).
 		From("latest_locations").
 		Join("users ON users.id = latest_locations.user_id").
-		Where("users.email = $1", "base@mail.com").
-		RunWith(infra.PgConn).
+		Where("users.email = ?", "base@mail.com").
+		RunWith(pgTest.conn).
 		QueryRow()
 
 	var loc struct {
-		Lat      string
-		Lng      string
-		Location string
+		Lat         float64
+		Lng         float64
+		PointLat    float64
+		PointLng    float64
+		City        string
+		CountryCode string
+		Distance    float64
 	}
-	s.Nil(row.Scan(&loc.Lat, &loc.Lng, &loc.Location))
-	s.Equal(lat, loc.Lat)
-	s.Equal(lng, loc.Lng)
-	parsedLocation, err := strconv.ParseFloat(loc.Location, 64)
+	s.Nil(row.Scan(&loc.Lat, &loc.Lng, &loc.PointLat, &loc.PointLng, &loc.City, &loc.CountryCode, &loc.Distance))
+	// stored location is jittered within the policy, and its geography follows the stored coordinate
+	s.Greater(loc.Distance, float64(0))
+	s.LessOrEqual(loc.Distance, geo.DefaultFuzzPolicy.JitterInMeter+1)
+	s.InDelta(loc.Lat, loc.PointLat, 0.0001)
+	s.InDelta(loc.Lng, loc.PointLng, 0.0001)
+	s.Equal("Malang", loc.City)
+	s.Equal("ID", loc.CountryCode)
+
+	// real location is kept in history
+	var history struct {
+		Lat string
+		Lng string
+	}
+	s.Nil(sq.
+		StatementBuilder.
+		PlaceholderFormat(sq.Dollar).
+		Select("location_histories.lat", "location_histories.lng").
+		From("location_histories").
+		Join("users ON users.id = location_histories.user_id").
+		Where("users.email = ?", "base@mail.com").
+		RunWith(pgTest.conn).
+		QueryRow().
+		Scan(&history.Lat, &history.Lng))
+	s.Equal(lat, history.Lat)
+	s.Equal(lng, history.Lng)
+}
+
+func (s *LocationTestSuite) postLocation(handler http.Handler, tokens [][]string, lat, lng string) bool {
+	res := newHttpTest().
+		withPath("/v1/locations").
+		withMethod(http.MethodPost).
+		withBody(map[string]interface{}{
+			"lat": lat,
+			"lng": lng,
+		}).
+		withHeader("Cookie", fmt.Sprintf("%s=%s", tokens[0][0], tokens[0][1])).
+		withHeader("Cookie", fmt.Sprintf("%s=%s", tokens[1][0], tokens[1][1])).
+		doWith(handler)
+
+	s.Equal(http.StatusOK, res.StatusCode)
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	s.Nil(err)
+	var response struct {
+		Updated bool `json:"updated"`
+	}
+	s.Nil(json.Unmarshal(body, &response))
+	return response.Updated
+}
+
+func (s *LocationTestSuite) countLocationHistories(email string) int {
+	var histories int
+	s.Nil(sq.
+		StatementBuilder.
+		PlaceholderFormat(sq.Dollar).
+		Select("COUNT(*)").
+		From("location_histories").
+		Join("users ON users.id = location_histories.user_id").
+		Where("users.email = ?", email).
+		RunWith(pgTest.conn).
+		QueryRow().
+		Scan(&histories))
+	return histories
+}
+
+func (s *LocationTestSuite) Test_Post_Location_Throttled() {
+	handler := rest.NewHandler(newTestApp(pgTest.conn, func(cfg *config.Configuration) {
+		cfg.Discovery.Throttling.MaxUpdates = 2
+	}))
+	tokens := getAuthToken(s.T(), pgTest.conn)
+
+	s.True(s.postLocation(handler, tokens, "-7.97727", "112.6341"))
+	// barely moved within the interval, history is still recorded
+	s.False(s.postLocation(handler, tokens, "-7.97730", "112.6342"))
+	s.Equal(2, s.countLocationHistories("base@mail.com"))
+
+	s.True(s.postLocation(handler, tokens, "-7.2575", "112.7521"))
+	s.Equal(3, s.countLocationHistories("base@mail.com"))
+
+	// moved, but exceeds max updates within the interval
+	s.False(s.postLocation(handler, tokens, "-6.2088", "106.8456"))
+	s.Equal(4, s.countLocationHistories("base@mail.com"))
+}
+
+func (s *LocationTestSuite) subscribe(email string) {
+	_, err := sq.
+		StatementBuilder.
+		PlaceholderFormat(sq.Dollar).
+		Update("users").
+		Set("subscribe_until", time.Now().Add(24*time.Hour).Unix()).
+		Where("email = ?", email).
+		RunWith(pgTest.conn).
+		Exec()
+	s.Nil(err)
+}
+
+func (s *LocationTestSuite) Test_Get_LocationCities_Success() {
+	tokens := getAuthToken(s.T(), pgTest.conn)
+
+	res := newHttpTest().
+		withPath("/v1/locations/cities?q=ja").
+		withHeader("Cookie", fmt.Sprintf("%s=%s", tokens[0][0], tokens[0][1])).
+		withHeader("Cookie", fmt.Sprintf("%s=%s", tokens[1][0], tokens[1][1])).
+		do()
+
+	s.Equal(http.StatusOK, res.StatusCode)
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
 	s.Nil(err)
-	s.Less(parsedLocation, float64(1))
+	var response struct {
+		Data []struct {
+			Name        string `json:"name"`
+			CountryCode string `json:"country_code"`
+		} `json:"data"`
+	}
+	s.Nil(json.Unmarshal(body, &response))
+	// every bundled city of the prefix is returned, most populated first
+	s.Len(response.Data, 3)
+	s.Equal("Jakarta", response.Data[0].Name)
+	s.Equal("ID", response.Data[0].CountryCode)
+}
+
+func (s *LocationTestSuite) Test_Put_LocationPassport_City_Success() {
+	tokens := getAuthToken(s.T(), pgTest.conn)
+	s.subscribe("base@mail.com")
+
+	res := newHttpTest().
+		withPath("/v1/locations/passport").
+		withMethod(http.MethodPut).
+		withBody(map[string]interface{}{
+			"city": "jakarta",
+		}).
+		withHeader("Cookie", fmt.Sprintf("%s=%s", tokens[0][0], tokens[0][1])).
+		withHeader("Cookie", fmt.Sprintf("%s=%s", tokens[1][0], tokens[1][1])).
+		do()
+	s.Equal(http.StatusOK, res.StatusCode)
+
+	res = newHttpTest().
+		withPath("/v1/locations/passport").
+		withHeader("Cookie", fmt.Sprintf("%s=%s", tokens[0][0], tokens[0][1])).
+		withHeader("Cookie", fmt.Sprintf("%s=%s", tokens[1][0], tokens[1][1])).
+		do()
+
+	s.Equal(http.StatusOK, res.StatusCode)
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	s.Nil(err)
+	var response struct {
+		Data struct {
+			Active   bool `json:"active"`
+			Passport struct {
+				Lat  float64 `json:"lat"`
+				Lng  float64 `json:"lng"`
+				City *struct {
+					Name string `json:"name"`
+				} `json:"city"`
+			} `json:"passport"`
+		} `json:"data"`
+	}
+	s.Nil(json.Unmarshal(body, &response))
+	s.True(response.Data.Active)
+	s.InDelta(-6.2088, response.Data.Passport.Lat, 0.0001)
+	s.InDelta(106.8456, response.Data.Passport.Lng, 0.0001)
+	s.NotNil(response.Data.Passport.City)
+	s.Equal("Jakarta", response.Data.Passport.City.Name)
+}
+
+func (s *LocationTestSuite) Test_Put_LocationPassport_Coordinate_Success() {
+	tokens := getAuthToken(s.T(), pgTest.conn)
+	s.subscribe("base@mail.com")
+
+	res := newHttpTest().
+		withPath("/v1/locations/passport").
+		withMethod(http.MethodPut).
+		withBody(map[string]interface{}{
+			"lat": "-8.65",
+			"lng": "115.2167",
+		}).
+		withHeader("Cookie", fmt.Sprintf("%s=%s", tokens[0][0], tokens[0][1])).
+		withHeader("Cookie", fmt.Sprintf("%s=%s", tokens[1][0], tokens[1][1])).
+		do()
+
+	s.Equal(http.StatusOK, res.StatusCode)
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	s.Nil(err)
+	var response struct {
+		Data struct {
+			Lat  float64     `json:"lat"`
+			Lng  float64     `json:"lng"`
+			City interface{} `json:"city"`
+		} `json:"data"`
+	}
+	s.Nil(json.Unmarshal(body, &response))
+	s.InDelta(-8.65, response.Data.Lat, 0.0001)
+	s.InDelta(115.2167, response.Data.Lng, 0.0001)
+	s.Nil(response.Data.City)
+}
+
+func (s *LocationTestSuite) Test_Put_LocationPassport_NonSubscribedUser() {
+	tokens := getAuthToken(s.T(), pgTest.conn)
+
+	res := newHttpTest().
+		withPath("/v1/locations/passport").
+		withMethod(http.MethodPut).
+		withBody(map[string]interface{}{
+			"city": "Jakarta",
+		}).
+		withHeader("Cookie", fmt.Sprintf("%s=%s", tokens[0][0], tokens[0][1])).
+		withHeader("Cookie", fmt.Sprintf("%s=%s", tokens[1][0], tokens[1][1])).
+		do()
+
+	s.Equal(http.StatusForbidden, res.StatusCode)
+}
+
+func (s *LocationTestSuite) Test_LocationHistoryRetentionJob_Success() {
+	getAuthToken(s.T(), pgTest.conn)
+
+	var userId string
+	s.Nil(sq.
+		StatementBuilder.
+		PlaceholderFormat(sq.Dollar).
+		Select("id").
+		From("users").
+		Where("email = ?", "base@mail.com").
+		RunWith(pgTest.conn).
+		QueryRow().
+		Scan(&userId))
+
+	_, err := sq.
+		StatementBuilder.
+		PlaceholderFormat(sq.Dollar).
+		Insert("location_histories").
+		Columns("created_at", "lat", "lng", "user_id").
+		Values(time.Now().Add(-31*24*time.Hour).Unix(), "-7.97727", "112.6341", userId).
+		Values(time.Now().Add(-24*time.Hour).Unix(), "-7.97727", "112.6341", userId).
+		RunWith(pgTest.conn).
+		Exec()
+	s.Nil(err)
+
+	s.Nil(newTestApp(pgTest.conn).Services.Locations.NewHistoryRetentionJob(30 * 24 * time.Hour)(context.Background()))
+
+	var histories int
+	s.Nil(sq.
+		StatementBuilder.
+		PlaceholderFormat(sq.Dollar).
+		Select("COUNT(*)").
+		From("location_histories").
+		Where("user_id = ?", userId).
+		RunWith(pgTest.conn).
+		QueryRow().
+		Scan(&histories))
+	s.Equal(1, histories)
+}
+
+func (s *LocationTestSuite) Test_JitterLegacyLocations_Success() {
+	getAuthToken(s.T(), pgTest.conn)
+
+	var userId string
+	s.Nil(sq.
+		StatementBuilder.
+		PlaceholderFormat(sq.Dollar).
+		Select("id").
+		From("users").
+		Where("email = ?", "base@mail.com").
+		RunWith(pgTest.conn).
+		QueryRow().
+		Scan(&userId))
+
+	// recorded before locations were jittered, so it is real location
+	exact := geo.Point{Lat: -7.97727, Lng: 112.6341}
+	_, err := sq.
+		StatementBuilder.
+		PlaceholderFormat(sq.Dollar).
+		Insert("latest_locations").
+		Columns("user_id", "updated_at", "lat", "lng").
+		Values(userId, time.Now().Unix(), exact.Lat, exact.Lng).
+		RunWith(pgTest.conn).
+		Exec()
+	s.Nil(err)
+
+	a := newTestApp(pgTest.conn)
+	indexed, err := a.Services.Locations.RebuildNearbyIndex(context.Background())
+	s.Nil(err)
+	s.Equal(1, indexed)
+
+	var latest geo.Point
+	var jittered bool
+	s.Nil(sq.
+		StatementBuilder.
+		PlaceholderFormat(sq.Dollar).
+		Select("lat", "lng", "jittered").
+		From("latest_locations").
+		Where("user_id = ?", userId).
+		RunWith(pgTest.conn).
+		QueryRow().
+		Scan(&latest.Lat, &latest.Lng, &jittered))
+	s.True(jittered)
+	s.Greater(exact.DistanceTo(latest), 1.0)
+
+	// jittered location is never jittered again
+	count, err := a.Services.Locations.JitterLegacyLocations(context.Background())
+	s.Nil(err)
+	s.Equal(0, count)
+}
+
+func (s *LocationTestSuite) Test_Cities_Distance_KnownPairs() {
+	cases := []struct {
+		from, to      string
+		distanceInKm  float64
+		toleranceInKm float64
+	}{
+		{"Jakarta", "Surabaya", 663, 5},
+		{"Malang", "Surabaya", 80, 2},
+		{"London", "Paris", 344, 3},
+		{"New York", "Los Angeles", 3936, 10},
+	}
+	for _, c := range cases {
+		var distance float64
+		var from, to geo.Point
+		s.Nil(sq.
+			StatementBuilder.
+			PlaceholderFormat(sq.Dollar).
+			Select(
+				"ST_DistanceSphere(a.location::geometry, b.location::geometry)",
+				"ST_Y(a.location::geometry)",
+				"ST_X(a.location::geometry)",
+				"ST_Y(b.location::geometry)",
+				"ST_X(b.location::geometry)",
+			).
+			From("cities a").
+			Join("cities b ON b.name = ?", c.to).
+			Where("a.name = ?", c.from).
+			RunWith(pgTest.conn).
+			QueryRow().
+			Scan(&distance, &from.Lat, &from.Lng, &to.Lat, &to.Lng), c.from)
+
+		s.InDelta(c.distanceInKm, distance/1000, c.toleranceInKm, "%s-%s", c.from, c.to)
+		s.InDelta(from.DistanceTo(to), distance, 1, "%s-%s", c.from, c.to)
+	}
 }
@@ -0,0 +1,946 @@
+package rest_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"gotinder/app"
+	"gotinder/config"
+	"gotinder/coupon"
+	"gotinder/infra"
+	"gotinder/logging"
+	"gotinder/memory"
+	"gotinder/rest"
+	"gotinder/user"
+	"io"
+	"log/slog"
+	"mime"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/suite"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// MemoryTestSuite serve handlers on the in-memory store, so it runs without Docker
+type MemoryTestSuite struct {
+	suite.Suite
+	store   *memory.Store
+	app     *app.App
+	handler http.Handler
+}
+
+func TestMemoryTestSuite(t *testing.T) {
+	suite.Run(t, new(MemoryTestSuite))
+}
+
+func (s *MemoryTestSuite) SetupTest() {
+	s.store = memory.NewStore()
+	s.app = newMemoryApp(s.store)
+	s.handler = rest.NewHandler(s.app)
+}
+
+// newMemoryApp give app kept on the store
+func newMemoryApp(store *memory.Store) *app.App {
+	cfg := new(config.Configuration)
+	cfg.Payment.Provider = "fake"
+	cfg.Payment.WebhookSecret = "test_webhook_secret"
+	cfg.Discovery.Fuzzing.Secret = "test_fuzzing_secret"
+	a := app.NewMemory(cfg, store)
+	// cities are seeded on start like main does
+	if err := a.Services.Locations.SeedCities(context.Background()); err != nil {
+		panic(err)
+	}
+	return a
+}
+
+// register sign up the user and log them in, giving their id and auth cookies
+func (s *MemoryTestSuite) register(email string) (string, [][]string) {
+	res := newHttpTest().
+		withPath("/v1/auth/register").
+		withMethod(http.MethodPost).
+		withBody(map[string]interface{}{
+			"email":         email,
+			"password":      "Secret1234!",
+			"birth_of_date": time.Now().Unix(),
+		}).
+		doWith(s.handler)
+	s.Require().Equal(http.StatusOK, res.StatusCode)
+
+	res = newHttpTest().
+		withPath("/v1/auth/direct/login").
+		withMethod(http.MethodPost).
+		withBody(map[string]interface{}{
+			"user":   email,
+			"passwd": "Secret1234!",
+		}).
+		doWith(s.handler)
+	s.Require().Equal(http.StatusOK, res.StatusCode)
+
+	cookies := make([][]string, 0)
+	for _, cookie := range res.Cookies() {
+		cookies = append(cookies, []string{cookie.Name, cookie.Value})
+	}
+	s.Require().Len(cookies, 2)
+
+	u, err := memory.NewUserRepository(s.store).FindByEmail(context.Background(), email)
+	s.Require().Nil(err)
+	return u.ID.String(), cookies
+}
+
+// do serve the request as the user of the tokens
+func (s *MemoryTestSuite) do(method, path string, body any, tokens [][]string) *http.Response {
+	req := newHttpTest().
+		withPath(path).
+		withMethod(method)
+	if body != nil {
+		req.withBody(body)
+	}
+	for _, token := range tokens {
+		req.withHeader("Cookie", fmt.Sprintf("%s=%s", token[0], token[1]))
+	}
+	return req.doWith(s.handler)
+}
+
+// decode read JSON response body into v
+func (s *MemoryTestSuite) decode(res *http.Response, v any) {
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	s.Require().Nil(err)
+	s.Require().Nil(json.Unmarshal(body, v))
+}
+
+func (s *MemoryTestSuite) Test_Apps_Isolated() {
+	s.register("base@mail.com")
+
+	other := rest.NewHandler(newMemoryApp(memory.NewStore()))
+	res := newHttpTest().
+		withPath("/v1/auth/direct/login").
+		withMethod(http.MethodPost).
+		withBody(map[string]interface{}{
+			"user":   "base@mail.com",
+			"passwd": "Secret1234!",
+		}).
+		doWith(other)
+	s.Equal(http.StatusForbidden, res.StatusCode)
+}
+
+func (s *MemoryTestSuite) Test_Post_AuthRegister_Conflict() {
+	s.register("base@mail.com")
+
+	res := newHttpTest().
+		withPath("/v1/auth/register").
+		withMethod(http.MethodPost).
+		withBody(map[string]interface{}{
+			"email":         "base@mail.com",
+			"password":      "Secret1234!",
+			"birth_of_date": time.Now().Unix(),
+		}).
+		doWith(s.handler)
+
+	s.Equal(http.StatusConflict, res.StatusCode)
+	var response map[string]interface{}
+	s.decode(res, &response)
+	s.Equal("conflict", response["code"])
+	// cause stays in logs
+	s.Equal("resource already exists", response["error"])
+}
+
+func (s *MemoryTestSuite) Test_Get_ActionLikes_Unauthorized() {
+	res := s.do(http.MethodGet, "/v1/actions/likes?limit=10", nil, nil)
+
+	s.Equal(http.StatusUnauthorized, res.StatusCode)
+	s.Equal("application/json; charset=utf-8", res.Header.Get("Content-Type"))
+	var response map[string]interface{}
+	s.decode(res, &response)
+	s.Equal("unauthorized", response["code"])
+}
+
+func (s *MemoryTestSuite) Test_Post_AuthRegister_WeakPassword() {
+	res := newHttpTest().
+		withPath("/v1/auth/register").
+		withMethod(http.MethodPost).
+		withBody(map[string]interface{}{
+			"email":         "weak@mail.com",
+			"password":      "weak",
+			"birth_of_date": time.Now().Unix(),
+		}).
+		doWith(s.handler)
+
+	s.Equal(http.StatusBadRequest, res.StatusCode)
+	var response map[string]interface{}
+	s.decode(res, &response)
+	s.Equal("weak_password", response["code"])
+	s.Contains(response["error"], "insecure password")
+}
+
+func (s *MemoryTestSuite) Test_Post_AuthRegister_InvalidFields() {
+	res := newHttpTest().
+		withPath("/v1/auth/register").
+		withMethod(http.MethodPost).
+		withBody(map[string]interface{}{
+			"email": "not-an-email",
+		}).
+		doWith(s.handler)
+
+	s.Equal(http.StatusBadRequest, res.StatusCode)
+	var response map[string]interface{}
+	s.decode(res, &response)
+	s.Equal("invalid_request", response["code"])
+	s.Equal("email must be a valid email address", response["error"])
+	s.Equal([]interface{}{
+		map[string]interface{}{"field": "email", "error": "email must be a valid email address"},
+		map[string]interface{}{"field": "password", "error": "password is a required field"},
+		map[string]interface{}{"field": "birth_of_date", "error": "birth_of_date is a required field"},
+	}, response["fields"])
+}
+
+func (s *MemoryTestSuite) Test_Post_AuthRegister_Localized() {
+	res := newHttpTest().
+		withPath("/v1/auth/register").
+		withMethod(http.MethodPost).
+		withHeader("Accept-Language", "fr-FR, id-ID;q=0.9, en;q=0.8").
+		withBody(map[string]interface{}{
+			"email":         "weak@mail.com",
+			"birth_of_date": time.Now().Unix(),
+		}).
+		doWith(s.handler)
+
+	s.Equal(http.StatusBadRequest, res.StatusCode)
+	var response map[string]interface{}
+	s.decode(res, &response)
+	s.Equal([]interface{}{
+		map[string]interface{}{"field": "password", "error": "password wajib diisi"},
+	}, response["fields"])
+
+	res = newHttpTest().
+		withPath("/v1/auth/register").
+		withMethod(http.MethodPost).
+		withHeader("Accept-Language", "id").
+		withBody(map[string]interface{}{
+			"email":         "weak@mail.com",
+			"password":      "weak",
+			"birth_of_date": time.Now().Unix(),
+		}).
+		doWith(s.handler)
+
+	s.Equal(http.StatusBadRequest, res.StatusCode)
+	response = nil
+	s.decode(res, &response)
+	s.Equal("weak_password", response["code"])
+	s.Contains(response["error"], "kata sandi tidak aman")
+	// detail of the rejected password is kept
+	s.Contains(response["error"], "insecure password, try including")
+	s.NotContains(response, "fields")
+}
+
+func (s *MemoryTestSuite) Test_Actions_LikePassWithdraw() {
+	_, tokens := s.register("base@mail.com")
+	likedID, _ := s.register("liked@mail.com")
+	passedID, _ := s.register("passed@mail.com")
+
+	s.Equal(http.StatusOK, s.do(http.MethodPost, "/v1/actions/likes", map[string]string{"id": likedID}, tokens).StatusCode)
+	s.Equal(http.StatusOK, s.do(http.MethodPost, "/v1/actions/passes", map[string]string{"id": passedID}, tokens).StatusCode)
+
+	var likes struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	res := s.do(http.MethodGet, "/v1/actions/likes?limit=10", nil, tokens)
+	s.Equal(http.StatusOK, res.StatusCode)
+	s.decode(res, &likes)
+	s.Len(likes.Data, 1)
+	s.Equal(likedID, likes.Data[0].ID)
+
+	s.Equal(http.StatusOK, s.do(http.MethodDelete, "/v1/actions/likes/"+likedID, nil, tokens).StatusCode)
+	s.Equal(http.StatusNotFound, s.do(http.MethodDelete, "/v1/actions/likes/"+likedID, nil, tokens).StatusCode)
+}
+
+func (s *MemoryTestSuite) Test_Get_Pages_LimitBounded() {
+	_, tokens := s.register("base@mail.com")
+
+	for _, path := range []string{
+		"/v1/actions/likes?limit=101",
+		"/v1/likes/received?limit=100000000",
+		"/v1/recommendations?limit=101",
+	} {
+		res := s.do(http.MethodGet, path, nil, tokens)
+		s.Equal(http.StatusBadRequest, res.StatusCode, path)
+		var response map[string]interface{}
+		s.decode(res, &response)
+		s.Equal("invalid_request", response["code"], path)
+		s.Equal("limit must be 100 or less", response["error"], path)
+	}
+	s.Equal(http.StatusOK, s.do(http.MethodGet, "/v1/actions/likes?limit=100", nil, tokens).StatusCode)
+}
+
+func (s *MemoryTestSuite) Test_Post_ActionLike_QuotaExceeded() {
+	_, tokens := s.register("base@mail.com")
+
+	// free plan allows 10 actions a day
+	for i := 0; i < 10; i++ {
+		res := s.do(http.MethodPost, "/v1/actions/likes", map[string]string{"id": uuid.NewString()}, tokens)
+		s.Equal(http.StatusOK, res.StatusCode)
+	}
+
+	res := s.do(http.MethodPost, "/v1/actions/passes", map[string]string{"id": uuid.NewString()}, tokens)
+	s.Equal(http.StatusBadRequest, res.StatusCode)
+	var response map[string]interface{}
+	s.decode(res, &response)
+	s.Equal("quota_exceeded", response["code"])
+	s.Equal("exceed max action allowed", response["error"])
+}
+
+func (s *MemoryTestSuite) Test_Get_Plans_Traced() {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	defer otel.SetTracerProvider(previous)
+	infra.NewTracerProvider("", "", false, "", 0)
+
+	res := newHttpTest().
+		withPath("/v1/plans").
+		withMethod(http.MethodGet).
+		withHeader("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01").
+		doWith(s.handler)
+	s.Equal(http.StatusOK, res.StatusCode)
+
+	spans := recorder.Ended()
+	s.Require().Len(spans, 1)
+	s.Equal("/v1/plans", spans[0].Name())
+	s.Equal("4bf92f3577b34da6a3ce929d0e0e4736", spans[0].SpanContext().TraceID().String())
+	s.Equal("00f067aa0ba902b7", spans[0].Parent().SpanID().String())
+}
+
+func (s *MemoryTestSuite) Test_NewHandler_IsolatedApps() {
+	// run with -race, apps built and served at the same time must not share any state of them.
+	// gin mode is global to the process, so it is set before like main does
+	setTestMode()
+	stores := []*memory.Store{memory.NewStore(), memory.NewStore()}
+	var wg sync.WaitGroup
+	for i, store := range stores {
+		wg.Add(1)
+		go func(i int, store *memory.Store) {
+			defer wg.Done()
+			handler := rest.NewHandler(newMemoryApp(store))
+
+			res := newHttpTest().
+				withPath("/v1/auth/register").
+				withMethod(http.MethodPost).
+				withHeader("Accept-Language", "id").
+				withBody(map[string]interface{}{"email": "weak@mail.com", "birth_of_date": time.Now().Unix()}).
+				doWith(handler)
+			s.Equal(http.StatusBadRequest, res.StatusCode)
+			res.Body.Close()
+
+			res = newHttpTest().
+				withPath("/v1/auth/register").
+				withMethod(http.MethodPost).
+				withBody(map[string]interface{}{
+					"email":         fmt.Sprintf("app.%d@mail.com", i),
+					"password":      "Secret1234!",
+					"birth_of_date": time.Now().Unix(),
+				}).
+				doWith(handler)
+			s.Equal(http.StatusOK, res.StatusCode)
+			res.Body.Close()
+		}(i, store)
+	}
+	wg.Wait()
+
+	// users are registered on the app they signed up on only
+	users := memory.NewUserRepository(stores[0])
+	_, err := users.FindByEmail(context.Background(), "app.0@mail.com")
+	s.Nil(err)
+	_, err = users.FindByEmail(context.Background(), "app.1@mail.com")
+	s.ErrorIs(err, user.ErrUserNotFound)
+}
+
+func (s *MemoryTestSuite) Test_Serve_CleanupsRunOnceInOrder() {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	s.Require().Nil(err)
+	s.app.Config.App.Rest.Port = listener.Addr().(*net.TCPAddr).Port
+	s.Require().Nil(listener.Close())
+
+	var calls []string
+	cleanup := func(name string) rest.CleanupFn {
+		return func() (string, func()) {
+			return name, func() { calls = append(calls, name) }
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- rest.Serve(ctx, s.app, cleanup("stop job runner"), cleanup("flush traces"), cleanup("close connections"))
+	}()
+
+	url := fmt.Sprintf("http://127.0.0.1:%d/healthz", s.app.Config.App.Rest.Port)
+	s.Eventually(func() bool {
+		res, err := http.Get(url)
+		if err != nil {
+			return false
+		}
+		res.Body.Close()
+		return res.StatusCode == http.StatusOK
+	}, 5*time.Second, 10*time.Millisecond)
+	cancel()
+
+	s.Nil(<-served)
+	s.Equal([]string{"stop job runner", "flush traces", "close connections"}, calls)
+}
+
+func (s *MemoryTestSuite) Test_RequestID_Logged() {
+	_, tokens := s.register("base@mail.com")
+
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(logging.New(&buf, config.AppConfiguration{}))
+	defer slog.SetDefault(previous)
+
+	res := newHttpTest().
+		withPath("/v1/actions/likes?limit=10&password=secret").
+		withMethod(http.MethodGet).
+		withHeader("Cookie", fmt.Sprintf("%s=%s", tokens[0][0], tokens[0][1])).
+		withHeader("X-Request-ID", "req-1").
+		doWith(s.handler)
+	s.Equal(http.StatusOK, res.StatusCode)
+	s.Equal("req-1", res.Header.Get("X-Request-ID"))
+
+	line := buf.String()
+	s.Contains(line, "request_id=req-1")
+	s.Contains(line, "user_id=")
+	s.Contains(line, "route=/v1/actions/likes")
+	s.Contains(line, "password=%5BREDACTED%5D")
+	s.NotContains(line, "secret")
+
+	// malformed id is replaced rather than logged
+	res = s.do(http.MethodGet, "/v1/plans", nil, nil)
+	s.NotEmpty(res.Header.Get("X-Request-ID"))
+	res = newHttpTest().
+		withPath("/v1/plans").
+		withMethod(http.MethodGet).
+		withHeader("X-Request-ID", "forged\nlevel=ERROR").
+		doWith(s.handler)
+	s.NotEqual("forged\nlevel=ERROR", res.Header.Get("X-Request-ID"))
+}
+
+func (s *MemoryTestSuite) Test_Get_HealthAndReadiness() {
+	res := s.do(http.MethodGet, "/healthz", nil, nil)
+	s.Equal(http.StatusOK, res.StatusCode)
+
+	// in-memory app has no postgresql nor redis to check
+	res = s.do(http.MethodGet, "/readyz", nil, nil)
+	s.Equal(http.StatusOK, res.StatusCode)
+	var response map[string]interface{}
+	s.decode(res, &response)
+	s.Equal("ready", response["status"])
+	s.Empty(response["checks"])
+}
+
+func (s *MemoryTestSuite) Test_Get_Plans_TimedOut() {
+	a := newMemoryApp(s.store)
+	a.Config.App.Rest.RequestTimeout = time.Nanosecond
+	handler := rest.NewHandler(a)
+
+	res := newHttpTest().
+		withPath("/v1/plans").
+		withMethod(http.MethodGet).
+		doWith(handler)
+	s.Equal(http.StatusGatewayTimeout, res.StatusCode)
+	var response map[string]interface{}
+	s.decode(res, &response)
+	s.Equal("timeout", response["code"])
+	s.Equal("request timed out", response["error"])
+}
+
+func (s *MemoryTestSuite) Test_Get_Metrics() {
+	_, tokens := s.register("base@mail.com")
+	for i := 0; i < 11; i++ {
+		s.do(http.MethodPost, "/v1/actions/likes", map[string]string{"id": uuid.NewString()}, tokens)
+	}
+	s.do(http.MethodGet, "/unknown", nil, nil)
+
+	res := s.do(http.MethodGet, "/metrics", nil, nil)
+	s.Equal(http.StatusOK, res.StatusCode)
+	body, err := io.ReadAll(res.Body)
+	s.NoError(err)
+
+	for _, line := range []string{
+		`gotinder_http_requests_total{method="POST",route="/v1/actions/likes",status="200"} 10`,
+		`gotinder_http_requests_total{method="POST",route="/v1/actions/likes",status="400"} 1`,
+		`gotinder_http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+		`gotinder_http_request_duration_seconds_count{method="POST",route="/v1/actions/likes"} 11`,
+		`gotinder_likes_total 10`,
+		`gotinder_quota_rejections_total 1`,
+		`gotinder_registrations_total 1`,
+	} {
+		s.Contains(string(body), line)
+	}
+}
+
+func (s *MemoryTestSuite) Test_Get_LikesReceived_Blurred() {
+	selfID, tokens := s.register("base@mail.com")
+	_, likerTokens := s.register("liker@mail.com")
+	s.Equal(http.StatusOK, s.do(http.MethodPost, "/v1/actions/likes", map[string]string{"id": selfID}, likerTokens).StatusCode)
+
+	res := s.do(http.MethodGet, "/v1/likes/received?limit=10", nil, tokens)
+	s.Equal(http.StatusOK, res.StatusCode)
+	var response struct {
+		Data []struct {
+			ID      *string `json:"id"`
+			Blurred bool    `json:"blurred"`
+		} `json:"data"`
+		Count int64 `json:"count"`
+	}
+	s.decode(res, &response)
+	s.EqualValues(1, response.Count)
+	s.Len(response.Data, 1)
+	s.True(response.Data[0].Blurred)
+	s.Nil(response.Data[0].ID)
+}
+
+func (s *MemoryTestSuite) Test_Post_UserSubscribe_AppliedCoupon() {
+	_, adminTokens := s.register("admin@mail.com")
+	s.Require().Nil(s.store.SetAdmin("admin@mail.com"))
+	subscriberID, tokens := s.register("sub@mail.com")
+
+	res := s.do(http.MethodPost, "/v1/coupons", map[string]interface{}{
+		"code":               "NEWUSER123",
+		"duration_in_second": 60 * 60 * 24 * 30,
+		"valid_until":        time.Now().Add(24 * time.Hour).Unix(),
+	}, adminTokens)
+	s.Equal(http.StatusOK, res.StatusCode)
+
+	res = s.do(http.MethodPost, "/v1/coupons/apply", map[string]interface{}{
+		"code":    "NEWUSER123",
+		"user_id": subscriberID,
+	}, adminTokens)
+	s.Equal(http.StatusOK, res.StatusCode)
+
+	s.Equal(http.StatusOK, s.do(http.MethodPost, "/v1/users/subscribe", map[string]string{"coupon_code": "NEWUSER123"}, tokens).StatusCode)
+	s.Equal(http.StatusNotFound, s.do(http.MethodPost, "/v1/users/subscribe", map[string]string{"coupon_code": "NEWUSER123"}, tokens).StatusCode)
+
+	res = s.do(http.MethodGet, "/v1/users/me/subscription", nil, tokens)
+	s.Equal(http.StatusOK, res.StatusCode)
+	var response struct {
+		Data struct {
+			Status string `json:"status"`
+			Plan   struct {
+				Tier string `json:"tier"`
+			} `json:"plan"`
+			History []struct {
+				ToStatus string `json:"to_status"`
+			} `json:"history"`
+		} `json:"data"`
+	}
+	s.decode(res, &response)
+	s.Equal("active", response.Data.Status)
+	s.Equal("premium", response.Data.Plan.Tier)
+	s.Len(response.Data.History, 1)
+}
+
+func (s *MemoryTestSuite) Test_Post_CouponApply_MaxRedemptionsReached() {
+	_, adminTokens := s.register("admin@mail.com")
+	s.Require().Nil(s.store.SetAdmin("admin@mail.com"))
+
+	res := s.do(http.MethodPost, "/v1/coupons", map[string]interface{}{
+		"code":               "LIMITED123",
+		"duration_in_second": 60 * 60 * 24,
+		"valid_until":        time.Now().Add(24 * time.Hour).Unix(),
+		"max_redemptions":    1,
+	}, adminTokens)
+	s.Equal(http.StatusOK, res.StatusCode)
+
+	for i, email := range []string{"sub.1@mail.com", "sub.2@mail.com"} {
+		subscriberID, _ := s.register(email)
+		res := s.do(http.MethodPost, "/v1/coupons/apply", map[string]interface{}{
+			"code":    "LIMITED123",
+			"user_id": subscriberID,
+		}, adminTokens)
+
+		if i == 0 {
+			s.Equal(http.StatusOK, res.StatusCode)
+			continue
+		}
+		s.Equal(http.StatusBadRequest, res.StatusCode)
+		var response map[string]interface{}
+		s.decode(res, &response)
+		s.Equal("coupon redemption limit reached", response["error"])
+	}
+}
+
+func (s *MemoryTestSuite) Test_Coupons_RequireAdmin() {
+	_, tokens := s.register("base@mail.com")
+
+	s.Equal(http.StatusForbidden, s.do(http.MethodGet, "/v1/coupons", nil, tokens).StatusCode)
+
+	// otherwise anyone could create a public coupon and redeem it for themselves
+	res := s.do(http.MethodPost, "/v1/coupons", map[string]interface{}{
+		"code":               "FREEPREMIUM1",
+		"duration_in_second": 60 * 60 * 24 * 365 * 100,
+		"valid_until":        time.Now().Add(24 * time.Hour).Unix(),
+		"plan_tier":          "premium",
+		"is_public":          true,
+	}, tokens)
+	s.Equal(http.StatusForbidden, res.StatusCode)
+	s.Equal(http.StatusNotFound, s.do(http.MethodPost, "/v1/coupons/redeem", map[string]string{"code": "FREEPREMIUM1"}, tokens).StatusCode)
+}
+
+func (s *MemoryTestSuite) Test_CouponCampaign_GenerateAndStats() {
+	_, adminTokens := s.register("admin@mail.com")
+	s.Require().Nil(s.store.SetAdmin("admin@mail.com"))
+
+	res := s.do(http.MethodPost, "/v1/coupons/campaigns", map[string]interface{}{
+		"campaign":           "launch",
+		"count":              5,
+		"duration_in_second": 60 * 60 * 24,
+		"valid_until":        time.Now().Add(24 * time.Hour).Unix(),
+	}, adminTokens)
+	s.Equal(http.StatusOK, res.StatusCode)
+
+	res = s.do(http.MethodGet, "/v1/coupons/campaigns/launch/stats", nil, adminTokens)
+	s.Equal(http.StatusOK, res.StatusCode)
+	var response struct {
+		Data struct {
+			TotalCodes int64 `json:"total_codes"`
+		} `json:"data"`
+	}
+	s.decode(res, &response)
+	s.EqualValues(5, response.Data.TotalCodes)
+
+	s.Equal(http.StatusNotFound, s.do(http.MethodGet, "/v1/coupons/campaigns/unknown/stats", nil, adminTokens).StatusCode)
+}
+
+func (s *MemoryTestSuite) Test_CouponCampaign_Export() {
+	_, adminTokens := s.register("admin@mail.com")
+	s.Require().Nil(s.store.SetAdmin("admin@mail.com"))
+
+	res := s.do(http.MethodPost, "/v1/coupons/campaigns", map[string]interface{}{
+		"campaign":           `new "year"`,
+		"count":              2,
+		"duration_in_second": 60 * 60 * 24,
+		"valid_until":        time.Now().Add(24 * time.Hour).Unix(),
+	}, adminTokens)
+	s.Equal(http.StatusOK, res.StatusCode)
+
+	res = s.do(http.MethodGet, "/v1/coupons/campaigns/new%20%22year%22/export", nil, adminTokens)
+	s.Equal(http.StatusOK, res.StatusCode)
+	s.Equal("text/csv", res.Header.Get("Content-Type"))
+	_, params, err := mime.ParseMediaType(res.Header.Get("Content-Disposition"))
+	s.Require().Nil(err)
+	s.Equal(`new "year".csv`, params["filename"])
+	records, err := csv.NewReader(res.Body).ReadAll()
+	s.Require().Nil(err)
+	s.Len(records, 3)
+
+	res = s.do(http.MethodGet, "/v1/coupons/campaigns/unknown/export", nil, adminTokens)
+	s.Equal(http.StatusNotFound, res.StatusCode)
+	s.Empty(res.Header.Get("Content-Disposition"))
+	var response map[string]interface{}
+	s.decode(res, &response)
+	s.Equal("coupon_campaign_not_found", response["code"])
+}
+
+func (s *MemoryTestSuite) Test_CouponCampaign_ExportGeneratedOnly() {
+	ctx := context.Background()
+	coupons := s.app.Services.Coupons
+	batch := coupon.Batch{Campaign: "launch", Count: 3, DurationInSecond: 60 * 60, ValidUntil: time.Now().Add(time.Hour).Unix()}
+	_, err := coupons.Generate(ctx, batch)
+	s.Require().Nil(err)
+	batch.Count = 2
+	codes, err := coupons.Generate(ctx, batch)
+	s.Require().Nil(err)
+
+	var generated bytes.Buffer
+	s.Require().Nil(coupons.ExportCampaign(ctx, &generated, "launch", codes...))
+	records, err := csv.NewReader(&generated).ReadAll()
+	s.Require().Nil(err)
+	s.Len(records, 3)
+	s.ElementsMatch(codes, []string{records[1][0], records[2][0]})
+
+	var all bytes.Buffer
+	s.Require().Nil(coupons.ExportCampaign(ctx, &all, "launch"))
+	records, err = csv.NewReader(&all).ReadAll()
+	s.Require().Nil(err)
+	s.Len(records, 6)
+
+	batch.Count = 0
+	_, err = coupons.Generate(ctx, batch)
+	s.ErrorIs(err, coupon.ErrInvalidBatch)
+}
+
+func (s *MemoryTestSuite) Test_Get_Recommendations_NearbyNotActedOn() {
+	_, tokens := s.register("base@mail.com")
+	nearID, nearTokens := s.register("near@mail.com")
+	_, farTokens := s.register("far@mail.com")
+
+	s.Equal(http.StatusNotFound, s.do(http.MethodGet, "/v1/recommendations?limit=10", nil, tokens).StatusCode)
+
+	// Jakarta, Bogor is around 50km away and Surabaya is way over 150km
+	s.Equal(http.StatusOK, s.do(http.MethodPost, "/v1/locations", map[string]string{"lat": "-6.2088", "lng": "106.8456"}, tokens).StatusCode)
+	s.Equal(http.StatusOK, s.do(http.MethodPost, "/v1/locations", map[string]string{"lat": "-6.5971", "lng": "106.8060"}, nearTokens).StatusCode)
+	s.Equal(http.StatusOK, s.do(http.MethodPost, "/v1/locations", map[string]string{"lat": "-7.2575", "lng": "112.7521"}, farTokens).StatusCode)
+
+	var response struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	res := s.do(http.MethodGet, "/v1/recommendations?limit=10", nil, tokens)
+	s.Equal(http.StatusOK, res.StatusCode)
+	s.decode(res, &response)
+	s.Len(response.Data, 1)
+	s.Equal(nearID, response.Data[0].ID)
+
+	s.Equal(http.StatusOK, s.do(http.MethodPost, "/v1/actions/passes", map[string]string{"id": nearID}, tokens).StatusCode)
+
+	res = s.do(http.MethodGet, "/v1/recommendations?limit=10", nil, tokens)
+	s.Equal(http.StatusOK, res.StatusCode)
+	s.decode(res, &response)
+	s.Len(response.Data, 0)
+}
+
+func (s *MemoryTestSuite) Test_Delete_Location_HiddenFromNearby() {
+	_, tokens := s.register("base@mail.com")
+	_, nearTokens := s.register("near@mail.com")
+
+	s.Equal(http.StatusOK, s.do(http.MethodPost, "/v1/locations", map[string]string{"lat": "-6.2088", "lng": "106.8456"}, tokens).StatusCode)
+	s.Equal(http.StatusOK, s.do(http.MethodPost, "/v1/locations", map[string]string{"lat": "-6.5971", "lng": "106.8060"}, nearTokens).StatusCode)
+
+	var response struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	res := s.do(http.MethodGet, "/v1/recommendations?limit=10", nil, tokens)
+	s.Equal(http.StatusOK, res.StatusCode)
+	s.decode(res, &response)
+	s.Len(response.Data, 1)
+
+	s.Equal(http.StatusOK, s.do(http.MethodDelete, "/v1/locations", nil, nearTokens).StatusCode)
+
+	res = s.do(http.MethodGet, "/v1/recommendations?limit=10", nil, tokens)
+	s.Equal(http.StatusOK, res.StatusCode)
+	s.decode(res, &response)
+	s.Len(response.Data, 0)
+
+	// resuming from the same spot is not throttled
+	s.Equal(http.StatusOK, s.do(http.MethodPost, "/v1/locations", map[string]string{"lat": "-6.5971", "lng": "106.8060"}, nearTokens).StatusCode)
+
+	res = s.do(http.MethodGet, "/v1/recommendations?limit=10", nil, tokens)
+	s.Equal(http.StatusOK, res.StatusCode)
+	s.decode(res, &response)
+	s.Len(response.Data, 1)
+}
+
+func (s *MemoryTestSuite) Test_Passport_SetFindRemove() {
+	_, tokens := s.register("base@mail.com")
+
+	// passport is a premium feature
+	res := newHttpTest().
+		withPath("/v1/locations/passport").
+		withMethod(http.MethodPut).
+		withHeader("Accept-Language", "id").
+		withHeader("Cookie", fmt.Sprintf("%s=%s", tokens[0][0], tokens[0][1])).
+		withHeader("Cookie", fmt.Sprintf("%s=%s", tokens[1][0], tokens[1][1])).
+		withBody(map[string]string{"city": "Jakarta"}).
+		doWith(s.handler)
+	s.Equal(http.StatusForbidden, res.StatusCode)
+	var errResponse map[string]interface{}
+	s.decode(res, &errResponse)
+	s.Equal("passport_required", errResponse["code"])
+	s.Equal("passport tidak tersedia pada paket anda", errResponse["error"])
+	s.redeemPremium(tokens)
+
+	s.Equal(http.StatusOK, s.do(http.MethodPut, "/v1/locations/passport", map[string]string{"city": "jakarta"}, tokens).StatusCode)
+	s.Equal(http.StatusNotFound, s.do(http.MethodPut, "/v1/locations/passport", map[string]string{"city": "Atlantis"}, tokens).StatusCode)
+
+	res = s.do(http.MethodGet, "/v1/locations/passport", nil, tokens)
+	s.Equal(http.StatusOK, res.StatusCode)
+	var response struct {
+		Data struct {
+			Passport struct {
+				City *struct {
+					Name string `json:"name"`
+				} `json:"city"`
+			} `json:"passport"`
+			Active bool `json:"active"`
+		} `json:"data"`
+	}
+	s.decode(res, &response)
+	s.True(response.Data.Active)
+	s.Require().NotNil(response.Data.Passport.City)
+	s.Equal("Jakarta", response.Data.Passport.City.Name)
+
+	s.Equal(http.StatusOK, s.do(http.MethodDelete, "/v1/locations/passport", nil, tokens).StatusCode)
+	s.Equal(http.StatusNotFound, s.do(http.MethodGet, "/v1/locations/passport", nil, tokens).StatusCode)
+}
+
+// redeemPremium put the user of the tokens on premium through public coupon
+func (s *MemoryTestSuite) redeemPremium(tokens [][]string) {
+	_, adminTokens := s.register("premium.admin@mail.com")
+	s.Require().Nil(s.store.SetAdmin("premium.admin@mail.com"))
+
+	res := s.do(http.MethodPost, "/v1/coupons", map[string]interface{}{
+		"code":               "PREMIUM123",
+		"duration_in_second": 60 * 60 * 24,
+		"valid_until":        time.Now().Add(24 * time.Hour).Unix(),
+		"is_public":          true,
+	}, adminTokens)
+	s.Require().Equal(http.StatusOK, res.StatusCode)
+	s.Require().Equal(http.StatusOK, s.do(http.MethodPost, "/v1/coupons/redeem", map[string]string{"code": "PREMIUM123"}, tokens).StatusCode)
+}
+
+func (s *MemoryTestSuite) Test_Get_Plans_Success() {
+	res := s.do(http.MethodGet, "/v1/plans", nil, nil)
+	s.Equal(http.StatusOK, res.StatusCode)
+	var response struct {
+		Data []struct {
+			Tier string `json:"tier"`
+		} `json:"data"`
+	}
+	s.decode(res, &response)
+	s.Len(response.Data, 2)
+	s.Equal("free", response.Data[0].Tier)
+	s.Equal("premium", response.Data[1].Tier)
+}
+
+func (s *MemoryTestSuite) Test_Payment_CheckoutActivated() {
+	_, tokens := s.register("base@mail.com")
+
+	res := s.do(http.MethodPost, "/v1/payments/checkout", map[string]string{"plan_tier": "premium"}, tokens)
+	s.Equal(http.StatusOK, res.StatusCode)
+	var checkout struct {
+		Data struct {
+			SessionID string `json:"session_id"`
+		} `json:"data"`
+	}
+	s.decode(res, &checkout)
+
+	fake := s.app.Payment.(*infra.FakePaymentProvider)
+	event := fake.NewEvent(infra.PaymentEventActivated, checkout.Data.SessionID, time.Now().Add(30*24*time.Hour).Unix())
+	payload, header, err := fake.SignEvent(event)
+	s.Require().Nil(err)
+	for i := 0; i < 2; i++ {
+		req := newHttpTest().
+			withPath("/v1/payments/webhook").
+			withMethod(http.MethodPost).
+			withRawBody(payload)
+		req.header = header
+		s.Equal(http.StatusOK, req.doWith(s.handler).StatusCode)
+	}
+
+	res = s.do(http.MethodGet, "/v1/users/me/subscription", nil, tokens)
+	s.Equal(http.StatusOK, res.StatusCode)
+	var response struct {
+		Data struct {
+			Plan struct {
+				Tier string `json:"tier"`
+			} `json:"plan"`
+			History []struct{} `json:"history"`
+		} `json:"data"`
+	}
+	s.decode(res, &response)
+	s.Equal("premium", response.Data.Plan.Tier)
+	// replayed event is recorded once
+	s.Len(response.Data.History, 1)
+}
+
+func (s *MemoryTestSuite) Test_Payment_CancelledKeepsCouponTime() {
+	_, adminTokens := s.register("admin@mail.com")
+	s.Require().Nil(s.store.SetAdmin("admin@mail.com"))
+	_, tokens := s.register("base@mail.com")
+
+	res := s.do(http.MethodPost, "/v1/payments/checkout", map[string]string{"plan_tier": "premium"}, tokens)
+	s.Equal(http.StatusOK, res.StatusCode)
+	var checkout struct {
+		Data struct {
+			SessionID string `json:"session_id"`
+		} `json:"data"`
+	}
+	s.decode(res, &checkout)
+	fake := s.app.Payment.(*infra.FakePaymentProvider)
+	sendWebhook := func(event infra.PaymentEvent) {
+		payload, header, err := fake.SignEvent(event)
+		s.Require().Nil(err)
+		req := newHttpTest().
+			withPath("/v1/payments/webhook").
+			withMethod(http.MethodPost).
+			withRawBody(payload)
+		req.header = header
+		s.Equal(http.StatusOK, req.doWith(s.handler).StatusCode)
+	}
+	sendWebhook(fake.NewEvent(infra.PaymentEventActivated, checkout.Data.SessionID, time.Now().Add(30*24*time.Hour).Unix()))
+
+	couponDuration := 7 * 24 * time.Hour
+	res = s.do(http.MethodPost, "/v1/coupons", map[string]interface{}{
+		"code":               "CAMPAIGN123",
+		"duration_in_second": int64(couponDuration / time.Second),
+		"valid_until":        time.Now().Add(24 * time.Hour).Unix(),
+		"is_public":          true,
+	}, adminTokens)
+	s.Equal(http.StatusOK, res.StatusCode)
+	s.Equal(http.StatusOK, s.do(http.MethodPost, "/v1/coupons/redeem", map[string]string{"code": "CAMPAIGN123"}, tokens).StatusCode)
+
+	// only paid time is revoked, time granted by the coupon keeps running
+	sendWebhook(fake.NewEvent(infra.PaymentEventCancelled, checkout.Data.SessionID, 0))
+
+	res = s.do(http.MethodGet, "/v1/users/me/subscription", nil, tokens)
+	s.Equal(http.StatusOK, res.StatusCode)
+	var response struct {
+		Data struct {
+			Status string `json:"status"`
+			EndsAt int64  `json:"ends_at"`
+		} `json:"data"`
+	}
+	s.decode(res, &response)
+	s.Equal("active", response.Data.Status)
+	s.InDelta(time.Now().Add(couponDuration).Unix(), response.Data.EndsAt, 2)
+}
+
+func (s *MemoryTestSuite) Test_Payment_WebhookRejected() {
+	_, tokens := s.register("base@mail.com")
+	res := s.do(http.MethodPost, "/v1/payments/checkout", map[string]string{"plan_tier": "premium"}, tokens)
+	s.Equal(http.StatusOK, res.StatusCode)
+	var checkout struct {
+		Data struct {
+			SessionID string `json:"session_id"`
+		} `json:"data"`
+	}
+	s.decode(res, &checkout)
+	fake := s.app.Payment.(*infra.FakePaymentProvider)
+
+	for _, periodEnd := range []int64{0, time.Now().Add(-time.Hour).Unix()} {
+		payload, header, err := fake.SignEvent(fake.NewEvent(infra.PaymentEventRenewed, checkout.Data.SessionID, periodEnd))
+		s.Require().Nil(err)
+		req := newHttpTest().
+			withPath("/v1/payments/webhook").
+			withMethod(http.MethodPost).
+			withRawBody(payload)
+		req.header = header
+		res := req.doWith(s.handler)
+		s.Equal(http.StatusBadRequest, res.StatusCode)
+		var response map[string]interface{}
+		s.decode(res, &response)
+		s.Equal("period_ended", response["code"])
+	}
+
+	// body is bounded before its signature is verified
+	res = newHttpTest().
+		withPath("/v1/payments/webhook").
+		withMethod(http.MethodPost).
+		withRawBody(bytes.Repeat([]byte("a"), 1<<20)).
+		doWith(s.handler)
+	s.Equal(http.StatusBadRequest, res.StatusCode)
+}
@@ -0,0 +1,10 @@
+package rest
+
+type (
+	// cursorQueryParam is a type of common cursor pagination query param,
+	// Cursor is decoded by pagination.Decode
+	cursorQueryParam struct {
+		Limit  int    `form:"limit" validate:"required,gte=1,lte=100"`
+		Cursor string `form:"cursor"`
+	}
+)
@@ -0,0 +1,123 @@
+package rest
+
+import (
+	"gotinder/apperr"
+	"gotinder/geo"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-pkgz/auth/token"
+)
+
+const (
+	featurePassport = "passport"
+
+	defaultCitiesLimit = 10
+)
+
+type (
+	// passportRequest is a type of "/locations/passport" request body,
+	// virtual location is either a city or a coordinate
+	passportRequest struct {
+		City        string `json:"city" validate:"required_without=Lat,omitempty,max=255"`
+		CountryCode string `json:"country_code" validate:"omitempty,iso3166_1_alpha2"`
+		Lat         string `json:"lat" validate:"required_with=Lng,omitempty,latitude"`
+		Lng         string `json:"lng" validate:"required_with=Lat,omitempty,longitude"`
+	}
+
+	// findCitiesQueryParam is a type of "/locations/cities" query param
+	findCitiesQueryParam struct {
+		Query string `form:"q" validate:"required,min=2"`
+		Limit int    `form:"limit" validate:"omitempty,gte=1,lte=50"`
+	}
+)
+
+// findCities search cities by name prefix, most populated first
+func (v v1) findCities(ctx *gin.Context) {
+	var param findCitiesQueryParam
+	if err := ctx.ShouldBind(&param); err != nil {
+		abortWithErr(ctx, apperr.InvalidRequest(err))
+		return
+	}
+	if param.Limit == 0 {
+		param.Limit = defaultCitiesLimit
+	}
+
+	cities, err := v.Locations.Cities(ctx.Request.Context(), param.Query, param.Limit)
+	if err != nil {
+		abortWithErr(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"data": cities,
+	})
+}
+
+// findPassport give virtual location of the actor
+func (v v1) findPassport(ctx *gin.Context) {
+	user := token.MustGetUserInfo(ctx.Request)
+
+	passport, err := v.Locations.Passport(ctx.Request.Context(), user.StrAttr("user_id"))
+	if err != nil {
+		abortWithErr(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"passport": passport,
+			"active":   hasFeature(user, featurePassport),
+		},
+	})
+}
+
+// setPassport put the actor on virtual location used for their recommendations,
+// their real location keeps being recorded
+func (v v1) setPassport(ctx *gin.Context) {
+	user := token.MustGetUserInfo(ctx.Request)
+	if !hasFeature(user, featurePassport) {
+		abortWithErr(ctx, errPassportRequired)
+		return
+	}
+
+	var req passportRequest
+	if err := ctx.ShouldBind(&req); err != nil {
+		abortWithErr(ctx, apperr.InvalidRequest(err))
+		return
+	}
+
+	var point *geo.Point
+	if req.Lat != "" {
+		p, err := geo.ParsePoint(req.Lat, req.Lng)
+		if err != nil {
+			abortWithErr(ctx, err)
+			return
+		}
+		point = &p
+	}
+
+	passport, err := v.Locations.SetPassport(ctx.Request.Context(), user.StrAttr("user_id"), point, req.City, req.CountryCode)
+	if err != nil {
+		abortWithErr(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"data": passport,
+	})
+}
+
+// removePassport put the actor back on their real location
+func (v v1) removePassport(ctx *gin.Context) {
+	user := token.MustGetUserInfo(ctx.Request)
+
+	if err := v.Locations.RemovePassport(ctx.Request.Context(), user.StrAttr("user_id")); err != nil {
+		abortWithErr(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"message": "success remove passport",
+	})
+}
@@ -0,0 +1,89 @@
+package rest
+
+import (
+	"gotinder/apperr"
+	"gotinder/infra"
+	"gotinder/payment"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-pkgz/auth/token"
+	"github.com/pkg/errors"
+)
+
+// maxWebhookPayloadSize bound webhook body read before its signature is verified
+const maxWebhookPayloadSize = 64 << 10
+
+type (
+	// checkoutRequest is a type of "/payments/checkout" request body
+	checkoutRequest struct {
+		PlanTier string `json:"plan_tier" validate:"required"`
+	}
+)
+
+// RegisterPayment register payment handler
+func (v v1) RegisterPayment() {
+	authMiddleware := v.auth.service.Middleware()
+
+	paymentGroup := v.group.Group("/payments")
+	paymentGroup.POST("/checkout", asGin(authMiddleware.Auth), v.enrichActor, v.checkout)
+	paymentGroup.POST("/webhook", v.paymentWebhook)
+}
+
+// checkout start payment of subscription plan for the actor
+func (v v1) checkout(ctx *gin.Context) {
+	var req checkoutRequest
+	if err := ctx.ShouldBind(&req); err != nil {
+		abortWithErr(ctx, apperr.InvalidRequest(err))
+		return
+	}
+
+	user := token.MustGetUserInfo(ctx.Request)
+	session, err := v.Payments.Checkout(ctx.Request.Context(), user.StrAttr("user_id"), req.PlanTier)
+	if err != nil {
+		abortWithErr(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"session_id": session.ID,
+			"url":        session.URL,
+		},
+	})
+}
+
+// paymentWebhook receive signed subscription event from payment provider,
+// redelivered event is acknowledged without being applied twice
+func (v v1) paymentWebhook(ctx *gin.Context) {
+	payload, err := io.ReadAll(http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxWebhookPayloadSize))
+	if err != nil {
+		abortWithErr(ctx, apperr.InvalidRequest(err))
+		return
+	}
+
+	event, err := v.Payments.ParseWebhook(payload, ctx.Request.Header)
+	if err != nil {
+		if !errors.Is(err, infra.ErrInvalidSignature) {
+			err = apperr.InvalidRequest(err)
+		}
+		abortWithErr(ctx, err)
+		return
+	}
+
+	if err := v.Payments.Apply(ctx.Request.Context(), event); err != nil {
+		if errors.Is(err, payment.ErrEventIgnored) || errors.Is(err, payment.ErrEventProcessed) {
+			ctx.JSON(http.StatusOK, gin.H{
+				"message": err.Error(),
+			})
+			return
+		}
+		abortWithErr(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"message": "success process event",
+	})
+}
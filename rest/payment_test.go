@@ -0,0 +1,190 @@
+//go:build integration
+
+package rest_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"gotinder/infra"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/stretchr/testify/suite"
+)
+
+type PaymentTestSuite struct {
+	suite.Suite
+}
+
+func TestPaymentTestSuite(t *testing.T) {
+	suite.Run(t, new(PaymentTestSuite))
+}
+
+func (s *PaymentTestSuite) SetupSuite() {
+	newPostgresTest(s.T())
+}
+
+func (s *PaymentTestSuite) SetupTest() {
+	pgTest.migrate(s.T(), pgTest.conn)
+}
+
+func (s *PaymentTestSuite) checkout(tokens [][]string) string {
+	res := newHttpTest().
+		withPath("/v1/payments/checkout").
+		withMethod(http.MethodPost).
+		withBody(map[string]interface{}{
+			"plan_tier": "premium",
+		}).
+		withHeader("Cookie", fmt.Sprintf("%s=%s", tokens[0][0], tokens[0][1])).
+		withHeader("Cookie", fmt.Sprintf("%s=%s", tokens[1][0], tokens[1][1])).
+		do()
+
+	s.Equal(http.StatusOK, res.StatusCode)
+	body, err := io.ReadAll(res.Body)
+	s.Nil(err)
+	defer res.Body.Close()
+	var response struct {
+		Data struct {
+			SessionID string `json:"session_id"`
+			URL       string `json:"url"`
+		} `json:"data"`
+	}
+	s.Nil(json.Unmarshal(body, &response))
+	s.NotEmpty(response.Data.URL)
+	return response.Data.SessionID
+}
+
+func (s *PaymentTestSuite) sendWebhook(event infra.PaymentEvent) *http.Response {
+	payload, header, err := infra.NewFakePaymentProvider("test_webhook_secret").SignEvent(event)
+	s.Nil(err)
+	req := newHttpTest().
+		withPath("/v1/payments/webhook").
+		withMethod(http.MethodPost).
+		withRawBody(payload)
+	req.header = header
+	return req.do()
+}
+
+func (s *PaymentTestSuite) Test_Post_PaymentCheckout_FreePlan() {
+	tokens := getAuthToken(s.T(), pgTest.conn)
+
+	res := newHttpTest().
+		withPath("/v1/payments/checkout").
+		withMethod(http.MethodPost).
+		withBody(map[string]interface{}{
+			"plan_tier": "free",
+		}).
+		withHeader("Cookie", fmt.Sprintf("%s=%s", tokens[0][0], tokens[0][1])).
+		withHeader("Cookie", fmt.Sprintf("%s=%s", tokens[1][0], tokens[1][1])).
+		do()
+
+	s.Equal(http.StatusBadRequest, res.StatusCode)
+}
+
+func (s *PaymentTestSuite) Test_Post_PaymentCheckout_LinksPendingSession() {
+	tokens := getAuthToken(s.T(), pgTest.conn)
+	sessionID := s.checkout(tokens)
+
+	row := sq.
+		StatementBuilder.
+		PlaceholderFormat(sq.Dollar).
+		Select("COUNT(id)", "COUNT(provider_session_id)").
+		From("payment_sessions").
+		RunWith(pgTest.conn).
+		QueryRow()
+	var count, linked int
+	s.Nil(row.Scan(&count, &linked))
+	s.Equal(1, count)
+	s.Equal(1, linked)
+
+	row = sq.
+		StatementBuilder.
+		PlaceholderFormat(sq.Dollar).
+		Select("COUNT(id)").
+		From("payment_sessions").
+		Where("provider_session_id = ?", sessionID).
+		RunWith(pgTest.conn).
+		QueryRow()
+	s.Nil(row.Scan(&count))
+	s.Equal(1, count)
+}
+
+func (s *PaymentTestSuite) Test_Post_PaymentWebhook_Activated_Idempotent() {
+	tokens := getAuthToken(s.T(), pgTest.conn)
+	sessionID := s.checkout(tokens)
+	fake := infra.NewFakePaymentProvider("test_webhook_secret")
+	periodEnd := time.Now().Add(30 * 24 * time.Hour).Unix()
+	event := fake.NewEvent(infra.PaymentEventActivated, sessionID, periodEnd)
+
+	res := s.sendWebhook(event)
+	s.Equal(http.StatusOK, res.StatusCode)
+	res = s.sendWebhook(event)
+	s.Equal(http.StatusOK, res.StatusCode)
+	body, err := io.ReadAll(res.Body)
+	s.Nil(err)
+	defer res.Body.Close()
+	var response map[string]interface{}
+	s.Nil(json.Unmarshal(body, &response))
+	s.Equal("event already processed", response["message"])
+
+	row := sq.
+		StatementBuilder.
+		PlaceholderFormat(sq.Dollar).
+		Select("users.subscribe_until", "subscriptions.status", "subscriptions.ends_at", "COUNT(subscription_histories.id)").
+		From("users").
+		InnerJoin("subscriptions ON subscriptions.user_id = users.id").
+		InnerJoin("subscription_histories ON subscription_histories.subscription_id = subscriptions.id").
+		Where("users.email = ?", "base@mail.com").
+		GroupBy("users.subscribe_until", "subscriptions.status", "subscriptions.ends_at").
+		RunWith(pgTest.conn).
+		QueryRow()
+	var subscribeUntil, endsAt int64
+	var status string
+	var historyCount int
+	s.Nil(row.Scan(&subscribeUntil, &status, &endsAt, &historyCount))
+	s.Equal(periodEnd, subscribeUntil)
+	s.Equal(periodEnd, endsAt)
+	s.Equal("active", status)
+	s.Equal(1, historyCount)
+}
+
+func (s *PaymentTestSuite) Test_Post_PaymentWebhook_Cancelled() {
+	tokens := getAuthToken(s.T(), pgTest.conn)
+	sessionID := s.checkout(tokens)
+	fake := infra.NewFakePaymentProvider("test_webhook_secret")
+
+	res := s.sendWebhook(fake.NewEvent(infra.PaymentEventActivated, sessionID, time.Now().Add(time.Hour).Unix()))
+	s.Equal(http.StatusOK, res.StatusCode)
+	res = s.sendWebhook(fake.NewEvent(infra.PaymentEventCancelled, sessionID, 0))
+	s.Equal(http.StatusOK, res.StatusCode)
+
+	row := sq.
+		StatementBuilder.
+		PlaceholderFormat(sq.Dollar).
+		Select("subscriptions.status").
+		From("subscriptions").
+		InnerJoin("users ON subscriptions.user_id = users.id").
+		Where("users.email = ?", "base@mail.com").
+		RunWith(pgTest.conn).
+		QueryRow()
+	var status string
+	s.Nil(row.Scan(&status))
+	s.Equal("cancelled", status)
+}
+
+func (s *PaymentTestSuite) Test_Post_PaymentWebhook_InvalidSignature() {
+	payload, err := json.Marshal(infra.PaymentEvent{ID: "evt", Type: infra.PaymentEventActivated})
+	s.Nil(err)
+
+	res := newHttpTest().
+		withPath("/v1/payments/webhook").
+		withMethod(http.MethodPost).
+		withRawBody(payload).
+		withHeader("X-Fake-Signature", "deadbeef").
+		do()
+
+	s.Equal(http.StatusUnauthorized, res.StatusCode)
+}
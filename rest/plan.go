@@ -0,0 +1,65 @@
+package rest
+
+import (
+	"gotinder/subscription"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-pkgz/auth/token"
+)
+
+const (
+	featureUnlimitedActions = "unlimited_actions"
+	featureSeeLikes         = "see_likes"
+
+	quotaDailyActions = "daily_actions"
+
+	attrPlanTier     = "plan_tier"
+	attrPlanFeatures = "plan_features"
+	attrQuotaPrefix  = "quota_"
+)
+
+// RegisterPlan register plan handler
+func (v v1) RegisterPlan() {
+	planGroup := v.group.Group("/plans")
+	planGroup.GET("", v.findPlans)
+}
+
+// findPlans give list of available subscription plans
+func (v v1) findPlans(ctx *gin.Context) {
+	plans, err := v.Subscriptions.Plans(ctx.Request.Context())
+	if err != nil {
+		abortWithErr(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"data": plans,
+	})
+}
+
+// setPlanAttrs put plan entitlements on the user token attributes
+func setPlanAttrs(user *token.User, tier string, features []string, quotas map[string]int) {
+	user.SetStrAttr(attrPlanTier, tier)
+	user.SetSliceAttr(attrPlanFeatures, features)
+	for quota, limit := range quotas {
+		user.SetStrAttr(attrQuotaPrefix+quota, strconv.Itoa(limit))
+	}
+	user.SetPaidSub(tier != subscription.PlanFree)
+}
+
+// hasFeature check if user's plan is entitled to the feature
+func hasFeature(user token.User, feature string) bool {
+	return slices.Contains(user.SliceAttr(attrPlanFeatures), feature)
+}
+
+// planQuota give limit of the quota on user's plan, fallback is used when plan has no such quota
+func planQuota(user token.User, quota string, fallback int) int {
+	limit, err := strconv.Atoi(user.StrAttr(attrQuotaPrefix + quota))
+	if err != nil {
+		return fallback
+	}
+	return limit
+}
@@ -0,0 +1,54 @@
+//go:build integration
+
+package rest_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type PlanTestSuite struct {
+	suite.Suite
+}
+
+func TestPlanTestSuite(t *testing.T) {
+	suite.Run(t, new(PlanTestSuite))
+}
+
+func (s *PlanTestSuite) SetupSuite() {
+	newPostgresTest(s.T())
+}
+
+func (s *PlanTestSuite) SetupTest() {
+	pgTest.migrate(s.T(), pgTest.conn)
+}
+
+func (s *PlanTestSuite) Test_Get_Plans_Success() {
+	res := newHttpTest().
+		withPath("/v1/plans").
+		do()
+
+	s.Equal(http.StatusOK, res.StatusCode)
+	body, err := io.ReadAll(res.Body)
+	s.Nil(err)
+	defer res.Body.Close()
+	var response struct {
+		Data []struct {
+			Tier     string         `json:"tier"`
+			Features []string       `json:"features"`
+			Quotas   map[string]int `json:"quotas"`
+		} `json:"data"`
+	}
+	s.Nil(json.Unmarshal(body, &response))
+	s.Len(response.Data, 2)
+	s.Equal("free", response.Data[0].Tier)
+	s.Equal(10, response.Data[0].Quotas["daily_actions"])
+	s.Equal("premium", response.Data[1].Tier)
+	s.Contains(response.Data[1].Features, "unlimited_actions")
+	s.Contains(response.Data[1].Features, "see_likes")
+	s.Contains(response.Data[1].Features, "passport")
+}
@@ -1,15 +1,21 @@
+//go:build integration
+
 package rest_test
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"gotinder/infra"
+	"gotinder/config"
+	"gotinder/geo"
+	"gotinder/rest"
 	"io"
 	"net/http"
 	"testing"
 	"time"
 
 	sq "github.com/Masterminds/squirrel"
+	"github.com/gomodule/redigo/redis"
 	"github.com/stretchr/testify/suite"
 	"golang.org/x/crypto/bcrypt"
 )
@@ -23,16 +29,15 @@ func TestRecommendationTestSuite(t *testing.T) {
 }
 
 func (s *RecommendationTestSuite) SetupSuite() {
-	pg := newPostgresTest(s.T())
-	infra.NewPgConnection(pg.connStr)
+	newPostgresTest(s.T())
 }
 
 func (s *RecommendationTestSuite) SetupTest() {
-	pgTest.migrate(s.T(), infra.PgConn)
+	pgTest.migrate(s.T(), pgTest.conn)
 }
 
 func (s *RecommendationTestSuite) Test_Get_Recommendation_Success() {
-	tokens := getAuthToken(s.T(), infra.PgConn)
+	tokens := getAuthToken(s.T(), pgTest.conn)
 
 	password := "Secret1234!"
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
@@ -47,7 +52,7 @@ func (s *RecommendationTestSuite) Test_Get_Recommendation_Success() {
 		Values("malang.2@mail.com", string(hashedPassword), time.Now().Unix()).
 		Values("malang.3@mail.com", string(hashedPassword), time.Now().Unix()).
 		Suffix("RETURNING id").
-		RunWith(infra.PgConn).
+		RunWith(pgTest.conn).
 		Query()
 	s.Nil(err)
 
@@ -64,7 +69,7 @@ func (s *RecommendationTestSuite) Test_Get_Recommendation_Success() {
 		Select("id").
 		From("users").
 		Where("email = $1", "base@mail.com").
-		RunWith(infra.PgConn).
+		RunWith(pgTest.conn).
 		QueryRow()
 
 	var userId string
@@ -75,13 +80,13 @@ func (s *RecommendationTestSuite) Test_Get_Recommendation_Success() {
 		StatementBuilder.
 		PlaceholderFormat(sq.Dollar).
 		Insert("latest_locations").
-		Columns("user_id", "updated_at", "lat", "lng").
-		Values(userIds[0], time.Now().Add(-1*time.Hour).Unix(), "-7.96447", "112.687").
-		Values(userIds[1], time.Now().Unix(), "-6.22956", "106.747").
-		Values(userIds[2], time.Now().Add(-3*time.Hour).Unix(), "-7.95349", "112.630").
-		Values(userIds[3], time.Now().Add(-6*time.Hour).Unix(), "-7.95349", "112.610").
-		Values(userIds[4], time.Now().Unix(), "-7.94447", "112.647").
-		RunWith(infra.PgConn).
+		Columns("user_id", "updated_at", "lat", "lng", "city", "country_code").
+		Values(userIds[0], time.Now().Add(-1*time.Hour).Unix(), "-7.96447", "112.687", "Malang", "ID").
+		Values(userIds[1], time.Now().Unix(), "-6.22956", "106.747", "Jakarta", "ID").
+		Values(userIds[2], time.Now().Add(-3*time.Hour).Unix(), "-7.95349", "112.630", nil, nil).
+		Values(userIds[3], time.Now().Add(-6*time.Hour).Unix(), "-7.95349", "112.610", "Malang", "ID").
+		Values(userIds[4], time.Now().Unix(), "-7.94447", "112.647", "Malang", "ID").
+		RunWith(pgTest.conn).
 		Exec()
 	s.Nil(err)
 
@@ -104,5 +109,320 @@ func (s *RecommendationTestSuite) Test_Get_Recommendation_Success() {
 		recMap, ok := rec.(map[string]interface{})
 		s.True(ok)
 		s.Contains(expectedResult, recMap["id"])
+		// location is shown as city, or null when it is not resolved
+		if recMap["id"] == userIds[0] {
+			s.Equal("Malang, ID", recMap["location"])
+		} else {
+			s.Nil(recMap["location"])
+		}
+	}
+}
+
+func (s *RecommendationTestSuite) Test_Get_Recommendation_NearestActedOn_Success() {
+	tokens := getAuthToken(s.T(), pgTest.conn)
+
+	// actor is in Malang, surrounded by 1100 users they already passed, one user further away is left
+	_, err := pgTest.conn.Exec(`
+		INSERT INTO users (email, password, birth_of_date)
+		SELECT 'near.' || n || '@mail.com', 'password', 0 FROM generate_series(1, 1100) AS n
+	`)
+	s.Nil(err)
+	_, err = pgTest.conn.Exec(`
+		INSERT INTO latest_locations (user_id, updated_at, lat, lng)
+		SELECT id, 0, -7.9445, 112.647 FROM users WHERE email LIKE 'near.%'
+		UNION ALL
+		SELECT id, DATE_PART('EPOCH', NOW()), -7.9445, 112.647 FROM users WHERE email = 'base@mail.com'
+	`)
+	s.Nil(err)
+	_, err = pgTest.conn.Exec(`
+		INSERT INTO passes (self_id, target_id)
+		SELECT base.id, near.id FROM users base, users near WHERE base.email = 'base@mail.com' AND near.email LIKE 'near.%'
+	`)
+	s.Nil(err)
+
+	var farID string
+	s.Nil(pgTest.conn.QueryRow(`
+		INSERT INTO users (email, password, birth_of_date) VALUES ('far@mail.com', 'password', 0) RETURNING id
+	`).Scan(&farID))
+	_, err = pgTest.conn.Exec(`INSERT INTO latest_locations (user_id, updated_at, lat, lng) VALUES ($1, 0, -7.7956, 112.6326)`, farID)
+	s.Nil(err)
+
+	res := newHttpTest().
+		withPath("/v1/recommendations?limit=10").
+		withHeader("Cookie", fmt.Sprintf("%s=%s", tokens[0][0], tokens[0][1])).
+		withHeader("Cookie", fmt.Sprintf("%s=%s", tokens[1][0], tokens[1][1])).
+		do()
+
+	s.Equal(http.StatusOK, res.StatusCode)
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	s.Nil(err)
+	var response struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	s.Nil(json.Unmarshal(body, &response))
+	s.Len(response.Data, 1)
+	s.Equal(farID, response.Data[0].ID)
+}
+
+func (s *RecommendationTestSuite) Test_Get_Recommendation_Passport_Success() {
+	tokens := getAuthToken(s.T(), pgTest.conn)
+
+	rows, err := sq.
+		StatementBuilder.
+		PlaceholderFormat(sq.Dollar).
+		Insert("users").
+		Columns("email", "password", "birth_of_date").
+		Values("malang@mail.com", "password", time.Now().Unix()).
+		Values("jakarta@mail.com", "password", time.Now().Unix()).
+		Suffix("RETURNING id").
+		RunWith(pgTest.conn).
+		Query()
+	s.Nil(err)
+	userIds := make([]string, 0)
+	for rows.Next() {
+		var userId string
+		s.Nil(rows.Scan(&userId))
+		userIds = append(userIds, userId)
+	}
+
+	var selfId string
+	s.Nil(sq.
+		StatementBuilder.
+		PlaceholderFormat(sq.Dollar).
+		Update("users").
+		Set("subscribe_until", time.Now().Add(24*time.Hour).Unix()).
+		Where("email = ?", "base@mail.com").
+		Suffix("RETURNING id").
+		RunWith(pgTest.conn).
+		QueryRow().
+		Scan(&selfId))
+
+	_, err = sq.
+		StatementBuilder.
+		PlaceholderFormat(sq.Dollar).
+		Insert("latest_locations").
+		Columns("user_id", "updated_at", "lat", "lng").
+		Values(userIds[0], time.Now().Unix(), "-7.96447", "112.687").
+		Values(userIds[1], time.Now().Unix(), "-6.22956", "106.747").
+		Values(selfId, time.Now().Unix(), "-7.94447", "112.647").
+		RunWith(pgTest.conn).
+		Exec()
+	s.Nil(err)
+
+	res := newHttpTest().
+		withPath("/v1/locations/passport").
+		withMethod(http.MethodPut).
+		withBody(map[string]interface{}{
+			"city": "Jakarta",
+		}).
+		withHeader("Cookie", fmt.Sprintf("%s=%s", tokens[0][0], tokens[0][1])).
+		withHeader("Cookie", fmt.Sprintf("%s=%s", tokens[1][0], tokens[1][1])).
+		do()
+	s.Equal(http.StatusOK, res.StatusCode)
+
+	res = newHttpTest().
+		withPath("/v1/recommendations?limit=10").
+		withHeader("Cookie", fmt.Sprintf("%s=%s", tokens[0][0], tokens[0][1])).
+		withHeader("Cookie", fmt.Sprintf("%s=%s", tokens[1][0], tokens[1][1])).
+		do()
+
+	s.Equal(http.StatusOK, res.StatusCode)
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	s.Nil(err)
+	var response struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	s.Nil(json.Unmarshal(body, &response))
+	s.Len(response.Data, 1)
+	s.Equal(userIds[1], response.Data[0].ID)
+}
+
+func (s *RecommendationTestSuite) Test_Get_Recommendation_Distance_KnownPairs() {
+	tokens := getAuthToken(s.T(), pgTest.conn)
+
+	rows, err := sq.
+		StatementBuilder.
+		PlaceholderFormat(sq.Dollar).
+		Insert("users").
+		Columns("email", "password", "birth_of_date").
+		Values("surabaya@mail.com", "password", time.Now().Unix()).
+		Values("jakarta@mail.com", "password", time.Now().Unix()).
+		Suffix("RETURNING id").
+		RunWith(pgTest.conn).
+		Query()
+	s.Nil(err)
+	userIds := make([]string, 0)
+	for rows.Next() {
+		var userId string
+		s.Nil(rows.Scan(&userId))
+		userIds = append(userIds, userId)
+	}
+
+	var selfId string
+	s.Nil(sq.
+		StatementBuilder.
+		PlaceholderFormat(sq.Dollar).
+		Select("id").
+		From("users").
+		Where("email = ?", "base@mail.com").
+		RunWith(pgTest.conn).
+		QueryRow().
+		Scan(&selfId))
+
+	malang := geo.Point{Lat: -7.9666, Lng: 112.6326}
+	surabaya := geo.Point{Lat: -7.2575, Lng: 112.7521}
+	jakarta := geo.Point{Lat: -6.2088, Lng: 106.8456}
+	_, err = sq.
+		StatementBuilder.
+		PlaceholderFormat(sq.Dollar).
+		Insert("latest_locations").
+		Columns("user_id", "updated_at", "lat", "lng").
+		Values(userIds[0], time.Now().Unix(), surabaya.Lat, surabaya.Lng).
+		Values(userIds[1], time.Now().Unix(), jakarta.Lat, jakarta.Lng).
+		Values(selfId, time.Now().Unix(), malang.Lat, malang.Lng).
+		RunWith(pgTest.conn).
+		Exec()
+	s.Nil(err)
+
+	res := newHttpTest().
+		withPath("/v1/recommendations?limit=10").
+		withHeader("Cookie", fmt.Sprintf("%s=%s", tokens[0][0], tokens[0][1])).
+		withHeader("Cookie", fmt.Sprintf("%s=%s", tokens[1][0], tokens[1][1])).
+		do()
+
+	s.Equal(http.StatusOK, res.StatusCode)
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	s.Nil(err)
+	var response struct {
+		Data []struct {
+			ID       string       `json:"id"`
+			Distance geo.Distance `json:"distance"`
+		} `json:"data"`
+	}
+	s.Nil(json.Unmarshal(body, &response))
+	// jakarta is ~660km away, far beyond recommendation radius
+	s.Len(response.Data, 1)
+	s.Equal(userIds[0], response.Data[0].ID)
+	s.Equal(geo.DefaultFuzzPolicy.Bucket(malang.DistanceTo(surabaya)), response.Data[0].Distance)
+	s.Equal(geo.Distance{Value: 80, Unit: "km", Label: "80 km"}, response.Data[0].Distance)
+}
+
+func (s *RecommendationTestSuite) Test_Get_Recommendation_RedisNearbyIndex_Success() {
+	newRedisTest(s.T())
+	a := newTestApp(pgTest.conn, func(cfg *config.Configuration) {
+		cfg.Discovery.NearbyIndex = "redis"
+	})
+	handler := rest.NewHandler(a)
+	conn := rdsTest.pool.Get()
+	defer conn.Close()
+	// redis outlives schema of the test, so start from empty index
+	_, err := conn.Do("DEL", "nearby-users")
+	s.Nil(err)
+
+	tokens := getAuthToken(s.T(), pgTest.conn)
+
+	rows, err := sq.
+		StatementBuilder.
+		PlaceholderFormat(sq.Dollar).
+		Insert("users").
+		Columns("email", "password", "birth_of_date").
+		Values("surabaya@mail.com", "password", time.Now().Unix()).
+		Values("jakarta@mail.com", "password", time.Now().Unix()).
+		Values("unindexed@mail.com", "password", time.Now().Unix()).
+		Suffix("RETURNING id").
+		RunWith(pgTest.conn).
+		Query()
+	s.Nil(err)
+	userIds := make([]string, 0)
+	for rows.Next() {
+		var userId string
+		s.Nil(rows.Scan(&userId))
+		userIds = append(userIds, userId)
 	}
+
+	_, err = sq.
+		StatementBuilder.
+		PlaceholderFormat(sq.Dollar).
+		Insert("latest_locations").
+		Columns("user_id", "updated_at", "lat", "lng").
+		Values(userIds[0], time.Now().Unix(), -7.2575, 112.7521).
+		Values(userIds[1], time.Now().Unix(), -6.2088, 106.8456).
+		RunWith(pgTest.conn).
+		Exec()
+	s.Nil(err)
+
+	indexed, err := a.Services.Locations.RebuildNearbyIndex(context.Background())
+	s.Nil(err)
+	s.Equal(2, indexed)
+
+	// written to postgres only, so discovery through redis can't see it
+	_, err = sq.
+		StatementBuilder.
+		PlaceholderFormat(sq.Dollar).
+		Insert("latest_locations").
+		Columns("user_id", "updated_at", "lat", "lng").
+		Values(userIds[2], time.Now().Unix(), -7.9666, 112.6326).
+		RunWith(pgTest.conn).
+		Exec()
+	s.Nil(err)
+
+	// location update of the actor feeds the index
+	res := newHttpTest().
+		withPath("/v1/locations").
+		withMethod(http.MethodPost).
+		withBody(map[string]interface{}{
+			"lat": "-7.9666",
+			"lng": "112.6326",
+		}).
+		withHeader("Cookie", fmt.Sprintf("%s=%s", tokens[0][0], tokens[0][1])).
+		withHeader("Cookie", fmt.Sprintf("%s=%s", tokens[1][0], tokens[1][1])).
+		doWith(handler)
+	s.Equal(http.StatusOK, res.StatusCode)
+
+	indexedUsers, err := redis.Int(conn.Do("ZCARD", "nearby-users"))
+	s.Nil(err)
+	s.Equal(3, indexedUsers)
+
+	res = newHttpTest().
+		withPath("/v1/recommendations?limit=10").
+		withHeader("Cookie", fmt.Sprintf("%s=%s", tokens[0][0], tokens[0][1])).
+		withHeader("Cookie", fmt.Sprintf("%s=%s", tokens[1][0], tokens[1][1])).
+		doWith(handler)
+
+	s.Equal(http.StatusOK, res.StatusCode)
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	s.Nil(err)
+	var response struct {
+		Data []struct {
+			ID       string       `json:"id"`
+			Distance geo.Distance `json:"distance"`
+		} `json:"data"`
+	}
+	s.Nil(json.Unmarshal(body, &response))
+	s.Len(response.Data, 1)
+	s.Equal(userIds[0], response.Data[0].ID)
+	// actor location is jittered, so the bucket may shift by one
+	s.Equal("km", response.Data[0].Distance.Unit)
+	s.InDelta(80, response.Data[0].Distance.Value, 1)
+
+	// stopping location sharing takes the actor off the index
+	res = newHttpTest().
+		withPath("/v1/locations").
+		withMethod(http.MethodDelete).
+		withHeader("Cookie", fmt.Sprintf("%s=%s", tokens[0][0], tokens[0][1])).
+		withHeader("Cookie", fmt.Sprintf("%s=%s", tokens[1][0], tokens[1][1])).
+		doWith(handler)
+	s.Equal(http.StatusOK, res.StatusCode)
+
+	indexedUsers, err = redis.Int(conn.Do("ZCARD", "nearby-users"))
+	s.Nil(err)
+	s.Equal(2, indexedUsers)
 }
@@ -2,99 +2,147 @@ package rest
 
 import (
 	"context"
-	"database/sql"
 	"fmt"
-	"gotinder/infra"
-	"log"
+	"gotinder/app"
+	"gotinder/apperr"
+	"gotinder/config"
+	"gotinder/logging"
+	"gotinder/metrics"
+	"io"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
 	"reflect"
+	"runtime/debug"
+	"strconv"
 	"syscall"
 	"time"
 
-	sq "github.com/Masterminds/squirrel"
 	"github.com/gin-gonic/gin"
-	"github.com/gin-gonic/gin/binding"
 	"github.com/go-pkgz/auth/token"
+	"github.com/google/uuid"
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 	"golang.org/x/sync/errgroup"
 )
 
+const (
+	requestIDHeader    = "X-Request-ID"
+	maxRequestIDLength = 128
+
+	defaultRequestTimeout = 30 * time.Second
+)
+
+// probeRoutes are routes polled by infrastructure rather than called by users
+var probeRoutes = map[string]bool{
+	"/healthz": true,
+	"/readyz":  true,
+	"/metrics": true,
+}
+
 type (
 	// Cleanup is a type to define function which has to call on shutdown
 	CleanupFn func() (name string, fn func())
 
 	// v1 is a type to group register function
 	v1 struct {
-		group *gin.RouterGroup
-		auth  *authService
+		app.Services
+		group   *gin.RouterGroup
+		auth    *authService
+		metrics *metrics.Metrics
 	}
 )
 
-// New run server with graceful shutdown
-func New(port int, cleanupFns ...CleanupFn) {
-	ctx, cancel := context.WithCancel(context.Background())
+// New run server of the app until it is interrupted, then shut it down gracefully
+func New(a *app.App, cleanupFns ...CleanupFn) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	go func() {
-		stop := make(chan os.Signal, 1)
-		signal.Notify(stop, os.Interrupt, syscall.SIGTERM, syscall.SIGINT)
-		<-stop
-		cancel()
-	}()
+	if err := Serve(ctx, a, cleanupFns...); err != nil {
+		slog.Error("fail to exit server", "error", err)
+	}
+}
 
+// Serve run server of the app until ctx is done and shut it down gracefully, cleanups are run one by one
+// in the given order once the server stopped, so they may release what requests in flight use
+func Serve(ctx context.Context, a *app.App, cleanupFns ...CleanupFn) error {
+	port := a.Config.App.Rest.Port
 	if port == 0 {
 		port = 3000
 	}
 	address := fmt.Sprintf(":%d", port)
+	probe := new(readiness)
 	srv := &http.Server{
-		Handler:           NewHandler(),
+		Handler:           newHandler(a, probe),
 		ReadHeaderTimeout: 1 * time.Minute,
 	}
 	srv.Addr = address
-	for _, cleanupFn := range cleanupFns {
-		srv.RegisterOnShutdown(func() {
-			name, fn := cleanupFn()
-			log.Println(name)
-			fn()
-		})
-	}
 
 	eg, egCtx := errgroup.WithContext(ctx)
 
 	eg.Go(func() error {
-		fmt.Println("server listening on port", port)
-		err := srv.ListenAndServe()
-		return err
+		slog.Info("server listening", "port", port)
+		if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		return nil
 	})
 
 	eg.Go(func() error {
 		<-egCtx.Done()
-		log.Println("shutting down server")
+		// readiness fails first, so traffic is moved away before the server stops accepting it
+		probe.drain()
+		slog.Info("draining server", "delay", a.Config.App.Rest.ShutdownDelay)
+		time.Sleep(a.Config.App.Rest.ShutdownDelay)
+		slog.Info("shutting down server")
 		err := srv.Shutdown(context.Background())
-		log.Println("server shutted down gracefully")
+		slog.Info("server shutted down gracefully")
 		return err
 	})
 
-	if err := eg.Wait(); err != nil {
-		fmt.Printf("fail to exit server: %s\n", err)
+	err := eg.Wait()
+	for _, cleanupFn := range cleanupFns {
+		name, fn := cleanupFn()
+		slog.Info(name)
+		fn()
 	}
+	return err
 }
 
-// NewHandler register handler on its path for restful API
-func NewHandler() *gin.Engine {
-	binding.Validator = new(bindValidator)
-	h := gin.Default()
+// NewHandler register handler of the app on its path for restful API
+func NewHandler(a *app.App) *gin.Engine {
+	return newHandler(a, new(readiness))
+}
+
+// newHandler register handler of the app reporting readiness of the probe
+func newHandler(a *app.App, probe *readiness) *gin.Engine {
+	useValidate()
+	h := gin.New()
+	h.Use(
+		requestID,
+		otelgin.Middleware(a.Config.App.Rest.Name),
+		observeRequest(a.Metrics),
+		logRequest,
+		renderErr,
+		boundRequest(requestTimeout(a.Config.App.Rest)),
+		gin.CustomRecoveryWithWriter(io.Discard, recoverPanic),
+	)
 
 	h.GET("/", func(ctx *gin.Context) {
 		ctx.Status(http.StatusOK)
 	})
+	h.GET("/metrics", gin.WrapH(a.Metrics.Handler()))
+	h.GET("/healthz", healthz)
+	h.GET("/readyz", readyz(a, probe))
 
 	authSvc := new(authService)
-	authSvc.init()
+	authSvc.init(a.Services.Users, requestTimeout(a.Config.App.Rest))
 	v1Group := v1{
-		group: h.Group("/v1"),
-		auth:  authSvc,
+		Services: a.Services,
+		group:    h.Group("/v1"),
+		auth:     authSvc,
+		metrics:  a.Metrics,
 	}
 	registerHandler[v1](v1Group)
 
@@ -110,7 +158,106 @@ func registerHandler[T any](group T) {
 	}
 }
 
-// asGin converts middleware to the gin middleware handler.
+// observeRequest record count and latency of requests by their route, unmatched ones share one label
+// so unknown paths can not blow up the number of series
+func observeRequest(m *metrics.Metrics) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		start := time.Now()
+		ctx.Next()
+
+		route := ctx.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		m.Requests.WithLabelValues(ctx.Request.Method, route, strconv.Itoa(ctx.Writer.Status())).Inc()
+		m.RequestDuration.WithLabelValues(ctx.Request.Method, route).Observe(time.Since(start).Seconds())
+	}
+}
+
+// requestID propagate X-Request-ID of the request, or generate one when it is missing or malformed,
+// so the response and every record logged while serving the request carry it
+func requestID(ctx *gin.Context) {
+	id := ctx.GetHeader(requestIDHeader)
+	if !isValidRequestID(id) {
+		id = uuid.NewString()
+	}
+	ctx.Header(requestIDHeader, id)
+	ctx.Request = ctx.Request.WithContext(logging.With(ctx.Request.Context(), slog.String("request_id", id)))
+	ctx.Next()
+}
+
+// requestTimeout give deadline of each request of the app, zero falls back to default and negative disables it
+func requestTimeout(cfg config.AppConfiguration) time.Duration {
+	if cfg.RequestTimeout == 0 {
+		return defaultRequestTimeout
+	}
+	return cfg.RequestTimeout
+}
+
+// boundRequest cancel context of the request once timeout is reached, so its queries and redis commands stop
+// and it is rendered as timeout error
+func boundRequest(timeout time.Duration) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		reqCtx, cancel := withTimeout(ctx.Request.Context(), timeout)
+		defer cancel()
+		ctx.Request = ctx.Request.WithContext(reqCtx)
+		ctx.Next()
+	}
+}
+
+// withTimeout give ctx cancelled once timeout is reached, non-positive timeout leaves it unbounded
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// isValidRequestID check the id is short and printable, so it can not forge log records
+func isValidRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		if r <= ' ' || r > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// logRequest log every request once served, query values of passwords and tokens are redacted
+// while headers and bodies are never logged
+func logRequest(ctx *gin.Context) {
+	start := time.Now()
+	ctx.Next()
+
+	level := slog.LevelInfo
+	switch {
+	case ctx.Writer.Status() >= http.StatusInternalServerError:
+		level = slog.LevelError
+	case probeRoutes[ctx.FullPath()]:
+		// probes and scrapes come every few seconds, they would bury other requests
+		level = slog.LevelDebug
+	}
+	slog.LogAttrs(ctx.Request.Context(), level, "request served",
+		slog.String("method", ctx.Request.Method),
+		slog.String("route", ctx.FullPath()),
+		slog.String("path", logging.RedactURL(ctx.Request.URL)),
+		slog.Int("status", ctx.Writer.Status()),
+		slog.Duration("latency", time.Since(start)),
+		slog.String("client_ip", ctx.ClientIP()),
+	)
+}
+
+// recoverPanic log panic of the handler with its stack, responding with internal error
+func recoverPanic(ctx *gin.Context, recovered any) {
+	slog.ErrorContext(ctx.Request.Context(), "panic recovered", "panic", recovered, "stack", string(debug.Stack()))
+	abortWithErr(ctx, apperr.ErrInternal)
+}
+
+// asGin converts middleware to the gin middleware handler,
+// rejection of the middleware is rendered as JSON error envelope instead of its own response
 func asGin(middleware func(next http.Handler) http.Handler) gin.HandlerFunc {
 	return func(gctx *gin.Context) {
 		var skip = true
@@ -118,53 +265,62 @@ func asGin(middleware func(next http.Handler) http.Handler) gin.HandlerFunc {
 			gctx.Request = r
 			skip = false
 		}
-		middleware(handler).ServeHTTP(gctx.Writer, gctx.Request)
+		w := &middlewareWriter{ResponseWriter: gctx.Writer}
+		middleware(handler).ServeHTTP(w, gctx.Request)
 		switch {
 		case skip:
-			gctx.Abort()
+			gctx.Writer.Header().Del("Content-Type")
+			abortWithErr(gctx, middlewareErr(w.status))
 		default:
 			gctx.Next()
 		}
 	}
 }
 
+// middlewareWriter hold back response of the middleware, only its headers (e.g. refreshed cookies) are kept
+type middlewareWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *middlewareWriter) WriteHeader(status int) {
+	w.status = status
+}
+
+func (w *middlewareWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+// middlewareErr give error of the status the middleware rejected request with
+func middlewareErr(status int) *apperr.Error {
+	if status == http.StatusForbidden {
+		return apperr.ErrForbidden
+	}
+	return apperr.ErrUnauthorized
+}
+
 // enrichActor will enrich current user information on context
-func enrichActor(ctx *gin.Context) {
+func (v v1) enrichActor(ctx *gin.Context) {
 	u := token.MustGetUserInfo(ctx.Request)
-	user := &u
-
-	findUserQuery, _, err := sq.
-		StatementBuilder.
-		PlaceholderFormat(sq.Dollar).
-		Select("id", "subscribe_until").
-		From("users").
-		Where("email = $1").
-		ToSql()
-	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{
-			"error": errors.Wrap(err, "failed to build find user query").Error(),
-		})
-		return
-	}
 
-	row := infra.PgConn.QueryRow(findUserQuery, user.Name)
-	var userID string
-	var subscribeUntil sql.NullInt64
-	if err := row.Scan(&userID, &subscribeUntil); err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			ctx.JSON(http.StatusNotFound, gin.H{
-				"error": "user not found",
-			})
-			return
-		}
-		ctx.JSON(http.StatusInternalServerError, gin.H{
-			"error": errors.Wrap(err, "failed to find user").Error(),
-		})
+	actor, err := v.Users.Actor(ctx.Request.Context(), u.Name)
+	if err != nil {
+		abortWithErr(ctx, err)
 		return
 	}
 
-	user.SetStrAttr("user_id", userID)
-	user.SetPaidSub(subscribeUntil.Valid && time.Now().Before(time.Unix(subscribeUntil.Int64, 0)))
+	u.SetStrAttr("user_id", actor.ID)
+	u.SetAdmin(actor.IsAdmin)
+	setPlanAttrs(&u, actor.Tier, actor.Features, actor.Quotas)
 
+	ctx.Request = ctx.Request.WithContext(logging.With(ctx.Request.Context(), slog.String("user_id", actor.ID)))
 	ctx.Request = token.SetUserInfo(ctx.Request, u)
 }
+
+// requireAdmin reject actor who is not an admin, must be placed after enrichActor
+func requireAdmin(ctx *gin.Context) {
+	user := token.MustGetUserInfo(ctx.Request)
+	if !user.IsAdmin() {
+		abortWithErr(ctx, errAdminRequired)
+	}
+}
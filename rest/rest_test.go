@@ -2,38 +2,15 @@ package rest_test
 
 import (
 	"bytes"
-	"context"
-	"database/sql"
 	"encoding/json"
 	"fmt"
-	"gotinder/infra"
-	"gotinder/rest"
 	"io"
 	"net/http"
 	"net/http/httptest"
-	"regexp"
-	"strings"
 	"sync"
-	"testing"
 	"time"
 
-	sq "github.com/Masterminds/squirrel"
-	_ "github.com/amacneil/dbmate/v2/pkg/driver/postgres"
 	"github.com/gin-gonic/gin"
-	_ "github.com/lib/pq"
-	"github.com/stretchr/testify/require"
-	"github.com/testcontainers/testcontainers-go"
-	"github.com/testcontainers/testcontainers-go/modules/postgres"
-	"github.com/testcontainers/testcontainers-go/modules/redis"
-	"github.com/testcontainers/testcontainers-go/wait"
-	"golang.org/x/crypto/bcrypt"
-)
-
-var (
-	pgTest      *postgresTest
-	pgTestOnce  sync.Once
-	rdsTest     *redisTest
-	rdsTestOnce sync.Once
 )
 
 type (
@@ -43,19 +20,15 @@ type (
 		body   io.Reader
 		header http.Header
 	}
-
-	postgresTest struct {
-		container *postgres.PostgresContainer
-		connStr   string
-	}
-
-	redisTest struct {
-		connStr string
-	}
 )
 
-func newHttpTest() *httpTestBuilder {
+// setTestMode put gin on test mode, once so concurrently built requests don't race on it
+var setTestMode = sync.OnceFunc(func() {
 	gin.SetMode(gin.TestMode)
+})
+
+func newHttpTest() *httpTestBuilder {
+	setTestMode()
 	h := make(http.Header)
 	h.Add("Content-Type", "application/json")
 	return &httpTestBuilder{
@@ -64,7 +37,8 @@ func newHttpTest() *httpTestBuilder {
 	}
 }
 
-func (b *httpTestBuilder) do() *http.Response {
+// doWith serve the request by given handler, so concurrent requests can share one handler
+func (b *httpTestBuilder) doWith(handler http.Handler) *http.Response {
 	host := "localhost:3000"
 	url := fmt.Sprintf("http://%s%s", host, b.path)
 	request := httptest.NewRequest(b.method, url, b.body)
@@ -72,7 +46,7 @@ func (b *httpTestBuilder) do() *http.Response {
 	recorder := httptest.NewRecorder()
 
 	server := &http.Server{
-		Handler:           rest.NewHandler(),
+		Handler:           handler,
 		ReadHeaderTimeout: 1 * time.Minute,
 	}
 	server.Handler.ServeHTTP(recorder, request)
@@ -95,92 +69,12 @@ func (b *httpTestBuilder) withBody(body any) *httpTestBuilder {
 	return b
 }
 
-func (b *httpTestBuilder) withHeader(key, val string) *httpTestBuilder {
-	b.header.Add(key, val)
+func (b *httpTestBuilder) withRawBody(body []byte) *httpTestBuilder {
+	b.body = bytes.NewReader(body)
 	return b
 }
 
-func newPostgresTest(t *testing.T) *postgresTest {
-	pgTestOnce.Do(func() {
-		var err error
-		ctx := context.Background()
-		pgTest = new(postgresTest)
-		pgTest.container, err = postgres.RunContainer(
-			ctx,
-			testcontainers.WithImage("docker.io/postgis/postgis:15-3.4"),
-			postgres.WithDatabase("test"),
-			testcontainers.WithWaitStrategy(wait.ForLog("database system is ready to accept connections").WithOccurrence(2).WithStartupTimeout(5*time.Second)),
-		)
-		require.NoError(t, err)
-
-		pgTest.connStr, err = pgTest.container.ConnectionString(ctx, "sslmode=disable", "application_name=test")
-		require.NoError(t, err)
-
-		infra.Migrate(fmt.Sprintf("%s&search_path=public", pgTest.connStr), "../migrations", "test_scheme_migrations")
-	})
-	return pgTest
-}
-
-func (p *postgresTest) migrate(t *testing.T, conn *sql.DB) {
-	scheme := strings.ToLower(regexp.MustCompile(`\W`).ReplaceAllString(t.Name(), "_"))
-	createSchema := fmt.Sprintf(`CREATE SCHEMA %s;`, scheme)
-	_, err := conn.Exec(createSchema)
-	require.NoError(t, err)
-
-	setSchema := fmt.Sprintf(`SET search_path TO %s,public;`, scheme)
-	_, err = conn.Exec(setSchema)
-	require.NoError(t, err)
-
-	infra.Migrate(fmt.Sprintf("%s&search_path=%s,public", p.connStr, scheme), "../migrations", "test_scheme_migrations")
-}
-
-func getAuthToken(t *testing.T, pgConn *sql.DB) [][]string {
-	password := "Secret1234!"
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	require.NoError(t, err)
-	_, err = sq.
-		StatementBuilder.
-		PlaceholderFormat(sq.Dollar).
-		Insert("users").
-		Columns("email", "password", "birth_of_date").
-		Values("base@mail.com", string(hashedPassword), time.Now().Unix()).
-		RunWith(pgConn).
-		Exec()
-	require.NoError(t, err)
-
-	res := newHttpTest().
-		withPath("/v1/auth/direct/login").
-		withMethod(http.MethodPost).
-		withBody(map[string]interface{}{
-			"user":   "base@mail.com",
-			"passwd": password,
-		}).
-		do()
-
-	require.Equal(t, http.StatusOK, res.StatusCode)
-	cookies := make([][]string, 0)
-	for _, cookie := range res.Cookies() {
-		cookies = append(cookies, []string{cookie.Name, cookie.Value})
-	}
-	require.Len(t, cookies, 2)
-	return cookies
-}
-
-func newRedisTest(t *testing.T) *redisTest {
-	rdsTestOnce.Do(func() {
-		container, err := redis.RunContainer(context.Background(),
-			testcontainers.WithImage("docker.io/redis:7"),
-			redis.WithSnapshotting(10, 1),
-			redis.WithLogLevel(redis.LogLevelVerbose),
-		)
-		require.NoError(t, err)
-
-		_, err = container.MappedPort(context.Background(), "6379")
-		require.NoError(t, err)
-
-		rdsTest = &redisTest{
-			connStr: "localhost:6379",
-		}
-	})
-	return rdsTest
+func (b *httpTestBuilder) withHeader(key, val string) *httpTestBuilder {
+	b.header.Add(key, val)
+	return b
 }
@@ -0,0 +1,47 @@
+package rest
+
+import (
+	"gotinder/subscription"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-pkgz/auth/token"
+	"github.com/google/uuid"
+)
+
+type (
+	// subscriptionResponse is a type of "/users/me/subscription" response,
+	// subscription fields are empty when user never subscribed
+	subscriptionResponse struct {
+		ID         *uuid.UUID             `json:"id"`
+		Status     *subscription.Status   `json:"status"`
+		StartedAt  *int64                 `json:"started_at"`
+		EndsAt     *int64                 `json:"ends_at"`
+		GraceUntil *int64                 `json:"grace_until"`
+		Plan       subscription.Plan      `json:"plan"`
+		History    []subscription.History `json:"history"`
+	}
+)
+
+// findMySubscription give current subscription of the actor along with its status history
+func (v v1) findMySubscription(ctx *gin.Context) {
+	user := token.MustGetUserInfo(ctx.Request)
+
+	overview, err := v.Subscriptions.Overview(ctx.Request.Context(), user.StrAttr("user_id"), user.StrAttr(attrPlanTier))
+	if err != nil {
+		abortWithErr(ctx, err)
+		return
+	}
+
+	res := subscriptionResponse{
+		Plan:    overview.Plan,
+		History: overview.History,
+	}
+	if s := overview.Subscription; s != nil {
+		res.ID, res.Status, res.StartedAt, res.EndsAt, res.GraceUntil = &s.ID, &s.Status, &s.StartedAt, &s.EndsAt, s.GraceUntil
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"data": res,
+	})
+}
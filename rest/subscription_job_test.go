@@ -0,0 +1,171 @@
+//go:build integration
+
+package rest_test
+
+import (
+	"context"
+	"gotinder/subscription"
+	"testing"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/stretchr/testify/suite"
+)
+
+type SubscriptionJobTestSuite struct {
+	suite.Suite
+}
+
+func TestSubscriptionJobTestSuite(t *testing.T) {
+	suite.Run(t, new(SubscriptionJobTestSuite))
+}
+
+func (s *SubscriptionJobTestSuite) SetupSuite() {
+	newPostgresTest(s.T())
+}
+
+func (s *SubscriptionJobTestSuite) SetupTest() {
+	pgTest.migrate(s.T(), pgTest.conn)
+	newRedisTest(s.T())
+}
+
+func (s *SubscriptionJobTestSuite) createSubscription(email string, status string, endsAt int64, graceUntil *int64) string {
+	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+	var userId string
+	s.Nil(psql.
+		Insert("users").
+		Columns("email", "password", "birth_of_date", "subscribe_until").
+		Values(email, "password", time.Now().Unix(), endsAt).
+		Suffix("RETURNING id").
+		RunWith(pgTest.conn).
+		QueryRow().
+		Scan(&userId))
+
+	var subscriptionId string
+	s.Nil(psql.
+		Insert("subscriptions").
+		Columns("user_id", "plan_id", "status", "started_at", "ends_at", "grace_until").
+		Select(psql.
+			Select().
+			Column("?", userId).
+			Column("id").
+			Column("?", status).
+			Column("?", time.Now().Add(-30*24*time.Hour).Unix()).
+			Column("?", endsAt).
+			Column("?", graceUntil).
+			From("plans").
+			Where("tier = ?", "premium")).
+		Suffix("RETURNING id").
+		RunWith(pgTest.conn).
+		QueryRow().
+		Scan(&subscriptionId))
+	return subscriptionId
+}
+
+func (s *SubscriptionJobTestSuite) subscriptionStatus(id string) string {
+	var status string
+	s.Nil(sq.
+		StatementBuilder.
+		PlaceholderFormat(sq.Dollar).
+		Select("status").
+		From("subscriptions").
+		Where("id = ?", id).
+		RunWith(pgTest.conn).
+		QueryRow().
+		Scan(&status))
+	return status
+}
+
+func (s *SubscriptionJobTestSuite) notificationTypes() map[string]int {
+	rows, err := sq.
+		StatementBuilder.
+		PlaceholderFormat(sq.Dollar).
+		Select("type", "COUNT(*)").
+		From("notification_events").
+		GroupBy("type").
+		RunWith(pgTest.conn).
+		Query()
+	s.Nil(err)
+	defer rows.Close()
+	types := make(map[string]int)
+	for rows.Next() {
+		var t string
+		var count int
+		s.Nil(rows.Scan(&t, &count))
+		types[t] = count
+	}
+	return types
+}
+
+func (s *SubscriptionJobTestSuite) Test_SubscriptionExpiryJob_Success() {
+	now := time.Now()
+	graceOver := now.Add(-time.Hour).Unix()
+	expiringId := s.createSubscription("expiring@mail.com", "active", now.Add(24*time.Hour).Unix(), nil)
+	runningId := s.createSubscription("running@mail.com", "active", now.Add(10*24*time.Hour).Unix(), nil)
+	endedId := s.createSubscription("ended@mail.com", "active", now.Add(-time.Hour).Unix(), nil)
+	graceId := s.createSubscription("grace@mail.com", "grace", now.Add(-4*24*time.Hour).Unix(), &graceOver)
+
+	job := newTestApp(pgTest.conn).Services.Subscriptions.NewExpiryJob(72*time.Hour, 72*time.Hour)
+	s.Nil(job(context.Background()))
+	// second run must not notify nor move subscriptions again
+	s.Nil(job(context.Background()))
+
+	s.Equal("active", s.subscriptionStatus(expiringId))
+	s.Equal("active", s.subscriptionStatus(runningId))
+	s.Equal("grace", s.subscriptionStatus(endedId))
+	s.Equal("expired", s.subscriptionStatus(graceId))
+
+	var graceUntil int64
+	s.Nil(sq.
+		StatementBuilder.
+		PlaceholderFormat(sq.Dollar).
+		Select("grace_until").
+		From("subscriptions").
+		Where("id = ?", endedId).
+		RunWith(pgTest.conn).
+		QueryRow().
+		Scan(&graceUntil))
+	s.Equal(now.Add(-time.Hour).Unix()+int64((72*time.Hour).Seconds()), graceUntil)
+
+	var histories int
+	s.Nil(sq.
+		StatementBuilder.
+		PlaceholderFormat(sq.Dollar).
+		Select("COUNT(*)").
+		From("subscription_histories").
+		Where(sq.Eq{"subscription_id": []string{endedId, graceId}}).
+		RunWith(pgTest.conn).
+		QueryRow().
+		Scan(&histories))
+	s.Equal(2, histories)
+
+	s.Equal(map[string]int{
+		"subscription.expiring": 1,
+		"subscription.grace":    1,
+		"subscription.expired":  1,
+	}, s.notificationTypes())
+}
+
+func (s *SubscriptionJobTestSuite) Test_SubscriptionExpiryJob_HistoryInOrder() {
+	// grace of the subscription is over already, so it is moved to grace and expired within the same second
+	s.createSubscription("ended@mail.com", "active", time.Now().Add(-4*24*time.Hour).Unix(), nil)
+	a := newTestApp(pgTest.conn)
+	s.Nil(a.Services.Subscriptions.NewExpiryJob(72*time.Hour, 72*time.Hour)(context.Background()))
+
+	var userId string
+	s.Nil(sq.
+		StatementBuilder.
+		PlaceholderFormat(sq.Dollar).
+		Select("id").
+		From("users").
+		Where("email = ?", "ended@mail.com").
+		RunWith(pgTest.conn).
+		QueryRow().
+		Scan(&userId))
+
+	overview, err := a.Services.Subscriptions.Overview(context.Background(), userId, "free")
+	s.Require().Nil(err)
+	s.Require().Len(overview.History, 2)
+	s.Equal(subscription.Grace, overview.History[0].ToStatus)
+	s.Equal(subscription.Expired, overview.History[1].ToStatus)
+}
@@ -1,10 +1,15 @@
+//go:build integration
+
 package rest_test
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
-	"gotinder/infra"
+	"gotinder/rest"
+	"io"
 	"net/http"
+	"sync"
 	"testing"
 	"time"
 
@@ -21,16 +26,15 @@ func TestUserTestSuite(t *testing.T) {
 }
 
 func (s *UserTestSuite) SetupSuite() {
-	pg := newPostgresTest(s.T())
-	infra.NewPgConnection(pg.connStr)
+	newPostgresTest(s.T())
 }
 
 func (s *UserTestSuite) SetupTest() {
-	pgTest.migrate(s.T(), infra.PgConn)
+	pgTest.migrate(s.T(), pgTest.conn)
 }
 
 func (s *UserTestSuite) Test_Post_UserSubscription_Success() {
-	tokens := getAuthToken(s.T(), infra.PgConn)
+	tokens := getAuthToken(s.T(), pgTest.conn)
 
 	rowFindUser := sq.
 		StatementBuilder.
@@ -38,7 +42,7 @@ func (s *UserTestSuite) Test_Post_UserSubscription_Success() {
 		Select("id").
 		From("users").
 		Where("email = ?", "base@mail.com").
-		RunWith(infra.PgConn).
+		RunWith(pgTest.conn).
 		QueryRow()
 	var userId string
 	s.Nil(rowFindUser.Scan(&userId))
@@ -51,7 +55,7 @@ func (s *UserTestSuite) Test_Post_UserSubscription_Success() {
 		Columns("code", "duration_in_second", "valid_until").
 		Values("NEWUSER123", couponDuration, time.Now().Add(24*14*time.Hour).Unix()).
 		Suffix("RETURNING id").
-		RunWith(infra.PgConn).
+		RunWith(pgTest.conn).
 		QueryRow()
 	var couponId string
 	s.Nil(rowCreateCoupon.Scan(&couponId))
@@ -63,7 +67,7 @@ func (s *UserTestSuite) Test_Post_UserSubscription_Success() {
 		Columns("user_id", "coupon_id").
 		Values(userId, couponId).
 		Suffix("RETURNING id").
-		RunWith(infra.PgConn).
+		RunWith(pgTest.conn).
 		QueryRow()
 	var userCouponId string
 	s.Nil(rowCreateUserCoupon.Scan(&userCouponId))
@@ -86,7 +90,7 @@ func (s *UserTestSuite) Test_Post_UserSubscription_Success() {
 		Select("subscribe_until").
 		From("users").
 		Where("id = ?", userId).
-		RunWith(infra.PgConn).
+		RunWith(pgTest.conn).
 		QueryRow()
 
 	var subscribeUntil sql.NullInt64
@@ -99,7 +103,7 @@ func (s *UserTestSuite) Test_Post_UserSubscription_Success() {
 		Select("used_at").
 		From("user_coupons").
 		Where("id = ?", userCouponId).
-		RunWith(infra.PgConn).
+		RunWith(pgTest.conn).
 		QueryRow()
 
 	var usedAt sql.NullInt64
@@ -108,7 +112,7 @@ func (s *UserTestSuite) Test_Post_UserSubscription_Success() {
 }
 
 func (s *UserTestSuite) Test_Post_UserSubscription_SubscribedBefore_Success() {
-	tokens := getAuthToken(s.T(), infra.PgConn)
+	tokens := getAuthToken(s.T(), pgTest.conn)
 
 	rowFindUser := sq.
 		StatementBuilder.
@@ -117,7 +121,7 @@ func (s *UserTestSuite) Test_Post_UserSubscription_SubscribedBefore_Success() {
 		Set("subscribe_until", time.Now().Add(24*14*time.Hour).Unix()).
 		Where("email = ?", "base@mail.com").
 		Suffix("RETURNING id").
-		RunWith(infra.PgConn).
+		RunWith(pgTest.conn).
 		QueryRow()
 	var userId string
 	s.Nil(rowFindUser.Scan(&userId))
@@ -130,7 +134,7 @@ func (s *UserTestSuite) Test_Post_UserSubscription_SubscribedBefore_Success() {
 		Columns("code", "duration_in_second", "valid_until").
 		Values("NEWUSER123", couponDuration, time.Now().Add(24*14*time.Hour).Unix()).
 		Suffix("RETURNING id").
-		RunWith(infra.PgConn).
+		RunWith(pgTest.conn).
 		QueryRow()
 	var couponId string
 	s.Nil(rowCreateCoupon.Scan(&couponId))
@@ -142,7 +146,7 @@ func (s *UserTestSuite) Test_Post_UserSubscription_SubscribedBefore_Success() {
 		Columns("user_id", "coupon_id").
 		Values(userId, couponId).
 		Suffix("RETURNING id").
-		RunWith(infra.PgConn).
+		RunWith(pgTest.conn).
 		QueryRow()
 	var userCouponId string
 	s.Nil(rowCreateUserCoupon.Scan(&userCouponId))
@@ -165,7 +169,7 @@ func (s *UserTestSuite) Test_Post_UserSubscription_SubscribedBefore_Success() {
 		Select("subscribe_until").
 		From("users").
 		Where("id = ?", userId).
-		RunWith(infra.PgConn).
+		RunWith(pgTest.conn).
 		QueryRow()
 
 	var subscribeUntil sql.NullInt64
@@ -178,10 +182,289 @@ func (s *UserTestSuite) Test_Post_UserSubscription_SubscribedBefore_Success() {
 		Select("used_at").
 		From("user_coupons").
 		Where("id = ?", userCouponId).
-		RunWith(infra.PgConn).
+		RunWith(pgTest.conn).
 		QueryRow()
 
 	var usedAt sql.NullInt64
 	s.Nil(rowUpdatedUserCoupon.Scan(&usedAt))
 	s.Equal(time.Now().Unix(), usedAt.Int64)
 }
+
+func (s *UserTestSuite) Test_Get_UserMeSubscription_NeverSubscribed() {
+	tokens := getAuthToken(s.T(), pgTest.conn)
+
+	res := newHttpTest().
+		withPath("/v1/users/me/subscription").
+		withHeader("Cookie", fmt.Sprintf("%s=%s", tokens[0][0], tokens[0][1])).
+		withHeader("Cookie", fmt.Sprintf("%s=%s", tokens[1][0], tokens[1][1])).
+		do()
+
+	s.Equal(http.StatusOK, res.StatusCode)
+	body, err := io.ReadAll(res.Body)
+	s.Nil(err)
+	defer res.Body.Close()
+	var response struct {
+		Data struct {
+			Status *string `json:"status"`
+			Plan   struct {
+				Tier string `json:"tier"`
+			} `json:"plan"`
+		} `json:"data"`
+	}
+	s.Nil(json.Unmarshal(body, &response))
+	s.Nil(response.Data.Status)
+	s.Equal("free", response.Data.Plan.Tier)
+}
+
+func (s *UserTestSuite) Test_Get_UserMeSubscription_AfterSubscribe() {
+	tokens := getAuthToken(s.T(), pgTest.conn)
+
+	rowFindUser := sq.
+		StatementBuilder.
+		PlaceholderFormat(sq.Dollar).
+		Select("id").
+		From("users").
+		Where("email = ?", "base@mail.com").
+		RunWith(pgTest.conn).
+		QueryRow()
+	var userId string
+	s.Nil(rowFindUser.Scan(&userId))
+
+	rowCreateCoupon := sq.
+		StatementBuilder.
+		PlaceholderFormat(sq.Dollar).
+		Insert("coupons").
+		Columns("code", "duration_in_second", "valid_until").
+		Values("NEWUSER123", 60*60*24*30, time.Now().Add(24*14*time.Hour).Unix()).
+		Suffix("RETURNING id").
+		RunWith(pgTest.conn).
+		QueryRow()
+	var couponId string
+	s.Nil(rowCreateCoupon.Scan(&couponId))
+
+	_, err := sq.
+		StatementBuilder.
+		PlaceholderFormat(sq.Dollar).
+		Insert("user_coupons").
+		Columns("user_id", "coupon_id").
+		Values(userId, couponId).
+		RunWith(pgTest.conn).
+		Exec()
+	s.Nil(err)
+
+	res := newHttpTest().
+		withPath("/v1/users/subscribe").
+		withMethod(http.MethodPost).
+		withBody(map[string]interface{}{
+			"coupon_code": "NEWUSER123",
+		}).
+		withHeader("Cookie", fmt.Sprintf("%s=%s", tokens[0][0], tokens[0][1])).
+		withHeader("Cookie", fmt.Sprintf("%s=%s", tokens[1][0], tokens[1][1])).
+		do()
+	s.Equal(http.StatusOK, res.StatusCode)
+
+	res = newHttpTest().
+		withPath("/v1/users/me/subscription").
+		withHeader("Cookie", fmt.Sprintf("%s=%s", tokens[0][0], tokens[0][1])).
+		withHeader("Cookie", fmt.Sprintf("%s=%s", tokens[1][0], tokens[1][1])).
+		do()
+
+	s.Equal(http.StatusOK, res.StatusCode)
+	body, err := io.ReadAll(res.Body)
+	s.Nil(err)
+	defer res.Body.Close()
+	var response struct {
+		Data struct {
+			Status string `json:"status"`
+			EndsAt int64  `json:"ends_at"`
+			Plan   struct {
+				Tier string `json:"tier"`
+			} `json:"plan"`
+			History []struct {
+				FromStatus *string `json:"from_status"`
+				ToStatus   string  `json:"to_status"`
+			} `json:"history"`
+		} `json:"data"`
+	}
+	s.Nil(json.Unmarshal(body, &response))
+	s.Equal("active", response.Data.Status)
+	s.Equal("premium", response.Data.Plan.Tier)
+	s.Equal(time.Now().Add(24*30*time.Hour).Unix(), response.Data.EndsAt)
+	s.Len(response.Data.History, 1)
+	s.Nil(response.Data.History[0].FromStatus)
+	s.Equal("active", response.Data.History[0].ToStatus)
+}
+
+func (s *UserTestSuite) Test_Post_UserSubscription_RevokedCoupon() {
+	tokens := getAuthToken(s.T(), pgTest.conn)
+
+	rowFindUser := sq.
+		StatementBuilder.
+		PlaceholderFormat(sq.Dollar).
+		Select("id").
+		From("users").
+		Where("email = ?", "base@mail.com").
+		RunWith(pgTest.conn).
+		QueryRow()
+	var userId string
+	s.Nil(rowFindUser.Scan(&userId))
+
+	rowCreateCoupon := sq.
+		StatementBuilder.
+		PlaceholderFormat(sq.Dollar).
+		Insert("coupons").
+		Columns("code", "duration_in_second", "valid_until").
+		Values("NEWUSER123", 60*60*24*30, time.Now().Add(24*14*time.Hour).Unix()).
+		Suffix("RETURNING id").
+		RunWith(pgTest.conn).
+		QueryRow()
+	var couponId string
+	s.Nil(rowCreateCoupon.Scan(&couponId))
+
+	_, err := sq.
+		StatementBuilder.
+		PlaceholderFormat(sq.Dollar).
+		Insert("user_coupons").
+		Columns("user_id", "coupon_id").
+		Values(userId, couponId).
+		RunWith(pgTest.conn).
+		Exec()
+	s.Nil(err)
+
+	_, err = sq.
+		StatementBuilder.
+		PlaceholderFormat(sq.Dollar).
+		Update("coupons").
+		Set("revoked_at", time.Now().Unix()).
+		Where("id = ?", couponId).
+		RunWith(pgTest.conn).
+		Exec()
+	s.Nil(err)
+
+	res := newHttpTest().
+		withPath("/v1/users/subscribe").
+		withMethod(http.MethodPost).
+		withBody(map[string]interface{}{
+			"coupon_code": "NEWUSER123",
+		}).
+		withHeader("Cookie", fmt.Sprintf("%s=%s", tokens[0][0], tokens[0][1])).
+		withHeader("Cookie", fmt.Sprintf("%s=%s", tokens[1][0], tokens[1][1])).
+		do()
+
+	s.Equal(http.StatusBadRequest, res.StatusCode)
+
+	rowUpdatedUser := sq.
+		StatementBuilder.
+		PlaceholderFormat(sq.Dollar).
+		Select("subscribe_until").
+		From("users").
+		Where("id = ?", userId).
+		RunWith(pgTest.conn).
+		QueryRow()
+	var subscribeUntil sql.NullInt64
+	s.Nil(rowUpdatedUser.Scan(&subscribeUntil))
+	s.False(subscribeUntil.Valid)
+}
+
+func (s *UserTestSuite) Test_Post_UserSubscription_ParallelRedemption() {
+	tokens := getAuthToken(s.T(), pgTest.conn)
+
+	rowFindUser := sq.
+		StatementBuilder.
+		PlaceholderFormat(sq.Dollar).
+		Select("id").
+		From("users").
+		Where("email = ?", "base@mail.com").
+		RunWith(pgTest.conn).
+		QueryRow()
+	var userId string
+	s.Nil(rowFindUser.Scan(&userId))
+
+	couponDuration := 60 * 60 * 24 * 30
+	codes := []string{"PARALLEL1", "PARALLEL2"}
+	for _, code := range codes {
+		rowCreateCoupon := sq.
+			StatementBuilder.
+			PlaceholderFormat(sq.Dollar).
+			Insert("coupons").
+			Columns("code", "duration_in_second", "valid_until").
+			Values(code, couponDuration, time.Now().Add(24*14*time.Hour).Unix()).
+			Suffix("RETURNING id").
+			RunWith(pgTest.conn).
+			QueryRow()
+		var couponId string
+		s.Nil(rowCreateCoupon.Scan(&couponId))
+
+		_, err := sq.
+			StatementBuilder.
+			PlaceholderFormat(sq.Dollar).
+			Insert("user_coupons").
+			Columns("user_id", "coupon_id").
+			Values(userId, couponId).
+			RunWith(pgTest.conn).
+			Exec()
+		s.Nil(err)
+	}
+
+	// every pooled connection must see the test schema while requests run concurrently
+	conn := pgTest.schemaConn(s.T())
+	defer conn.Close()
+
+	// each coupon is redeemed twice at the same time, only one of each may succeed
+	handler := rest.NewHandler(newTestApp(conn))
+	statuses := make(chan int, 2*len(codes))
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for _, code := range append(codes, codes...) {
+		wg.Add(1)
+		go func(code string) {
+			defer wg.Done()
+			req := newHttpTest().
+				withPath("/v1/users/subscribe").
+				withMethod(http.MethodPost).
+				withBody(map[string]interface{}{
+					"coupon_code": code,
+				}).
+				withHeader("Cookie", fmt.Sprintf("%s=%s", tokens[0][0], tokens[0][1])).
+				withHeader("Cookie", fmt.Sprintf("%s=%s", tokens[1][0], tokens[1][1]))
+			<-start
+			statuses <- req.doWith(handler).StatusCode
+		}(code)
+	}
+	close(start)
+	wg.Wait()
+	close(statuses)
+
+	counts := make(map[int]int)
+	for status := range statuses {
+		counts[status]++
+	}
+	s.Equal(map[int]int{http.StatusOK: 2, http.StatusNotFound: 2}, counts)
+
+	// both extensions are applied, none is lost by reading stale subscription end
+	var subscribeUntil sql.NullInt64
+	s.Nil(sq.
+		StatementBuilder.
+		PlaceholderFormat(sq.Dollar).
+		Select("subscribe_until").
+		From("users").
+		Where("id = ?", userId).
+		RunWith(pgTest.conn).
+		QueryRow().
+		Scan(&subscribeUntil))
+	s.InDelta(time.Now().Add(2*time.Duration(couponDuration)*time.Second).Unix(), subscribeUntil.Int64, 2)
+
+	var subscriptions, histories int
+	s.Nil(sq.
+		StatementBuilder.
+		PlaceholderFormat(sq.Dollar).
+		Select("COUNT(DISTINCT subscriptions.id)", "COUNT(subscription_histories.id)").
+		From("subscriptions").
+		InnerJoin("subscription_histories ON subscription_histories.subscription_id = subscriptions.id").
+		Where("subscriptions.user_id = ?", userId).
+		RunWith(pgTest.conn).
+		QueryRow().
+		Scan(&subscriptions, &histories))
+	s.Equal(1, subscriptions)
+	s.Equal(2, histories)
+}
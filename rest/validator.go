@@ -1,22 +1,52 @@
 package rest
 
 import (
+	"reflect"
+	"strings"
 	"sync"
 
 	"github.com/gin-gonic/gin/binding"
 	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/id"
 	ut "github.com/go-playground/universal-translator"
 	"github.com/go-playground/validator/v10"
 	en_translations "github.com/go-playground/validator/v10/translations/en"
+	id_translations "github.com/go-playground/validator/v10/translations/id"
 	"github.com/pkg/errors"
+	"golang.org/x/text/language"
 )
 
-// bindValidator is a type for custom validator for gin binding process
-type bindValidator struct {
-	once       sync.Once
-	validate   *validator.Validate
-	translator ut.Translator
-}
+type (
+	// bindValidator is a type for custom validator for gin binding process
+	bindValidator struct {
+		once     sync.Once
+		validate *validator.Validate
+		uni      *ut.UniversalTranslator
+	}
+
+	// validationError is a type of error of invalid fields of the request, translated on rendering
+	validationError struct {
+		errs     validator.ValidationErrors
+		fallback ut.Translator
+	}
+
+	// fieldError is a type of translated error of one invalid field of the request
+	fieldError struct {
+		Field   string `json:"field"`
+		Message string `json:"error"`
+	}
+)
+
+var (
+	// validate is the validator of gin binding process, shared by all handlers as gin binding is global.
+	// it holds no state of any app, so apps in the same process can share it
+	validate = new(bindValidator)
+
+	// useValidate make gin binding use validate, once per process so building handlers never races on it
+	useValidate = sync.OnceFunc(func() {
+		binding.Validator = validate
+	})
+)
 
 var _ binding.StructValidator = &bindValidator{}
 
@@ -30,11 +60,7 @@ func (v *bindValidator) ValidateStruct(obj any) error {
 	if !ok {
 		return err
 	}
-	for _, e := range validatorErrs {
-		message := e.Translate(v.translator)
-		return errors.New(message)
-	}
-	return err
+	return validationError{errs: validatorErrs, fallback: v.uni.GetFallback()}
 }
 
 func (v *bindValidator) Engine() any {
@@ -42,19 +68,80 @@ func (v *bindValidator) Engine() any {
 	return v.validate
 }
 
+// translator give translator of the most preferred supported locale of Accept-Language header,
+// english is used when none is supported
+func (v *bindValidator) translator(acceptLanguage string) ut.Translator {
+	v.lazyinit()
+	tags, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil {
+		return v.uni.GetFallback()
+	}
+	locales := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		base, _ := tag.Base()
+		locales = append(locales, base.String())
+	}
+	trans, _ := v.uni.FindTranslator(locales...)
+	return trans
+}
+
 // lazyinit do initialize of bindValidator
 func (v *bindValidator) lazyinit() {
 	v.once.Do(func() {
 		v.validate = validator.New()
+		v.validate.RegisterTagNameFunc(fieldName)
+
 		english := en.New()
-		uni := ut.New(english, english)
-		trans, ok := uni.GetTranslator("en")
-		if !ok {
-			panic(errors.New("failed to get translator"))
+		v.uni = ut.New(english, english, id.New())
+		v.register("en", en_translations.RegisterDefaultTranslations)
+		v.register("id", id_translations.RegisterDefaultTranslations)
+	})
+}
+
+// register register validation and domain error translations of the locale
+func (v *bindValidator) register(locale string, registerFn func(*validator.Validate, ut.Translator) error) {
+	trans, ok := v.uni.GetTranslator(locale)
+	if !ok {
+		panic(errors.Errorf("failed to get translator %s", locale))
+	}
+	if err := registerFn(v.validate, trans); err != nil {
+		panic(errors.Wrapf(err, "failed to register translator %s", locale))
+	}
+	for code, message := range errMessages[locale] {
+		if err := trans.Add(string(code), message, false); err != nil {
+			panic(errors.Wrapf(err, "failed to register error message %s of %s", code, locale))
 		}
-		v.translator = trans
-		if err := en_translations.RegisterDefaultTranslations(v.validate, v.translator); err != nil {
-			panic(errors.Wrap(err, "failed to register translator"))
+	}
+}
+
+// fieldName give name of the field as the client sent it, following json, form and uri tags in order
+func fieldName(field reflect.StructField) string {
+	for _, key := range []string{"json", "form", "uri"} {
+		name, _, _ := strings.Cut(field.Tag.Get(key), ",")
+		if name != "" && name != "-" {
+			return name
 		}
-	})
+	}
+	return field.Name
+}
+
+func (e validationError) Error() string {
+	fields := e.fields(e.fallback)
+	messages := make([]string, 0, len(fields))
+	for _, f := range fields {
+		messages = append(messages, f.Message)
+	}
+	return strings.Join(messages, "; ")
+}
+
+// fields give all invalid fields of the request translated by trans
+func (e validationError) fields(trans ut.Translator) []fieldError {
+	fields := make([]fieldError, 0, len(e.errs))
+	for _, err := range e.errs {
+		fields = append(fields, fieldError{
+			Field:   err.Field(),
+			Message: err.Translate(trans),
+		})
+	}
+	return fields
 }
@@ -0,0 +1,107 @@
+package subscription
+
+import (
+	"context"
+	"gotinder/notification"
+	"log/slog"
+	"time"
+)
+
+const (
+	defaultGracePeriod  = 3 * 24 * time.Hour
+	defaultNoticePeriod = 3 * 24 * time.Hour
+)
+
+type (
+	// transition is a type of function moving subscriptions to next lifecycle step, giving notification events of them
+	transition func(ctx context.Context, now time.Time) ([]notification.Event, error)
+)
+
+// NewExpiryJob give job which notify subscriptions ending within noticePeriod,
+// move ended subscriptions to grace for gracePeriod and expire them once grace is over
+func (s *Service) NewExpiryJob(gracePeriod, noticePeriod time.Duration) func(ctx context.Context) error {
+	if gracePeriod <= 0 {
+		gracePeriod = defaultGracePeriod
+	}
+	if noticePeriod <= 0 {
+		noticePeriod = defaultNoticePeriod
+	}
+
+	transitions := []transition{
+		func(ctx context.Context, now time.Time) ([]notification.Event, error) {
+			subscriptions, err := s.repo.MarkExpiring(ctx, now, now.Add(noticePeriod))
+			if err != nil {
+				return nil, err
+			}
+			return s.notify(ctx, subscriptions, notification.SubscriptionExpiring)
+		},
+		func(ctx context.Context, now time.Time) ([]notification.Event, error) {
+			subscriptions, err := s.repo.EnterGrace(ctx, now, gracePeriod)
+			if err != nil {
+				return nil, err
+			}
+			return s.moved(ctx, subscriptions, Active, Grace, notification.SubscriptionGrace)
+		},
+		func(ctx context.Context, now time.Time) ([]notification.Event, error) {
+			subscriptions, err := s.repo.ExpireGrace(ctx, now)
+			if err != nil {
+				return nil, err
+			}
+			return s.moved(ctx, subscriptions, Grace, Expired, notification.SubscriptionExpired)
+		},
+	}
+
+	return func(ctx context.Context) error {
+		now := time.Now()
+		for _, transition := range transitions {
+			// each transition runs in its own transaction
+			var events []notification.Event
+			if err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+				var err error
+				events, err = transition(ctx, now)
+				return err
+			}); err != nil {
+				return err
+			}
+			// events are already recorded, failing to publish must not roll the transition back
+			if err := s.publisher.Publish(ctx, events); err != nil {
+				slog.ErrorContext(ctx, "failed to publish subscription events", "error", err)
+			}
+		}
+		return nil
+	}
+}
+
+// moved record history and notification event of every moved subscription
+func (s *Service) moved(ctx context.Context, subscriptions []Subscription, from, to Status, notificationType string) ([]notification.Event, error) {
+	for _, subscription := range subscriptions {
+		if err := s.repo.RecordHistory(ctx, subscription.ID, from, to, subscription.EndsAt); err != nil {
+			return nil, err
+		}
+	}
+	return s.notify(ctx, subscriptions, notificationType)
+}
+
+// notify record notification event of every subscription
+func (s *Service) notify(ctx context.Context, subscriptions []Subscription, notificationType string) ([]notification.Event, error) {
+	events := make([]notification.Event, 0, len(subscriptions))
+	for _, subscription := range subscriptions {
+		payload := map[string]interface{}{
+			"subscription_id": subscription.ID.String(),
+			"ends_at":         subscription.EndsAt,
+		}
+		if subscription.GraceUntil != nil {
+			payload["grace_until"] = *subscription.GraceUntil
+		}
+		event := notification.Event{
+			UserID:  subscription.UserID,
+			Type:    notificationType,
+			Payload: payload,
+		}
+		if err := s.notifications.Record(ctx, &event); err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
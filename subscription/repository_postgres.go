@@ -0,0 +1,359 @@
+package subscription
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"gotinder/infra"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+// PostgresRepository store plans and subscriptions on postgresql
+type PostgresRepository struct {
+	db *sql.DB
+}
+
+var _ Repository = &PostgresRepository{}
+
+func NewPostgresRepository(db *sql.DB) *PostgresRepository {
+	return &PostgresRepository{db: db}
+}
+
+var psql = sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+func (r *PostgresRepository) FindPlans(ctx context.Context) ([]Plan, error) {
+	rows, err := selectPlans().
+		OrderBy("price ASC").
+		RunWith(infra.PgRunner(ctx, r.db)).
+		QueryContext(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to find plans")
+	}
+	defer rows.Close()
+
+	plans := make([]Plan, 0)
+	for rows.Next() {
+		plan, err := scanPlan(rows)
+		if err != nil {
+			return nil, err
+		}
+		plans = append(plans, plan)
+	}
+	return plans, rows.Err()
+}
+
+func (r *PostgresRepository) FindPlanByTier(ctx context.Context, tier string) (Plan, error) {
+	plan, err := scanPlan(selectPlans().Where("tier = ?", tier).RunWith(infra.PgRunner(ctx, r.db)).QueryRowContext(ctx))
+	if errors.Is(err, sql.ErrNoRows) {
+		return plan, errors.Wrapf(ErrPlanNotFound, "failed to find plan %s", tier)
+	}
+	if err != nil {
+		return plan, errors.Wrapf(err, "failed to find plan %s", tier)
+	}
+	return plan, nil
+}
+
+func (r *PostgresRepository) FindPlanByID(ctx context.Context, id uuid.UUID) (Plan, error) {
+	plan, err := scanPlan(selectPlans().Where("id = ?", id).RunWith(infra.PgRunner(ctx, r.db)).QueryRowContext(ctx))
+	if errors.Is(err, sql.ErrNoRows) {
+		return plan, ErrPlanNotFound
+	}
+	return plan, err
+}
+
+func (r *PostgresRepository) FindLatest(ctx context.Context, userID string) (Subscription, error) {
+	subscription, err := scanSubscription(selectSubscriptions().
+		Where("user_id = ?", userID).
+		OrderBy("created_at DESC").
+		Limit(1).
+		RunWith(infra.PgRunner(ctx, r.db)).
+		QueryRowContext(ctx))
+	if errors.Is(err, sql.ErrNoRows) {
+		return subscription, ErrSubscriptionNotFound
+	}
+	if err != nil {
+		return subscription, errors.Wrap(err, "failed to find subscription")
+	}
+	return subscription, nil
+}
+
+func (r *PostgresRepository) FindHistory(ctx context.Context, subscriptionID uuid.UUID) ([]History, error) {
+	rows, err := psql.
+		Select("from_status", "to_status", "ends_at", "created_at").
+		From("subscription_histories").
+		Where("subscription_id = ?", subscriptionID).
+		// created_at is in second, so transitions recorded within the same second are ordered by recorded_at
+		OrderBy("recorded_at ASC", "id ASC").
+		RunWith(infra.PgRunner(ctx, r.db)).
+		QueryContext(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to find subscription history")
+	}
+	defer rows.Close()
+
+	history := make([]History, 0)
+	for rows.Next() {
+		var h History
+		var fromStatus sql.NullString
+		if err := rows.Scan(&fromStatus, &h.ToStatus, &h.EndsAt, &h.CreatedAt); err != nil {
+			return nil, err
+		}
+		if fromStatus.Valid {
+			from := Status(fromStatus.String)
+			h.FromStatus = &from
+		}
+		history = append(history, h)
+	}
+	return history, rows.Err()
+}
+
+func (r *PostgresRepository) LockCurrent(ctx context.Context, userID string) (Subscription, error) {
+	subscription, err := scanSubscription(selectSubscriptions().
+		Where("user_id = ?", userID).
+		Where(sq.Eq{"status": []Status{Active, Grace}}).
+		Suffix("FOR UPDATE").
+		RunWith(infra.PgRunner(ctx, r.db)).
+		QueryRowContext(ctx))
+	if errors.Is(err, sql.ErrNoRows) {
+		return subscription, ErrSubscriptionNotFound
+	}
+	if err != nil {
+		return subscription, errors.Wrap(err, "failed to find current subscription")
+	}
+	return subscription, nil
+}
+
+func (r *PostgresRepository) Lock(ctx context.Context, id uuid.UUID) (Subscription, error) {
+	subscription, err := scanSubscription(selectSubscriptions().
+		Where("id = ?", id).
+		Suffix("FOR UPDATE").
+		RunWith(infra.PgRunner(ctx, r.db)).
+		QueryRowContext(ctx))
+	if errors.Is(err, sql.ErrNoRows) {
+		return subscription, ErrSubscriptionNotFound
+	}
+	if err != nil {
+		return subscription, errors.Wrap(err, "failed to find subscription")
+	}
+	return subscription, nil
+}
+
+func (r *PostgresRepository) Create(ctx context.Context, s *Subscription) error {
+	if err := psql.
+		Insert("subscriptions").
+		Columns("user_id", "plan_id", "status", "started_at", "ends_at").
+		Values(s.UserID, s.PlanID, s.Status, s.StartedAt, s.EndsAt).
+		Suffix("RETURNING id").
+		RunWith(infra.PgRunner(ctx, r.db)).
+		QueryRowContext(ctx).
+		Scan(&s.ID); err != nil {
+		return errors.Wrap(err, "failed to create subscription")
+	}
+	return nil
+}
+
+func (r *PostgresRepository) Renew(ctx context.Context, id, planID uuid.UUID, endsAt time.Time) error {
+	if _, err := psql.
+		Update("subscriptions").
+		Set("plan_id", planID).
+		Set("status", Active).
+		Set("ends_at", endsAt.Unix()).
+		Set("grace_until", nil).
+		Set("expiry_notified_at", nil).
+		Set("updated_at", time.Now().Unix()).
+		Where("id = ?", id).
+		RunWith(infra.PgRunner(ctx, r.db)).
+		ExecContext(ctx); err != nil {
+		return errors.Wrap(err, "failed to extend subscription")
+	}
+	return nil
+}
+
+func (r *PostgresRepository) UpdateStatus(ctx context.Context, id uuid.UUID, status Status) error {
+	if _, err := psql.
+		Update("subscriptions").
+		Set("status", status).
+		Set("updated_at", time.Now().Unix()).
+		Where("id = ?", id).
+		RunWith(infra.PgRunner(ctx, r.db)).
+		ExecContext(ctx); err != nil {
+		return errors.Wrapf(err, "failed to move subscription to %s", status)
+	}
+	return nil
+}
+
+// RecordHistory record status transition of subscription, empty from means newly created
+func (r *PostgresRepository) RecordHistory(ctx context.Context, subscriptionID uuid.UUID, from, to Status, endsAt int64) error {
+	var fromStatus sql.NullString
+	if from != "" {
+		fromStatus = sql.NullString{String: string(from), Valid: true}
+	}
+	if _, err := psql.
+		Insert("subscription_histories").
+		Columns("subscription_id", "from_status", "to_status", "ends_at").
+		Values(subscriptionID, fromStatus, to, endsAt).
+		RunWith(infra.PgRunner(ctx, r.db)).
+		ExecContext(ctx); err != nil {
+		return errors.Wrap(err, "failed to record subscription history")
+	}
+	return nil
+}
+
+func (r *PostgresRepository) MarkExpiring(ctx context.Context, now, until time.Time) ([]Subscription, error) {
+	subscriptions, err := r.returning(ctx, psql.
+		Update("subscriptions").
+		Set("expiry_notified_at", now.Unix()).
+		Where("status = ?", Active).
+		Where("ends_at > ?", now.Unix()).
+		Where("ends_at <= ?", until.Unix()).
+		Where("expiry_notified_at IS NULL"))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to find expiring subscriptions")
+	}
+	return subscriptions, nil
+}
+
+func (r *PostgresRepository) EnterGrace(ctx context.Context, now time.Time, gracePeriod time.Duration) ([]Subscription, error) {
+	subscriptions, err := r.returning(ctx, psql.
+		Update("subscriptions").
+		Set("status", Grace).
+		Set("grace_until", sq.Expr("ends_at + ?", int64(gracePeriod.Seconds()))).
+		Set("updated_at", now.Unix()).
+		Where("status = ?", Active).
+		Where("ends_at <= ?", now.Unix()))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to move subscriptions to %s", Grace)
+	}
+	return subscriptions, nil
+}
+
+func (r *PostgresRepository) ExpireGrace(ctx context.Context, now time.Time) ([]Subscription, error) {
+	subscriptions, err := r.returning(ctx, psql.
+		Update("subscriptions").
+		Set("status", Expired).
+		Set("updated_at", now.Unix()).
+		Where("status = ?", Grace).
+		Where("grace_until <= ?", now.Unix()))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to move subscriptions to %s", Expired)
+	}
+	return subscriptions, nil
+}
+
+func (r *PostgresRepository) LockSubscriber(ctx context.Context, userID string) (*int64, error) {
+	var subscribeUntil sql.NullInt64
+	err := psql.
+		Select("subscribe_until").
+		From("users").
+		Where("id = ?", userID).
+		Suffix("FOR UPDATE").
+		RunWith(infra.PgRunner(ctx, r.db)).
+		QueryRowContext(ctx).
+		Scan(&subscribeUntil)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrSubscriberNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to find subscriber")
+	}
+	if !subscribeUntil.Valid {
+		return nil, nil
+	}
+	return &subscribeUntil.Int64, nil
+}
+
+func (r *PostgresRepository) UpdateSubscriber(ctx context.Context, userID string, subscribeUntil time.Time) error {
+	if _, err := psql.
+		Update("users").
+		Set("subscribe_until", subscribeUntil.Unix()).
+		Where("id = ?", userID).
+		RunWith(infra.PgRunner(ctx, r.db)).
+		ExecContext(ctx); err != nil {
+		return errors.Wrap(err, "failed to update subscriber")
+	}
+	return nil
+}
+
+// returning run the update, giving subscriptions it changed
+func (r *PostgresRepository) returning(ctx context.Context, update sq.UpdateBuilder) ([]Subscription, error) {
+	rows, err := update.
+		Suffix("RETURNING id, user_id, plan_id, status, started_at, ends_at, grace_until").
+		RunWith(infra.PgRunner(ctx, r.db)).
+		QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	subscriptions := make([]Subscription, 0)
+	for rows.Next() {
+		subscription, err := scanSubscription(rows)
+		if err != nil {
+			return nil, err
+		}
+		subscriptions = append(subscriptions, subscription)
+	}
+	return subscriptions, rows.Err()
+}
+
+// selectPlans build query to select plan columns in the order expected by scanPlan
+func selectPlans() sq.SelectBuilder {
+	return psql.
+		Select("id", "tier", "name", "features", "quotas", "price", "currency", "duration_in_second").
+		From("plans")
+}
+
+// scanPlan read plan columns selected by selectPlans
+func scanPlan(row sq.RowScanner) (Plan, error) {
+	var plan Plan
+	var quotas []byte
+	if err := row.Scan(
+		&plan.ID,
+		&plan.Tier,
+		&plan.Name,
+		pq.Array(&plan.Features),
+		&quotas,
+		&plan.Price,
+		&plan.Currency,
+		&plan.DurationInSecond,
+	); err != nil {
+		return plan, err
+	}
+	if err := json.Unmarshal(quotas, &plan.Quotas); err != nil {
+		return plan, errors.Wrap(err, "failed to decode plan quotas")
+	}
+	return plan, nil
+}
+
+// selectSubscriptions build query to select subscription columns in the order expected by scanSubscription
+func selectSubscriptions() sq.SelectBuilder {
+	return psql.
+		Select("id", "user_id", "plan_id", "status", "started_at", "ends_at", "grace_until").
+		From("subscriptions")
+}
+
+// scanSubscription read subscription columns selected by selectSubscriptions
+func scanSubscription(row sq.RowScanner) (Subscription, error) {
+	var subscription Subscription
+	var graceUntil sql.NullInt64
+	if err := row.Scan(
+		&subscription.ID,
+		&subscription.UserID,
+		&subscription.PlanID,
+		&subscription.Status,
+		&subscription.StartedAt,
+		&subscription.EndsAt,
+		&graceUntil,
+	); err != nil {
+		return subscription, err
+	}
+	if graceUntil.Valid {
+		subscription.GraceUntil = &graceUntil.Int64
+	}
+	return subscription, nil
+}
@@ -0,0 +1,209 @@
+package subscription
+
+import (
+	"context"
+	"gotinder/infra"
+	"gotinder/notification"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+type (
+	// Service is a type of subscription business logic
+	Service struct {
+		repo          Repository
+		tx            infra.Transactor
+		notifications notification.Repository
+		publisher     notification.Publisher
+	}
+
+	// Overview is a type of current subscription of a user, Subscription is nil when user never subscribed
+	Overview struct {
+		Subscription *Subscription
+		Plan         Plan
+		History      []History
+	}
+)
+
+func NewService(repo Repository, tx infra.Transactor, notifications notification.Repository, publisher notification.Publisher) *Service {
+	return &Service{
+		repo:          repo,
+		tx:            tx,
+		notifications: notifications,
+		publisher:     publisher,
+	}
+}
+
+// Plans give list of available subscription plans, cheapest first
+func (s *Service) Plans(ctx context.Context) ([]Plan, error) {
+	return s.repo.FindPlans(ctx)
+}
+
+// PlanByTier give plan of the tier
+func (s *Service) PlanByTier(ctx context.Context, tier string) (Plan, error) {
+	return s.repo.FindPlanByTier(ctx, tier)
+}
+
+// Overview give latest subscription of the user along with its status history,
+// plan of fallbackTier is given when user never subscribed
+func (s *Service) Overview(ctx context.Context, userID, fallbackTier string) (Overview, error) {
+	var overview Overview
+	subscription, err := s.repo.FindLatest(ctx, userID)
+	switch {
+	case errors.Is(err, ErrSubscriptionNotFound):
+		overview.Plan, err = s.repo.FindPlanByTier(ctx, fallbackTier)
+		if err != nil {
+			return overview, err
+		}
+		overview.History = make([]History, 0)
+		return overview, nil
+	case err != nil:
+		return overview, err
+	}
+	overview.Subscription = &subscription
+
+	overview.Plan, err = s.repo.FindPlanByID(ctx, subscription.PlanID)
+	if err != nil {
+		return overview, errors.Wrap(err, "failed to find subscription plan")
+	}
+
+	overview.History, err = s.repo.FindHistory(ctx, subscription.ID)
+	if err != nil {
+		return overview, err
+	}
+	return overview, nil
+}
+
+// LockSubscriber lock the user, so changes of their subscription are applied one after another.
+// it has to be called before inserting rows referencing the user, as their foreign key check shares the lock
+// and upgrading it later deadlocks with concurrent transaction doing the same. must be called within transaction
+func (s *Service) LockSubscriber(ctx context.Context, userID string) error {
+	_, err := s.repo.LockSubscriber(ctx, userID)
+	return err
+}
+
+// Extend lock the user and extend their subscription by duration,
+// counted from current subscription end when it is still running. must be called within transaction
+func (s *Service) Extend(ctx context.Context, userID, tier string, duration time.Duration) (time.Time, error) {
+	subscribedUntil, err := s.repo.LockSubscriber(ctx, userID)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	subscribeUntil := time.Now()
+	if subscribedUntil != nil && subscribeUntil.Before(time.Unix(*subscribedUntil, 0)) {
+		subscribeUntil = time.Unix(*subscribedUntil, 0)
+	}
+	subscribeUntil = subscribeUntil.Add(duration)
+
+	if err := s.repo.UpdateSubscriber(ctx, userID, subscribeUntil); err != nil {
+		return time.Time{}, err
+	}
+
+	if _, err := s.Record(ctx, userID, tier, subscribeUntil); err != nil {
+		return time.Time{}, err
+	}
+	return subscribeUntil, nil
+}
+
+// Activate activate or renew user's subscription until periodEnd, giving id of the subscription along with
+// time it granted beyond the running one. longer running subscription is not shortened.
+// must be called within transaction
+func (s *Service) Activate(ctx context.Context, userID, tier string, periodEnd time.Time) (uuid.UUID, time.Duration, error) {
+	subscribeUntil, err := s.repo.LockSubscriber(ctx, userID)
+	if err != nil {
+		return uuid.Nil, 0, err
+	}
+	runningUntil := time.Now()
+	if subscribeUntil != nil && runningUntil.Before(time.Unix(*subscribeUntil, 0)) {
+		runningUntil = time.Unix(*subscribeUntil, 0)
+	}
+	granted := periodEnd.Sub(runningUntil)
+	if granted < 0 {
+		granted = 0
+		periodEnd = runningUntil
+	}
+
+	subscriptionID, err := s.Record(ctx, userID, tier, periodEnd)
+	if err != nil {
+		return uuid.Nil, 0, err
+	}
+
+	if err := s.repo.UpdateSubscriber(ctx, userID, periodEnd); err != nil {
+		return uuid.Nil, 0, err
+	}
+	return subscriptionID, granted, nil
+}
+
+// Record put user on the plan until endsAt,
+// current subscription (active or grace) is extended instead of creating new one
+func (s *Service) Record(ctx context.Context, userID, tier string, endsAt time.Time) (uuid.UUID, error) {
+	plan, err := s.repo.FindPlanByTier(ctx, tier)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	current, err := s.repo.LockCurrent(ctx, userID)
+	switch {
+	case errors.Is(err, ErrSubscriptionNotFound):
+		subscription := Subscription{
+			UserID:    userID,
+			PlanID:    plan.ID,
+			Status:    Active,
+			StartedAt: time.Now().Unix(),
+			EndsAt:    endsAt.Unix(),
+		}
+		if err := s.repo.Create(ctx, &subscription); err != nil {
+			return uuid.Nil, err
+		}
+		return subscription.ID, s.repo.RecordHistory(ctx, subscription.ID, "", Active, endsAt.Unix())
+	case err != nil:
+		return uuid.Nil, err
+	}
+
+	if err := s.repo.Renew(ctx, current.ID, plan.ID, endsAt); err != nil {
+		return uuid.Nil, err
+	}
+	return current.ID, s.repo.RecordHistory(ctx, current.ID, current.Status, Active, endsAt.Unix())
+}
+
+// Cancel take revoke off the end of the user's subscription, not before now, when the subscription is still running.
+// time granted otherwise (e.g. by coupons) keeps it running, otherwise it is cancelled and entitlement of its user
+// ends immediately. false means nothing to cancel. must be called within transaction
+func (s *Service) Cancel(ctx context.Context, userID string, subscriptionID uuid.UUID, revoke time.Duration) (bool, error) {
+	subscribedUntil, err := s.repo.LockSubscriber(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	subscription, err := s.repo.Lock(ctx, subscriptionID)
+	if err != nil {
+		return false, err
+	}
+	if subscription.Status != Active && subscription.Status != Grace {
+		return false, nil
+	}
+
+	now := time.Now()
+	subscribeUntil := now
+	if subscribedUntil != nil {
+		if until := time.Unix(*subscribedUntil, 0).Add(-revoke); until.After(now) {
+			subscribeUntil = until
+		}
+	}
+	if err := s.repo.UpdateSubscriber(ctx, userID, subscribeUntil); err != nil {
+		return false, err
+	}
+
+	if subscribeUntil.After(now) {
+		if err := s.repo.Renew(ctx, subscriptionID, subscription.PlanID, subscribeUntil); err != nil {
+			return false, err
+		}
+		return true, s.repo.RecordHistory(ctx, subscriptionID, subscription.Status, Active, subscribeUntil.Unix())
+	}
+	if err := s.repo.UpdateStatus(ctx, subscriptionID, Cancelled); err != nil {
+		return false, err
+	}
+	return true, s.repo.RecordHistory(ctx, subscriptionID, subscription.Status, Cancelled, subscription.EndsAt)
+}
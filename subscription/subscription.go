@@ -0,0 +1,91 @@
+package subscription
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+const (
+	PlanFree    = "free"
+	PlanPremium = "premium"
+
+	Active    Status = "active"
+	Grace     Status = "grace"
+	Cancelled Status = "cancelled"
+	Expired   Status = "expired"
+)
+
+var (
+	ErrPlanNotFound         = errors.New("plan not found")
+	ErrSubscriptionNotFound = errors.New("subscription not found")
+	ErrSubscriberNotFound   = errors.New("subscriber not found")
+)
+
+type (
+	// Status is a type of subscription lifecycle status
+	Status string
+
+	// Plan is a type of subscription plan
+	Plan struct {
+		ID               uuid.UUID      `json:"id"`
+		Tier             string         `json:"tier"`
+		Name             string         `json:"name"`
+		Features         []string       `json:"features"`
+		Quotas           map[string]int `json:"quotas"`
+		Price            int64          `json:"price"`
+		Currency         string         `json:"currency"`
+		DurationInSecond int64          `json:"duration_in_second"`
+	}
+
+	// Subscription is a type of user subscription on a plan
+	Subscription struct {
+		ID         uuid.UUID
+		UserID     string
+		PlanID     uuid.UUID
+		Status     Status
+		StartedAt  int64
+		EndsAt     int64
+		GraceUntil *int64
+	}
+
+	// History is a type of subscription status transition
+	History struct {
+		FromStatus *Status `json:"from_status"`
+		ToStatus   Status  `json:"to_status"`
+		EndsAt     int64   `json:"ends_at"`
+		CreatedAt  int64   `json:"created_at"`
+	}
+
+	// Repository is an interface of plan and subscription storage,
+	// Lock methods hold the row until the transaction ends
+	Repository interface {
+		FindPlans(ctx context.Context) ([]Plan, error)
+		FindPlanByTier(ctx context.Context, tier string) (Plan, error)
+		FindPlanByID(ctx context.Context, id uuid.UUID) (Plan, error)
+		// FindLatest give most recently created subscription of the user
+		FindLatest(ctx context.Context, userID string) (Subscription, error)
+		// FindHistory give status transitions of the subscription, oldest first
+		FindHistory(ctx context.Context, subscriptionID uuid.UUID) ([]History, error)
+		// LockCurrent give running (active or grace) subscription of the user
+		LockCurrent(ctx context.Context, userID string) (Subscription, error)
+		Lock(ctx context.Context, id uuid.UUID) (Subscription, error)
+		Create(ctx context.Context, s *Subscription) error
+		// Renew put running subscription back to active on the plan until endsAt
+		Renew(ctx context.Context, id, planID uuid.UUID, endsAt time.Time) error
+		UpdateStatus(ctx context.Context, id uuid.UUID, status Status) error
+		RecordHistory(ctx context.Context, subscriptionID uuid.UUID, from, to Status, endsAt int64) error
+		// MarkExpiring mark active subscriptions ending before until which were not yet notified, giving them
+		MarkExpiring(ctx context.Context, now, until time.Time) ([]Subscription, error)
+		// EnterGrace move active subscriptions which already ended to grace until gracePeriod after their end, giving them
+		EnterGrace(ctx context.Context, now time.Time, gracePeriod time.Duration) ([]Subscription, error)
+		// ExpireGrace expire subscriptions which grace is over, giving them
+		ExpireGrace(ctx context.Context, now time.Time) ([]Subscription, error)
+
+		// LockSubscriber give subscribe_until of the user, kept for users subscribed before plans were introduced
+		LockSubscriber(ctx context.Context, userID string) (*int64, error)
+		UpdateSubscriber(ctx context.Context, userID string, subscribeUntil time.Time) error
+	}
+)
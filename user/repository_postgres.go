@@ -0,0 +1,99 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"gotinder/infra"
+	"gotinder/subscription"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+// PostgresRepository store users on postgresql
+type PostgresRepository struct {
+	db *sql.DB
+}
+
+var _ Repository = &PostgresRepository{}
+
+func NewPostgresRepository(db *sql.DB) *PostgresRepository {
+	return &PostgresRepository{db: db}
+}
+
+var psql = sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+func (r *PostgresRepository) Create(ctx context.Context, u User) error {
+	if _, err := psql.
+		Insert("users").
+		Columns("email", "password", "birth_of_date").
+		Values(u.Email, u.Password, u.BirthOfDate).
+		RunWith(infra.PgRunner(ctx, r.db)).
+		ExecContext(ctx); err != nil {
+		return errors.Wrap(err, "failed to record request")
+	}
+	return nil
+}
+
+func (r *PostgresRepository) FindByEmail(ctx context.Context, email string) (User, error) {
+	var u User
+	err := psql.
+		Select("id", "email", "password", "birth_of_date").
+		From("users").
+		Where("email = ?", email).
+		RunWith(infra.PgRunner(ctx, r.db)).
+		QueryRowContext(ctx).
+		Scan(&u.ID, &u.Email, &u.Password, &u.BirthOfDate)
+	if errors.Is(err, sql.ErrNoRows) {
+		return u, ErrUserNotFound
+	}
+	if err != nil {
+		return u, errors.Wrap(err, "failed to find user")
+	}
+	return u, nil
+}
+
+func (r *PostgresRepository) FindActor(ctx context.Context, email string) (Actor, error) {
+	now := time.Now().Unix()
+	var actor Actor
+	var quotas []byte
+	err := psql.
+		Select("users.id", "users.is_admin", "plans.tier", "plans.features", "plans.quotas").
+		From("users").
+		LeftJoin(
+			`subscriptions ON subscriptions.user_id = users.id AND (
+				(subscriptions.status = 'active' AND subscriptions.ends_at > ?) OR
+				(subscriptions.status = 'grace' AND subscriptions.grace_until > ?)
+			)`,
+			now,
+			now,
+		).
+		// users subscribed before plans were introduced only have subscribe_until
+		InnerJoin(
+			`plans ON plans.id = COALESCE(
+				subscriptions.plan_id,
+				(SELECT legacy.id FROM plans AS legacy WHERE legacy.tier = CASE WHEN users.subscribe_until > ? THEN ? ELSE ? END)
+			)`,
+			now,
+			subscription.PlanPremium,
+			subscription.PlanFree,
+		).
+		Where("users.email = ?", email).
+		RunWith(infra.PgRunner(ctx, r.db)).
+		QueryRowContext(ctx).
+		Scan(&actor.ID, &actor.IsAdmin, &actor.Tier, pq.Array(&actor.Features), &quotas)
+	if errors.Is(err, sql.ErrNoRows) {
+		return actor, ErrUserNotFound
+	}
+	if err != nil {
+		return actor, errors.Wrap(err, "failed to find user")
+	}
+
+	if err := json.Unmarshal(quotas, &actor.Quotas); err != nil {
+		return actor, errors.Wrap(err, "failed to decode plan quotas")
+	}
+	return actor, nil
+}
@@ -0,0 +1,65 @@
+package user
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	passwordvalidator "github.com/wagslane/go-password-validator"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// minPasswordEntropy is minimum entropy bits of accepted password
+const minPasswordEntropy = 35
+
+// Service is a type of user business logic
+type Service struct {
+	repo Repository
+}
+
+func NewService(repo Repository) *Service {
+	return &Service{repo: repo}
+}
+
+// Register validate password strength and record the user with hashed password
+func (s *Service) Register(ctx context.Context, email, password string, birthOfDate int64) error {
+	if err := passwordvalidator.Validate(password, minPasswordEntropy); err != nil {
+		return passwordError{err: err}
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return errors.Wrap(err, "failed to process request")
+	}
+
+	return s.repo.Create(ctx, User{
+		Email:       email,
+		Password:    string(hashedPassword),
+		BirthOfDate: birthOfDate,
+	})
+}
+
+// CheckCredential validate user's credential, unknown email is reported as invalid credential
+func (s *Service) CheckCredential(ctx context.Context, email, password string) (bool, error) {
+	u, err := s.repo.FindByEmail(ctx, email)
+	if errors.Is(err, ErrUserNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(password)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// FindByEmail give the user of the email
+func (s *Service) FindByEmail(ctx context.Context, email string) (User, error) {
+	return s.repo.FindByEmail(ctx, email)
+}
+
+// Actor give the user of the email along with entitlements of their current plan
+func (s *Service) Actor(ctx context.Context, email string) (Actor, error) {
+	return s.repo.FindActor(ctx, email)
+}
@@ -0,0 +1,54 @@
+package user
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrUserNotFound = errors.New("user not found")
+	ErrWeakPassword = errors.New("weak password")
+)
+
+type (
+	// User is a type of registered user, Password is bcrypt hash
+	User struct {
+		ID          uuid.UUID
+		Email       string
+		Password    string
+		BirthOfDate int64
+	}
+
+	// Actor is a type of user acting on a request along with entitlements of their current plan
+	Actor struct {
+		ID       string
+		IsAdmin  bool
+		Tier     string
+		Features []string
+		Quotas   map[string]int
+	}
+
+	// Repository is an interface of user storage
+	Repository interface {
+		Create(ctx context.Context, u User) error
+		FindByEmail(ctx context.Context, email string) (User, error)
+		// FindActor give the user with plan of their running subscription,
+		// users subscribed before plans were introduced are put on plan by their subscribe_until
+		FindActor(ctx context.Context, email string) (Actor, error)
+	}
+
+	// passwordError is a type of error of password not satisfying strength rule
+	passwordError struct {
+		err error
+	}
+)
+
+func (e passwordError) Error() string {
+	return e.err.Error()
+}
+
+func (e passwordError) Is(target error) bool {
+	return target == ErrWeakPassword
+}